@@ -27,6 +27,8 @@ import (
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/tracing"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
@@ -76,6 +78,13 @@ func main() {
 	command := os.Args[1]
 
 	switch command {
+	case tools.ExecLimitedCommand:
+		// Hidden: the exec tool starts commands through it to set resource
+		// limits. It only returns on error.
+		if err := tools.RunExecLimited(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "picoclaw: %v\n", err)
+			os.Exit(126)
+		}
 	case "onboard":
 		onboard()
 	case "agent":
@@ -387,6 +396,7 @@ func agentCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	defer setupTracing(cfg)()
 
 	provider, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -396,6 +406,7 @@ func agentCmd() {
 
 	msgBus := bus.NewMessageBus()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Stop() // flushes buffered MemDB stores
 
 	// Print agent startup info (only for interactive mode)
 	startupInfo := agentLoop.GetStartupInfo()
@@ -522,6 +533,7 @@ func gatewayCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	defer setupTracing(cfg)()
 
 	provider, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -530,6 +542,13 @@ func gatewayCmd() {
 	}
 
 	msgBus := bus.NewMessageBus()
+	if err := bus.ValidateBusyPolicy(cfg.Agents.Defaults.BusyPolicy); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if policy := cfg.Agents.Defaults.BusyPolicy; policy != "" {
+		msgBus.SetBusyPolicy(bus.BusyPolicy(policy))
+	}
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
 
 	// Print agent startup info
@@ -587,6 +606,8 @@ func gatewayCmd() {
 		}
 	}
 
+	agentLoop.SetTypingFunc(channelManager.StartTyping)
+
 	enabledChannels := channelManager.GetEnabledChannels()
 	if len(enabledChannels) > 0 {
 		fmt.Printf("✓ Channels enabled: %s\n", enabledChannels)
@@ -621,14 +642,45 @@ func gatewayCmd() {
 	<-sigChan
 
 	fmt.Println("\nShutting down...")
-	cancel()
 	heartbeatService.Stop()
 	cronService.Stop()
+	// Let the channels drain in-flight messages, with a deadline of their
+	// own, before the agent and the outbound dispatcher go away.
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	channelManager.StopAll(stopCtx)
+	stopCancel()
 	agentLoop.Stop()
-	channelManager.StopAll(ctx)
+	cancel()
 	fmt.Println("✓ Gateway stopped")
 }
 
+// setupTracing installs an OTLP tracer when tracing is enabled. The returned
+// func flushes buffered spans and must run before exiting.
+func setupTracing(cfg *config.Config) func() {
+	if !cfg.Tracing.Enabled {
+		return func() {}
+	}
+	tracer, shutdown, err := tracing.NewOTLPTracer(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.ServiceName)
+	if err != nil {
+		fmt.Printf("Error setting up tracing, continuing without it: %v\n", err)
+		return func() {}
+	}
+	tracing.SetTracer(tracer)
+	logger.InfoCF("tracing", "OTLP tracing enabled",
+		map[string]interface{}{
+			"endpoint": cfg.Tracing.Endpoint,
+			"service":  cfg.Tracing.ServiceName,
+		})
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.WarnCF("tracing", "Failed to flush spans", map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
 func statusCmd() {
 	cfg, err := loadConfig()
 	if err != nil {
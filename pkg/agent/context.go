@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
 )
@@ -18,6 +19,9 @@ type ContextBuilder struct {
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
 	toolsSummary func() []string // Function to get tool summaries dynamically
+	// channelPrompts holds per-channel instructions added to the global
+	// system prompt, keyed by channel name.
+	channelPrompts map[string]string
 }
 
 func getGlobalConfigDir() string {
@@ -43,6 +47,13 @@ func NewContextBuilder(workspace string, toolsSummaryFunc func() []string) *Cont
 	}
 }
 
+// SetChannelPrompts sets per-channel instructions, keyed by channel name,
+// that are merged into the system prompt for messages from that channel.
+// Channels without an entry get the global prompt only.
+func (cb *ContextBuilder) SetChannelPrompts(prompts map[string]string) {
+	cb.channelPrompts = prompts
+}
+
 func (cb *ContextBuilder) getIdentity() string {
 	now := time.Now().Format("2006-01-02 15:04 (Monday)")
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
@@ -146,20 +157,20 @@ func (cb *ContextBuilder) LoadBootstrapFiles() string {
 	return result
 }
 
-func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary string, currentMessage string, media []string, channel, chatID string, memdbContext string) []providers.Message {
+func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary string, currentMessage string, attachments []providers.Attachment, channel, chatID string, memories *memory.SearchResult) []providers.Message {
 	messages := []providers.Message{}
 
-	systemPrompt := cb.BuildSystemPrompt()
+	pb := NewPromptBuilder(cb.BuildSystemPrompt())
+	pb.AddSection("Channel Instructions", cb.channelPrompts[channel])
 
 	// Add Current Session info if provided
 	if channel != "" && chatID != "" {
-		systemPrompt += fmt.Sprintf("\n\n## Current Session\nChannel: %s\nChat ID: %s", channel, chatID)
+		pb.AddSection("Current Session", fmt.Sprintf("Channel: %s\nChat ID: %s", channel, chatID))
 	}
+	pb.AddSection("Summary of Previous Conversation", summary)
+	pb.WithMemories(memories)
 
-	// Add MemDB context if available
-	if memdbContext != "" {
-		systemPrompt += "\n\n" + memdbContext
-	}
+	systemPrompt := pb.Build()
 
 	// Log system prompt summary for debugging (debug mode only)
 	logger.DebugCF("agent", "System prompt built",
@@ -179,10 +190,6 @@ func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary str
 			"preview": preview,
 		})
 
-	if summary != "" {
-		systemPrompt += "\n\n## Summary of Previous Conversation\n\n" + summary
-	}
-
 	messages = append(messages, providers.Message{
 		Role:    "system",
 		Content: systemPrompt,
@@ -191,20 +198,16 @@ func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary str
 	messages = append(messages, history...)
 
 	messages = append(messages, providers.Message{
-		Role:    "user",
-		Content: currentMessage,
+		Role:        "user",
+		Content:     currentMessage,
+		Attachments: attachments,
 	})
 
 	return messages
 }
 
 func (cb *ContextBuilder) AddToolResult(messages []providers.Message, toolCallID, toolName, result string) []providers.Message {
-	messages = append(messages, providers.Message{
-		Role:       "tool",
-		Content:    result,
-		ToolCallID: toolCallID,
-	})
-	return messages
+	return append(messages, providers.NewToolResult(toolCallID, result))
 }
 
 func (cb *ContextBuilder) AddAssistantMessage(messages []providers.Message, content string, toolCalls []map[string]interface{}) []providers.Message {
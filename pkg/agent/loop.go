@@ -9,11 +9,14 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -22,19 +25,36 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/tracing"
 )
 
+// defaultMaxToolIterations bounds the LLM calls in a turn when
+// max_tool_iterations is unset, so a model that keeps calling tools cannot
+// loop forever.
+const defaultMaxToolIterations = 20
+
 type AgentLoop struct {
-	bus            *bus.MessageBus
-	provider       providers.LLMProvider
-	workspace      string
-	model          string
-	maxIterations  int
-	sessions       *session.SessionManager
-	contextBuilder *ContextBuilder
-	tools          *tools.ToolRegistry
-	memdb          *memory.MemDBClient
-	running        atomic.Bool
+	bus              *bus.MessageBus
+	provider         providers.LLMProvider
+	workspace        string
+	model            string
+	maxIterations    int
+	maxParallelTools int
+	sessions         *session.SessionManager
+	history          *session.History
+	contextBuilder   *ContextBuilder
+	tools            *tools.ToolRegistry
+	memdb            *memory.MemDBClient
+	storePolicy      *memory.StorePolicy
+	memdbPerUser     bool
+	thinkingBudget   *int
+	googleSearch     bool
+	llmTimeout       time.Duration
+	modelPrices      map[string]providers.ModelPrice
+	maxRequestCost   float64
+	usage            sync.Map // sessionKey -> *providers.SessionUsage
+	typing           TypingFunc
+	running          atomic.Bool
 }
 
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
@@ -42,16 +62,100 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	os.MkdirAll(workspace, 0755)
 
 	toolsRegistry := tools.NewToolRegistry()
+	toolsRegistry.SetTimeout(time.Duration(cfg.Agents.Defaults.ToolTimeoutSeconds) * time.Second)
+	toolsRegistry.SetResultLimit(cfg.Tools.MaxResultChars, workspace)
 	toolsRegistry.Register(tools.NewReadFileTool(""))
 	toolsRegistry.Register(tools.NewWriteFileTool(""))
-	toolsRegistry.Register(tools.NewListDirTool(""))
+	listDirTool := tools.NewListDirTool("")
+	listDirTool.SetIgnorePatterns(cfg.Tools.IgnorePatterns)
+	toolsRegistry.Register(listDirTool)
+	grepTool := tools.NewGrepTool(workspace)
+	grepTool.SetIgnorePatterns(cfg.Tools.IgnorePatterns)
+	toolsRegistry.Register(grepTool)
 	execTool := tools.NewExecTool(workspace)
 	execTool.SetRestrictToWorkspace(false)
-	toolsRegistry.Register(execTool)
+	execTool.SetDefaultWorkingDir(cfg.Tools.Exec.DefaultWorkingDir)
+	execTool.SetMaxOutputLen(cfg.Tools.Exec.MaxOutputLen)
+	execTool.SetMaxConcurrent(cfg.Tools.Exec.MaxConcurrent, time.Duration(cfg.Tools.Exec.QueueTimeoutSeconds)*time.Second)
+	denyRules := make([]tools.DenyRule, 0, len(cfg.Tools.Exec.DenyPatterns)+len(cfg.Tools.Exec.DenyRules))
+	for _, p := range cfg.Tools.Exec.DenyPatterns {
+		denyRules = append(denyRules, tools.DenyRule{Pattern: p, Severity: tools.GuardBlock})
+	}
+	for _, r := range cfg.Tools.Exec.DenyRules {
+		denyRules = append(denyRules, tools.DenyRule{Pattern: r.Pattern, Severity: tools.GuardSeverity(r.Severity)})
+	}
+	if err := execTool.SetDenyRules(denyRules); err != nil {
+		logger.ErrorCF("agent", "Invalid exec deny pattern, using defaults only", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if err := execTool.SetExtraPathWhitelist(cfg.Tools.Exec.ExtraPathWhitelist); err != nil {
+		logger.ErrorCF("agent", "Invalid exec extra path whitelist, ignoring it", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	execTool.SetRedactSecrets(!cfg.Tools.Exec.DisableRedaction)
+	if err := execTool.SetRedactPatterns(cfg.Tools.Exec.RedactPatterns); err != nil {
+		logger.ErrorCF("agent", "Invalid exec redact pattern, using built-in secret formats only", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if cfg.Tools.Exec.DisableGuard {
+		execTool.SetGuardEnabled(false)
+		logger.WarnCF("agent", "EXEC SAFETY GUARD DISABLED: the agent can run any shell command without restriction. Only use this on a trusted single-user machine.", map[string]interface{}{
+			"setting": "tools.exec.disable_guard",
+		})
+	}
+	execEnabled := true
+	if path := cfg.Tools.Exec.AllowPatternsFile; path != "" {
+		if err := execTool.LoadAllowPatternsFromFile(path); err != nil {
+			// Fail closed: an allowlist that didn't load must not mean "allow all".
+			logger.ErrorCF("agent", "Failed to load exec allow patterns, exec tool disabled", map[string]interface{}{
+				"path":  path,
+				"error": err.Error(),
+			})
+			execEnabled = false
+		}
+	}
+	execTool.SetAuditFunc(func(e tools.ExecAuditEvent) {
+		fields := map[string]interface{}{
+			"phase":       string(e.Phase),
+			"command":     e.Command,
+			"working_dir": e.WorkingDir,
+		}
+		if e.BlockedReason != "" {
+			fields["blocked_reason"] = e.BlockedReason
+			logger.WarnCF("exec", "Command blocked", fields)
+			return
+		}
+		if e.BusyReason != "" {
+			fields["busy_reason"] = e.BusyReason
+			logger.WarnCF("exec", "Command rejected, too many running", fields)
+			return
+		}
+		if e.Phase == tools.ExecAuditAfter {
+			fields["exit_code"] = e.ExitCode
+			fields["timed_out"] = e.TimedOut
+			fields["duration_ms"] = e.Duration.Milliseconds()
+		}
+		logger.InfoCF("exec", "Command audit", fields)
+	})
+	if execEnabled {
+		toolsRegistry.Register(execTool)
+		logger.InfoCF("agent", "Exec guard policy", map[string]interface{}{
+			"guard_enabled":         execTool.GuardEnabled(),
+			"restrict_to_workspace": execTool.IsRestrictedToWorkspace(),
+			"deny_patterns":         execTool.ListDenyPatterns(),
+			"warn_patterns":         execTool.ListWarnPatterns(),
+			"allow_patterns":        execTool.ListAllowPatterns(),
+		})
+	}
 
 	braveAPIKey := cfg.Tools.Web.Search.APIKey
 	toolsRegistry.Register(tools.NewWebSearchTool(braveAPIKey, cfg.Tools.Web.Search.MaxResults))
-	toolsRegistry.Register(tools.NewWebFetchTool(50000))
+	webFetchTool := tools.NewWebFetchTool(50000)
+	webFetchTool.SetAllowPrivateNetworks(cfg.Tools.Web.Fetch.AllowPrivateNetworks)
+	toolsRegistry.Register(webFetchTool)
 
 	// Register message tool
 	messageTool := tools.NewMessageTool()
@@ -73,47 +177,116 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	// Register edit file tool
 	editFileTool := tools.NewEditFileTool("")
 	toolsRegistry.Register(editFileTool)
+	toolsRegistry.Register(tools.NewEditLinesTool(""))
 
 	sessionsManager := session.NewSessionManager(filepath.Join(filepath.Dir(cfg.WorkspacePath()), "sessions"))
 
 	// Initialize MemDB client if enabled
 	var memdbClient *memory.MemDBClient
 	if cfg.Memory.MemDB.Enabled {
-		memdbClient = memory.NewMemDBClient(memory.MemDBConfig{
+		memdbCfg := memory.MemDBConfig{
 			Enabled: cfg.Memory.MemDB.Enabled,
 			URL:     cfg.Memory.MemDB.URL,
 			UserID:  cfg.Memory.MemDB.UserID,
 			CubeID:  cfg.Memory.MemDB.CubeID,
 			Secret:  cfg.Memory.MemDB.Secret,
-		})
-		if memdbClient.Health(context.Background()) {
-			logger.InfoCF("agent", "MemDB connected", map[string]interface{}{
-				"url": cfg.Memory.MemDB.URL,
+
+			Gzip:         cfg.Memory.MemDB.Gzip,
+			GzipMinBytes: cfg.Memory.MemDB.GzipMinBytes,
+			Debug:        cfg.Memory.MemDB.Debug,
+			RedactFields: cfg.Memory.MemDB.RedactFields,
+			TopK:         cfg.Memory.MemDB.TopK,
+			Relativity:   cfg.Memory.MemDB.Relativity,
+			MaxItemChars: cfg.Memory.MemDB.MaxItemChars,
+			StoreMode:    cfg.Memory.MemDB.StoreMode,
+			TLSCertFile:  cfg.Memory.MemDB.TLSCertFile,
+			TLSKeyFile:   cfg.Memory.MemDB.TLSKeyFile,
+			TLSCAFile:    cfg.Memory.MemDB.TLSCAFile,
+
+			DownCooldownSeconds: cfg.Memory.MemDB.DownCooldownSeconds,
+			RecencyHalfLife:     time.Duration(cfg.Memory.MemDB.RecencyHalfLifeDays * float64(24*time.Hour)),
+
+			PathPrefix: cfg.Memory.MemDB.PathPrefix,
+			SearchPath: cfg.Memory.MemDB.SearchPath,
+			AddPath:    cfg.Memory.MemDB.AddPath,
+			HealthPath: cfg.Memory.MemDB.HealthPath,
+		}
+		switch {
+		case cfg.Memory.MemDB.SecretFile != "":
+			memdbCfg.SecretProvider = memory.FileSecret(cfg.Memory.MemDB.SecretFile)
+		case cfg.Memory.MemDB.SecretEnv != "":
+			memdbCfg.SecretProvider = memory.EnvSecret(cfg.Memory.MemDB.SecretEnv)
+		}
+		if err := memdbCfg.LoadTLSConfig(); err != nil {
+			logger.ErrorCF("agent", "Invalid MemDB TLS settings, disabling", map[string]interface{}{
+				"error": err.Error(),
 			})
 		} else {
-			logger.ErrorCF("agent", "MemDB unreachable, disabling", map[string]interface{}{
-				"url": cfg.Memory.MemDB.URL,
-			})
-			memdbClient = nil
+			memdbClient = memory.NewMemDBClient(memdbCfg)
+			if memdbClient.WaitHealthy(context.Background(), 500*time.Millisecond, 3*time.Second) {
+				logger.InfoCF("agent", "MemDB connected", map[string]interface{}{
+					"url": cfg.Memory.MemDB.URL,
+				})
+			} else {
+				logger.ErrorCF("agent", "MemDB unreachable, disabling", map[string]interface{}{
+					"url": cfg.Memory.MemDB.URL,
+				})
+				memdbClient = nil
+			}
 		}
 	}
 
+	var storePolicy *memory.StorePolicy
+	if memdbClient != nil {
+		storePolicy = memory.NewStorePolicy(memdbClient,
+			cfg.Memory.MemDB.StoreEveryTurns,
+			time.Duration(cfg.Memory.MemDB.StoreIntervalSeconds)*time.Second)
+		toolsRegistry.Register(tools.NewRememberTool(memdbClient))
+		toolsRegistry.Register(tools.NewRecallTool(memdbClient))
+	}
+
+	historyWindow := session.HistoryWindow{
+		MaxTurns:  cfg.Agents.Defaults.MaxHistoryTurns,
+		MaxTokens: cfg.Agents.Defaults.MaxHistoryTokens,
+	}
+
+	maxIterations := cfg.Agents.Defaults.MaxToolIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxToolIterations
+	}
+
+	contextBuilder := NewContextBuilder(workspace, func() []string { return toolsRegistry.GetSummaries() })
+	contextBuilder.SetChannelPrompts(cfg.Channels.SystemPrompts())
+
 	return &AgentLoop{
-		bus:            msgBus,
-		provider:       provider,
-		workspace:      workspace,
-		model:          cfg.Agents.Defaults.Model,
-		maxIterations:  cfg.Agents.Defaults.MaxToolIterations,
-		sessions:       sessionsManager,
-		contextBuilder: NewContextBuilder(workspace, func() []string { return toolsRegistry.GetSummaries() }),
-		tools:          toolsRegistry,
-		memdb:          memdbClient,
+		bus:              msgBus,
+		provider:         provider,
+		workspace:        workspace,
+		model:            cfg.Agents.Defaults.Model,
+		maxIterations:    maxIterations,
+		maxParallelTools: cfg.Agents.Defaults.MaxParallelTools,
+		sessions:         sessionsManager,
+		history:          session.NewHistory(sessionsManager, historyWindow),
+		contextBuilder:   contextBuilder,
+		tools:            toolsRegistry,
+		memdb:            memdbClient,
+		storePolicy:      storePolicy,
+		memdbPerUser:     cfg.Memory.MemDB.PerUser,
+		thinkingBudget:   cfg.Agents.Defaults.ThinkingBudget,
+		googleSearch:     cfg.Agents.Defaults.GoogleSearch,
+		llmTimeout:       time.Duration(cfg.Agents.Defaults.LLMTimeoutSeconds) * time.Second,
+		modelPrices:      modelPrices(cfg.Agents.Defaults.ModelPrices),
+		maxRequestCost:   cfg.Agents.Defaults.MaxRequestCostUSD,
 	}
 }
 
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
 
+	if al.storePolicy != nil {
+		go al.storePolicy.Run(ctx)
+	}
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -124,17 +297,27 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
+			stopTyping := func() {}
+			if al.typing != nil && msg.Channel != "system" {
+				stopTyping = al.typing(ctx, msg.Channel, msg.ChatID)
+			}
 			response, err := al.processMessage(ctx, msg)
+			al.bus.InboundDone(msg)
+			stopTyping()
 			if err != nil {
 				response = fmt.Sprintf("Error processing message: %v", err)
 			}
 
 			if response != "" {
-				al.bus.PublishOutbound(bus.OutboundMessage{
+				out := bus.OutboundMessage{
 					Channel: msg.Channel,
 					ChatID:  msg.ChatID,
 					Content: response,
-				})
+				}
+				if id := msg.Metadata["message_id"]; id != "" {
+					out.IdempotencyKey = fmt.Sprintf("reply:%s:%s", msg.ChatID, id)
+				}
+				al.bus.PublishOutbound(out)
 			}
 		}
 	}
@@ -142,8 +325,21 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 	return nil
 }
 
+// TypingFunc starts a typing indicator for a chat and returns a function that
+// stops it.
+type TypingFunc func(ctx context.Context, channel, chatID string) (stop func())
+
+// SetTypingFunc installs the hook used to show a typing indicator while a
+// message is being processed.
+func (al *AgentLoop) SetTypingFunc(fn TypingFunc) {
+	al.typing = fn
+}
+
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+	if al.storePolicy != nil {
+		al.storePolicy.Flush(context.Background())
+	}
 }
 
 func (al *AgentLoop) ProcessDirect(ctx context.Context, content, sessionKey string) (string, error) {
@@ -159,21 +355,26 @@ func (al *AgentLoop) ProcessDirect(ctx context.Context, content, sessionKey stri
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	ctx = logger.With(ctx, map[string]interface{}{
+		"session_key": msg.SessionKey,
+		"channel":     msg.Channel,
+		"chat_id":     msg.ChatID,
+		"sender_id":   msg.SenderID,
+	})
+
 	// Add message preview to log
 	preview := truncate(msg.Content, 80)
-	logger.InfoCF("agent", fmt.Sprintf("Processing message from %s:%s: %s", msg.Channel, msg.SenderID, preview),
-		map[string]interface{}{
-			"channel":     msg.Channel,
-			"chat_id":     msg.ChatID,
-			"sender_id":   msg.SenderID,
-			"session_key": msg.SessionKey,
-		})
+	logger.InfoCtx(ctx, "agent", fmt.Sprintf("Processing message from %s:%s: %s", msg.Channel, msg.SenderID, preview), nil)
 
 	// Route system messages to processSystemMessage
 	if msg.Channel == "system" {
 		return al.processSystemMessage(ctx, msg)
 	}
 
+	// One turn at a time per conversation, however the message arrived.
+	unlock := al.bus.LockSession(msg.SessionKey)
+	defer unlock()
+
 	// Update tool contexts
 	if tool, ok := al.tools.Get("message"); ok {
 		if mt, ok := tool.(*tools.MessageTool); ok {
@@ -185,67 +386,64 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			st.SetContext(msg.Channel, msg.ChatID)
 		}
 	}
+	if tool, ok := al.tools.Get("remember"); ok {
+		if rt, ok := tool.(*tools.RememberTool); ok {
+			rt.SetUserID(al.memoryUserID(msg))
+		}
+	}
+	if tool, ok := al.tools.Get("recall"); ok {
+		if rt, ok := tool.(*tools.RecallTool); ok {
+			rt.SetUserID(al.memoryUserID(msg))
+		}
+	}
 
 	// Search MemDB for relevant memories
-	var memdbContext string
+	var memories *memory.SearchResult
 	if al.memdb != nil {
-		searchResult, err := al.memdb.Search(ctx, msg.Content)
+		searchResult, err := al.memdb.SearchForUser(ctx, al.memoryUserID(msg), msg.Content)
 		if err != nil {
-			logger.ErrorCF("memdb", "search failed", map[string]interface{}{"error": err.Error()})
-		} else {
-			memdbContext = searchResult.FormatForPrompt()
-			if memdbContext != "" {
-				total := len(searchResult.TextMemories) + len(searchResult.SkillMemories) + len(searchResult.PrefMemories)
-				logger.InfoCF("memdb", "injecting memories", map[string]interface{}{
-					"text":  len(searchResult.TextMemories),
-					"skill": len(searchResult.SkillMemories),
-					"pref":  len(searchResult.PrefMemories),
-					"total": total,
-				})
-			}
+			logger.ErrorCtx(ctx, "memdb", "search failed", map[string]interface{}{"error": err.Error()})
+		} else if total := searchResult.Total(); total > 0 {
+			memories = searchResult
+			logger.InfoCtx(ctx, "memdb", "injecting memories", map[string]interface{}{
+				"text":  len(searchResult.TextMemories),
+				"skill": len(searchResult.SkillMemories),
+				"pref":  len(searchResult.PrefMemories),
+				"total": total,
+			})
 		}
 	}
 
-	history := al.sessions.GetHistory(msg.SessionKey)
+	history := al.history.Messages(msg.SessionKey)
 	summary := al.sessions.GetSummary(msg.SessionKey)
 
 	messages := al.contextBuilder.BuildMessages(
 		history,
 		summary,
 		msg.Content,
-		nil,
+		loadAttachments(ctx, msg.Media),
 		msg.Channel,
 		msg.ChatID,
-		memdbContext,
+		memories,
 	)
 
 	iteration := 0
-	var finalContent string
+	var finalContent, lastContent string
+	answered := false
 
 	for iteration < al.maxIterations {
 		iteration++
 
-		logger.DebugCF("agent", "LLM iteration",
+		logger.DebugCtx(ctx, "agent", "LLM iteration",
 			map[string]interface{}{
 				"iteration": iteration,
 				"max":       al.maxIterations,
 			})
 
-		toolDefs := al.tools.GetDefinitions()
-		providerToolDefs := make([]providers.ToolDefinition, 0, len(toolDefs))
-		for _, td := range toolDefs {
-			providerToolDefs = append(providerToolDefs, providers.ToolDefinition{
-				Type: td["type"].(string),
-				Function: providers.ToolFunctionDefinition{
-					Name:        td["function"].(map[string]interface{})["name"].(string),
-					Description: td["function"].(map[string]interface{})["description"].(string),
-					Parameters:  td["function"].(map[string]interface{})["parameters"].(map[string]interface{}),
-				},
-			})
-		}
+		providerToolDefs := al.tools.ProviderDefinitions()
 
 		// Log LLM request details
-		logger.DebugCF("agent", "LLM request",
+		logger.DebugCtx(ctx, "agent", "LLM request",
 			map[string]interface{}{
 				"iteration":        iteration,
 				"model":            al.model,
@@ -257,20 +455,17 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			})
 
 		// Log full messages (detailed)
-		logger.DebugCF("agent", "Full LLM request",
+		logger.DebugCtx(ctx, "agent", "Full LLM request",
 			map[string]interface{}{
 				"iteration":     iteration,
 				"messages_json": formatMessagesForLog(messages),
 				"tools_json":    formatToolsForLog(providerToolDefs),
 			})
 
-		response, err := al.provider.Chat(ctx, messages, providerToolDefs, al.model, map[string]interface{}{
-			"max_tokens":  8192,
-			"temperature": 0.7,
-		})
+		response, err := al.chat(ctx, msg.SessionKey, &messages, providerToolDefs)
 
 		if err != nil {
-			logger.ErrorCF("agent", "LLM call failed",
+			logger.ErrorCtx(ctx, "agent", "LLM call failed",
 				map[string]interface{}{
 					"iteration": iteration,
 					"error":     err.Error(),
@@ -278,69 +473,55 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 			return "", fmt.Errorf("LLM call failed: %w", err)
 		}
 
+		al.recordUsage(msg.SessionKey, response.Usage)
+
 		if len(response.ToolCalls) == 0 {
-			finalContent = response.Content
-			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
+			finalContent = response.Content + response.Grounding.Citations()
+			finishReason := response.RawFinishReason
+			if finishReason == "" {
+				finishReason = response.FinishReason
+			}
+			logger.InfoCtx(ctx, "agent", "LLM response without tool calls (direct answer)",
 				map[string]interface{}{
 					"iteration":     iteration,
 					"content_chars": len(finalContent),
+					"finish_reason": finishReason,
 				})
+			answered = true
 			break
 		}
+		lastContent = response.Content
 
 		toolNames := make([]string, 0, len(response.ToolCalls))
 		for _, tc := range response.ToolCalls {
 			toolNames = append(toolNames, tc.Name)
 		}
-		logger.InfoCF("agent", "LLM requested tool calls",
+		logger.InfoCtx(ctx, "agent", "LLM requested tool calls",
 			map[string]interface{}{
 				"tools":     toolNames,
 				"count":     len(toolNames),
 				"iteration": iteration,
 			})
 
-		assistantMsg := providers.Message{
-			Role:    "assistant",
-			Content: response.Content,
-		}
-
-		for _, tc := range response.ToolCalls {
-			argumentsJSON, _ := json.Marshal(tc.Arguments)
-			assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, providers.ToolCall{
-				ID:   tc.ID,
-				Type: "function",
-				Function: &providers.FunctionCall{
-					Name:      tc.Name,
-					Arguments: string(argumentsJSON),
-				},
-			})
-		}
-		messages = append(messages, assistantMsg)
+		messages = append(messages, providers.NewAssistantToolCalls(response.Content, response.ToolCalls))
 
 		for _, tc := range response.ToolCalls {
 			// Log tool call with arguments preview
 			argsJSON, _ := json.Marshal(tc.Arguments)
 			argsPreview := truncate(string(argsJSON), 200)
-			logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+			logger.InfoCtx(ctx, "agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
 				map[string]interface{}{
 					"tool":       tc.Name,
 					"iteration":  iteration,
 				})
-
-			result, err := al.tools.Execute(ctx, tc.Name, tc.Arguments)
-			if err != nil {
-				result = fmt.Sprintf("Error: %v", err)
-			}
-
-			toolResultMsg := providers.Message{
-				Role:       "tool",
-				Content:    result,
-				ToolCallID: tc.ID,
-			}
-			messages = append(messages, toolResultMsg)
 		}
+
+		messages = append(messages, al.executeToolCalls(ctx, response.ToolCalls)...)
 	}
 
+	if !answered {
+		finalContent = al.iterationLimitReply(ctx, lastContent, iteration)
+	}
 	if finalContent == "" {
 		finalContent = "I've completed processing but have no response to give."
 	}
@@ -349,25 +530,40 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	al.sessions.AddMessage(msg.SessionKey, "assistant", finalContent)
 	al.sessions.Save(al.sessions.GetOrCreate(msg.SessionKey))
 
-	// Async store to MemDB
-	if al.memdb != nil {
-		go al.memdb.Store(context.Background(), []map[string]string{
-			{"role": "user", "content": msg.Content},
-			{"role": "assistant", "content": finalContent},
-		})
+	// Buffer the turn for MemDB; the store policy decides when to send it
+	if al.storePolicy != nil {
+		al.storePolicy.Add(context.Background(), msg.SessionKey, al.memoryUserID(msg),
+			map[string]string{"role": "user", "content": msg.Content},
+			map[string]string{"role": "assistant", "content": finalContent},
+		)
 	}
 
 	// Log response preview
 	responsePreview := truncate(finalContent, 120)
-	logger.InfoCF("agent", fmt.Sprintf("Response to %s:%s: %s", msg.Channel, msg.SenderID, responsePreview),
+	logger.InfoCtx(ctx, "agent", fmt.Sprintf("Response to %s:%s: %s", msg.Channel, msg.SenderID, responsePreview),
 		map[string]interface{}{
-			"iterations":   iteration,
-			"final_length": len(finalContent),
+			"iterations":    iteration,
+			"final_length":  len(finalContent),
+			"limit_reached": !answered,
 		})
 
 	return finalContent, nil
 }
 
+// memoryUserID returns the MemDB user to read and write memories for msg, or
+// "" for the configured default when per-user memory is off. Senders without
+// an identity mapping are scoped to their channel, since the same ID on two
+// channels needn't be the same person.
+func (al *AgentLoop) memoryUserID(msg bus.InboundMessage) string {
+	if !al.memdbPerUser {
+		return ""
+	}
+	if msg.UserID != "" {
+		return msg.UserID
+	}
+	return msg.Channel + ":" + msg.SenderID
+}
+
 func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
 	// Verify this is a system message
 	if msg.Channel != "system" {
@@ -393,6 +589,8 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 
 	// Use the origin session for context
 	sessionKey := fmt.Sprintf("%s:%s", originChannel, originChatID)
+	unlock := al.bus.LockSession(sessionKey)
+	defer unlock()
 
 	// Update tool contexts to original channel/chatID
 	if tool, ok := al.tools.Get("message"); ok {
@@ -407,7 +605,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 	}
 
 	// Build messages with the announce content
-	history := al.sessions.GetHistory(sessionKey)
+	history := al.history.Messages(sessionKey)
 	summary := al.sessions.GetSummary(sessionKey)
 	messages := al.contextBuilder.BuildMessages(
 		history,
@@ -416,27 +614,17 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		nil,
 		originChannel,
 		originChatID,
-		"", // no memdb context for system messages
+		nil, // no memdb context for system messages
 	)
 
 	iteration := 0
-	var finalContent string
+	var finalContent, lastContent string
+	answered := false
 
 	for iteration < al.maxIterations {
 		iteration++
 
-		toolDefs := al.tools.GetDefinitions()
-		providerToolDefs := make([]providers.ToolDefinition, 0, len(toolDefs))
-		for _, td := range toolDefs {
-			providerToolDefs = append(providerToolDefs, providers.ToolDefinition{
-				Type: td["type"].(string),
-				Function: providers.ToolFunctionDefinition{
-					Name:        td["function"].(map[string]interface{})["name"].(string),
-					Description: td["function"].(map[string]interface{})["description"].(string),
-					Parameters:  td["function"].(map[string]interface{})["parameters"].(map[string]interface{}),
-				},
-			})
-		}
+		providerToolDefs := al.tools.ProviderDefinitions()
 
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
@@ -458,10 +646,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 				"tools_json":    formatToolsForLog(providerToolDefs),
 			})
 
-		response, err := al.provider.Chat(ctx, messages, providerToolDefs, al.model, map[string]interface{}{
-			"max_tokens":  8192,
-			"temperature": 0.7,
-		})
+		response, err := al.chat(ctx, sessionKey, &messages, providerToolDefs)
 
 		if err != nil {
 			logger.ErrorCF("agent", "LLM call failed in system message",
@@ -472,44 +657,23 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 			return "", fmt.Errorf("LLM call failed: %w", err)
 		}
 
+		al.recordUsage(sessionKey, response.Usage)
+
 		if len(response.ToolCalls) == 0 {
 			finalContent = response.Content
+			answered = true
 			break
 		}
+		lastContent = response.Content
 
-		assistantMsg := providers.Message{
-			Role:    "assistant",
-			Content: response.Content,
-		}
+		messages = append(messages, providers.NewAssistantToolCalls(response.Content, response.ToolCalls))
 
-		for _, tc := range response.ToolCalls {
-			argumentsJSON, _ := json.Marshal(tc.Arguments)
-			assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, providers.ToolCall{
-				ID:   tc.ID,
-				Type: "function",
-				Function: &providers.FunctionCall{
-					Name:      tc.Name,
-					Arguments: string(argumentsJSON),
-				},
-			})
-		}
-		messages = append(messages, assistantMsg)
-
-		for _, tc := range response.ToolCalls {
-			result, err := al.tools.Execute(ctx, tc.Name, tc.Arguments)
-			if err != nil {
-				result = fmt.Sprintf("Error: %v", err)
-			}
-
-			toolResultMsg := providers.Message{
-				Role:       "tool",
-				Content:    result,
-				ToolCallID: tc.ID,
-			}
-			messages = append(messages, toolResultMsg)
-		}
+		messages = append(messages, al.executeToolCalls(ctx, response.ToolCalls)...)
 	}
 
+	if !answered {
+		finalContent = al.iterationLimitReply(ctx, lastContent, iteration)
+	}
 	if finalContent == "" {
 		finalContent = "Background task completed."
 	}
@@ -521,13 +685,161 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 
 	logger.InfoCF("agent", "System message processing completed",
 		map[string]interface{}{
-			"iterations":   iteration,
-			"final_length": len(finalContent),
+			"iterations":    iteration,
+			"final_length":  len(finalContent),
+			"limit_reached": !answered,
 		})
 
 	return finalContent, nil
 }
 
+// iterationLimitReply is the reply for a turn that used up its tool
+// iterations without a final answer: the model's last text, if any, with a
+// note that it was cut short.
+func (al *AgentLoop) iterationLimitReply(ctx context.Context, lastContent string, iterations int) string {
+	logger.WarnCtx(ctx, "agent", "Tool iteration limit reached, stopping turn",
+		map[string]interface{}{
+			"iterations": iterations,
+			"max":        al.maxIterations,
+		})
+	note := fmt.Sprintf("(Stopped after %d tool iterations without a final answer.)", iterations)
+	if lastContent = strings.TrimSpace(lastContent); lastContent == "" {
+		return note
+	}
+	return lastContent + "\n\n" + note
+}
+
+// chat calls the provider. If the request overflows the model's context
+// window, the oldest half of the history is dropped and the call retried
+// once; *messages and the stored history of sessionKey are updated so later
+// iterations and turns use the trimmed history.
+func (al *AgentLoop) chat(ctx context.Context, sessionKey string, messages *[]providers.Message, toolDefs []providers.ToolDefinition) (*providers.LLMResponse, error) {
+	options := map[string]interface{}{
+		"max_tokens":  8192,
+		"temperature": 0.7,
+	}
+	if al.thinkingBudget != nil {
+		options["thinking_budget"] = *al.thinkingBudget
+	}
+	if al.googleSearch {
+		options["google_search"] = true
+	}
+
+	// Each attempt gets its own deadline, independent of time spent on tools.
+	call := func() (*providers.LLMResponse, error) {
+		if err := al.checkRequestCost(ctx, *messages, toolDefs); err != nil {
+			return nil, err
+		}
+		callCtx, span := tracing.Start(ctx, "provider.chat", map[string]interface{}{
+			"llm.model":    al.model,
+			"llm.messages": len(*messages),
+			"llm.tools":    len(toolDefs),
+		})
+		if al.llmTimeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(callCtx, al.llmTimeout)
+			defer cancel()
+		}
+		resp, err := al.provider.Chat(callCtx, *messages, toolDefs, al.model, options)
+		if resp != nil && resp.Usage != nil {
+			span.SetAttributes(map[string]interface{}{
+				"llm.prompt_tokens":     resp.Usage.PromptTokens,
+				"llm.completion_tokens": resp.Usage.CompletionTokens,
+			})
+		}
+		tracing.End(span, err)
+		return resp, err
+	}
+
+	response, err := call()
+	if err == nil || !errors.Is(err, providers.ErrContextLength) {
+		return response, err
+	}
+
+	trimmed, ok := session.ShrinkHistory(*messages)
+	if !ok {
+		return nil, err
+	}
+	logger.WarnCtx(ctx, "agent", "Context length exceeded, retrying with trimmed history",
+		map[string]interface{}{
+			"messages_before": len(*messages),
+			"messages_after":  len(trimmed),
+		})
+	*messages = trimmed
+	// The current turn isn't stored yet, so keep one turn fewer. Without
+	// this, every later turn would rebuild the history and overflow again.
+	al.history.KeepTurns(sessionKey, session.CountTurns(trimmed)-1)
+	return call()
+}
+
+// checkRequestCost refuses a call whose estimated input cost exceeds the
+// configured per-request limit.
+func (al *AgentLoop) checkRequestCost(ctx context.Context, messages []providers.Message, toolDefs []providers.ToolDefinition) error {
+	if al.maxRequestCost <= 0 {
+		return nil
+	}
+	est := providers.EstimateCost(ctx, al.provider, messages, toolDefs, al.model, al.modelPrices)
+	fields := map[string]interface{}{
+		"model":        al.model,
+		"input_tokens": est.InputTokens,
+		"exact":        est.Exact,
+		"cost_usd":     est.Cost,
+	}
+	if !est.Priced {
+		logger.DebugCtx(ctx, "agent", "No price configured for model, skipping cost check", fields)
+		return nil
+	}
+	if est.Cost > al.maxRequestCost {
+		logger.WarnCtx(ctx, "agent", "Request refused: estimated cost over limit", fields)
+		return fmt.Errorf("estimated request cost $%.4f (%d input tokens) exceeds limit of $%.4f", est.Cost, est.InputTokens, al.maxRequestCost)
+	}
+	logger.DebugCtx(ctx, "agent", "Estimated request cost", fields)
+	return nil
+}
+
+// modelPrices converts the configured price map for providers.EstimateCost.
+func modelPrices(prices map[string]config.ModelPrice) map[string]providers.ModelPrice {
+	if len(prices) == 0 {
+		return nil
+	}
+	out := make(map[string]providers.ModelPrice, len(prices))
+	for name, p := range prices {
+		out[name] = providers.ModelPrice{InputPerMillion: p.InputPerMillion, OutputPerMillion: p.OutputPerMillion}
+	}
+	return out
+}
+
+// executeToolCalls runs a turn's tool calls (independent ones in parallel)
+// and returns the tool result messages in call order.
+func (al *AgentLoop) executeToolCalls(ctx context.Context, calls []providers.ToolCall) []providers.Message {
+	results := al.tools.ExecuteToolCalls(ctx, calls, al.maxParallelTools)
+	msgs := make([]providers.Message, 0, len(results))
+	for _, r := range results {
+		content := r.Content
+		if r.Err != nil {
+			content = fmt.Sprintf("Error: %v", r.Err)
+		}
+		msgs = append(msgs, providers.NewToolResult(r.ID, content))
+	}
+	return msgs
+}
+
+// recordUsage adds a provider call's token usage to the session's running total.
+func (al *AgentLoop) recordUsage(sessionKey string, usage *providers.UsageInfo) {
+	v, _ := al.usage.LoadOrStore(sessionKey, providers.NewSessionUsage())
+	v.(*providers.SessionUsage).Add(usage)
+}
+
+// GetSessionUsage returns the cumulative token usage for a session, or nil if
+// no provider calls have been made for it yet.
+func (al *AgentLoop) GetSessionUsage(sessionKey string) *providers.SessionUsage {
+	v, ok := al.usage.Load(sessionKey)
+	if !ok {
+		return nil
+	}
+	return v.(*providers.SessionUsage)
+}
+
 // truncate returns a truncated version of s with at most maxLen characters.
 // If the string is truncated, "..." is appended to indicate truncation.
 // If the string fits within maxLen, it is returned unchanged.
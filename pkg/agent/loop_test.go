@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestMemoryUserID(t *testing.T) {
+	perUser := &AgentLoop{memdbPerUser: true}
+	tests := []struct {
+		name string
+		al   *AgentLoop
+		msg  bus.InboundMessage
+		want string
+	}{
+		{"shared memory", &AgentLoop{}, bus.InboundMessage{Channel: "telegram", SenderID: "42"}, ""},
+		{"mapped identity", perUser, bus.InboundMessage{Channel: "telegram", SenderID: "42", UserID: "alice"}, "alice"},
+		{"telegram sender", perUser, bus.InboundMessage{Channel: "telegram", SenderID: "42"}, "telegram:42"},
+		{"discord sender", perUser, bus.InboundMessage{Channel: "discord", SenderID: "42"}, "discord:42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.al.memoryUserID(tt.msg); got != tt.want {
+				t.Errorf("memoryUserID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// overflowProvider fails with a context length error while given more than
+// limit messages.
+type overflowProvider struct {
+	limit int
+	calls int
+}
+
+func (p *overflowProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, options map[string]interface{}) (*providers.LLMResponse, error) {
+	p.calls++
+	if len(messages) > p.limit {
+		return nil, fmt.Errorf("prompt too long: %w", providers.ErrContextLength)
+	}
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *overflowProvider) GetDefaultModel() string { return "test" }
+
+func TestChatStoresTrimmedHistory(t *testing.T) {
+	sessions := session.NewSessionManager("")
+	for i := 0; i < 4; i++ {
+		sessions.AddMessage("s", "user", fmt.Sprintf("q%d", i))
+		sessions.AddMessage("s", "assistant", fmt.Sprintf("a%d", i))
+	}
+	provider := &overflowProvider{limit: 6}
+	al := &AgentLoop{provider: provider, history: session.NewHistory(sessions, session.HistoryWindow{})}
+
+	messages := append([]providers.Message{{Role: "system", Content: "sys"}}, sessions.GetHistory("s")...)
+	messages = append(messages, providers.Message{Role: "user", Content: "q4"})
+	if _, err := al.chat(context.Background(), "s", &messages, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Five turns shrink to the latest three: q2, q3 and the current q4.
+	var stored []string
+	for _, m := range sessions.GetHistory("s") {
+		stored = append(stored, m.Content)
+	}
+	if got := strings.Join(stored, ","); got != "q2,a2,q3,a3" {
+		t.Errorf("stored history = %s, want the turns the retry kept", got)
+	}
+	if len(messages) != 6 || provider.calls != 2 {
+		t.Errorf("%d messages after %d calls, want 6 after 2", len(messages), provider.calls)
+	}
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// maxAttachmentSize caps each file sent inline to the model.
+const maxAttachmentSize = 10 << 20
+
+// mediaHTTPClient downloads media URLs. They come from chat messages, so
+// private and internal addresses are refused.
+var mediaHTTPClient = tools.NewPublicHTTPClient(30 * time.Second)
+
+// loadAttachments turns the media references on an inbound message (local
+// paths under channels.MediaDir, or http(s) URLs when a download failed
+// there) into image attachments for the provider. Other media types are
+// left to the text placeholders channels already add, e.g. "[voice
+// transcription: ...]". Media that can't be loaded is logged and skipped.
+func loadAttachments(ctx context.Context, media []string) []providers.Attachment {
+	var attachments []providers.Attachment
+	for _, ref := range media {
+		if ref == "" {
+			continue
+		}
+		data, err := readMedia(ctx, ref)
+		if err != nil {
+			logger.WarnCtx(ctx, "agent", "Skipping media attachment", map[string]interface{}{
+				"media": ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+
+		mimeType := mediaType(ref, data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			continue
+		}
+		attachments = append(attachments, providers.Attachment{MIMEType: mimeType, Data: data})
+	}
+	return attachments
+}
+
+func readMedia(ctx context.Context, ref string) ([]byte, error) {
+	var r io.Reader
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		req, err := http.NewRequestWithContext(ctx, "GET", ref, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := mediaHTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+		}
+		r = resp.Body
+	} else {
+		path, err := tools.ValidatePathResolved(ref, channels.MediaDir())
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAttachmentSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxAttachmentSize)
+	}
+	return data, nil
+}
+
+// mediaType guesses the MIME type from the file extension, falling back to
+// content sniffing.
+func mediaType(ref string, data []byte) string {
+	if ext := filepath.Ext(strings.SplitN(ref, "?", 2)[0]); ext != "" {
+		if t := mime.TypeByExtension(strings.ToLower(ext)); t != "" {
+			t, _, _ = strings.Cut(t, ";")
+			return t
+		}
+	}
+	return http.DetectContentType(data)
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// PromptBuilder composes the system message from a base prompt, extra named
+// sections and MemDB memories. Sections appear in the order added, with
+// memories last; empty and duplicate sections are dropped.
+type PromptBuilder struct {
+	base     string
+	sections []string
+	titles   map[string]bool
+	memories *memory.SearchResult
+}
+
+func NewPromptBuilder(base string) *PromptBuilder {
+	return &PromptBuilder{
+		base:   base,
+		titles: make(map[string]bool),
+	}
+}
+
+// AddSection appends a "## title" section. Empty bodies are skipped, and a
+// title that was already added keeps its first body.
+func (b *PromptBuilder) AddSection(title, body string) *PromptBuilder {
+	body = strings.TrimSpace(body)
+	if body == "" || b.titles[title] {
+		return b
+	}
+	b.titles[title] = true
+	b.sections = append(b.sections, fmt.Sprintf("## %s\n\n%s", title, body))
+	return b
+}
+
+// WithMemories sets the MemDB search result to include. Memories repeated
+// across categories, or already present verbatim in the base prompt (e.g.
+// from MEMORY.md), are left out.
+func (b *PromptBuilder) WithMemories(r *memory.SearchResult) *PromptBuilder {
+	b.memories = r
+	return b
+}
+
+// Build returns the assembled system prompt.
+func (b *PromptBuilder) Build() string {
+	parts := []string{}
+	if base := strings.TrimSpace(b.base); base != "" {
+		parts = append(parts, base)
+	}
+	parts = append(parts, b.sections...)
+
+	if b.memories != nil {
+		deduped := b.memories.Dedup()
+		deduped.Filter(func(m memory.MemoryItem) bool {
+			return !strings.Contains(b.base, strings.TrimSpace(m.Content))
+		})
+		if block := deduped.FormatForPrompt(); block != "" {
+			parts = append(parts, block)
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// Message returns the assembled prompt as a system message.
+func (b *PromptBuilder) Message() providers.Message {
+	return providers.Message{Role: "system", Content: b.Build()}
+}
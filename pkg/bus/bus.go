@@ -10,6 +10,7 @@ type MessageBus struct {
 	outbound chan OutboundMessage
 	handlers map[string]MessageHandler
 	mu       sync.RWMutex
+	sessions sessionState
 }
 
 func NewMessageBus() *MessageBus {
@@ -17,10 +18,17 @@ func NewMessageBus() *MessageBus {
 		inbound:  make(chan InboundMessage, 100),
 		outbound: make(chan OutboundMessage, 100),
 		handlers: make(map[string]MessageHandler),
+		sessions: sessionState{policy: BusyQueue},
 	}
 }
 
+// PublishInbound queues msg for the agent. Under BusyDrop, a message for a
+// session that still has an earlier one pending is discarded instead.
 func (mb *MessageBus) PublishInbound(msg InboundMessage) {
+	if !mb.sessions.admit(msg) {
+		logDropped(msg)
+		return
+	}
 	mb.inbound <- msg
 }
 
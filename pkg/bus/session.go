@@ -0,0 +1,131 @@
+package bus
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// BusyPolicy decides what happens to a message that arrives for a session
+// whose previous message is still queued or being processed.
+type BusyPolicy string
+
+const (
+	// BusyQueue processes the message once the earlier turns are done.
+	BusyQueue BusyPolicy = "queue"
+	// BusyDrop discards the message.
+	BusyDrop BusyPolicy = "drop"
+)
+
+// ValidateBusyPolicy returns an error for anything other than "queue",
+// "drop" or "" (queue).
+func ValidateBusyPolicy(policy string) error {
+	switch BusyPolicy(policy) {
+	case "", BusyQueue, BusyDrop:
+		return nil
+	}
+	return fmt.Errorf("invalid busy policy %q (want %q or %q)", policy, BusyQueue, BusyDrop)
+}
+
+// sessionState tracks, per SessionKey, how many inbound messages are queued
+// or in progress, and the lock that serializes their turns.
+type sessionState struct {
+	mu      sync.Mutex
+	policy  BusyPolicy
+	pending map[string]int
+	locks   map[string]*sessionLock
+}
+
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// admit records msg as pending, or reports false if the busy policy says to
+// drop it. System messages (e.g. subagent results) are always admitted.
+func (s *sessionState) admit(msg InboundMessage) bool {
+	if msg.SessionKey == "" {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy == BusyDrop && msg.Channel != "system" && s.pending[msg.SessionKey] > 0 {
+		return false
+	}
+	if s.pending == nil {
+		s.pending = make(map[string]int)
+	}
+	s.pending[msg.SessionKey]++
+	return true
+}
+
+func (s *sessionState) done(sessionKey string) {
+	if sessionKey == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[sessionKey] <= 1 {
+		delete(s.pending, sessionKey)
+		return
+	}
+	s.pending[sessionKey]--
+}
+
+func (s *sessionState) lock(sessionKey string) func() {
+	s.mu.Lock()
+	if s.locks == nil {
+		s.locks = make(map[string]*sessionLock)
+	}
+	l := s.locks[sessionKey]
+	if l == nil {
+		l = &sessionLock{}
+		s.locks[sessionKey] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.locks, sessionKey)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// SetBusyPolicy sets how PublishInbound treats a message for a session that
+// already has one queued or in progress (default BusyQueue).
+func (mb *MessageBus) SetBusyPolicy(policy BusyPolicy) {
+	mb.sessions.mu.Lock()
+	defer mb.sessions.mu.Unlock()
+	mb.sessions.policy = policy
+}
+
+// InboundDone marks a message returned by ConsumeInbound as fully
+// processed, so later messages for its session are no longer considered
+// follow-ups.
+func (mb *MessageBus) InboundDone(msg InboundMessage) {
+	mb.sessions.done(msg.SessionKey)
+}
+
+// LockSession blocks until no other turn holds sessionKey and returns the
+// function that releases it. Consumers hold it for the whole turn so that a
+// conversation processes one message at a time, whichever path it came in on.
+func (mb *MessageBus) LockSession(sessionKey string) (unlock func()) {
+	return mb.sessions.lock(sessionKey)
+}
+
+func logDropped(msg InboundMessage) {
+	logger.InfoCF("bus", "Dropped message for busy session", map[string]interface{}{
+		"session_key": msg.SessionKey,
+		"channel":     msg.Channel,
+		"sender_id":   msg.SenderID,
+	})
+}
@@ -8,12 +8,18 @@ type InboundMessage struct {
 	Media      []string          `json:"media,omitempty"`
 	SessionKey string            `json:"session_key"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
+	// UserID is the canonical identity of the sender, shared across
+	// channels. It is empty unless an identity mapping applies.
+	UserID string `json:"user_id,omitempty"`
 }
 
 type OutboundMessage struct {
 	Channel string `json:"channel"`
 	ChatID  string `json:"chat_id"`
 	Content string `json:"content"`
+	// IdempotencyKey, when set, lets channels drop duplicate sends of the
+	// same logical message (e.g. after a retry or restart).
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 type MessageHandler func(InboundMessage) error
@@ -0,0 +1,81 @@
+package channels
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// allowMatcher is a compiled allow_from entry. Entries are matched as:
+//   - "/expr/"       regular expression
+//   - "*@corp.com"   glob with * and ? wildcards
+//   - anything else  exact match (also against the id in "id|username")
+type allowMatcher struct {
+	exact string
+	re    *regexp.Regexp
+}
+
+func compileAllowList(channel string, allowList []string) []allowMatcher {
+	matchers := make([]allowMatcher, 0, len(allowList))
+	for _, entry := range allowList {
+		m, err := compileAllowEntry(entry)
+		if err != nil {
+			logger.WarnCF("channels", "Ignoring invalid allow_from entry", map[string]interface{}{
+				"channel": channel,
+				"entry":   entry,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers
+}
+
+func compileAllowEntry(entry string) (allowMatcher, error) {
+	if len(entry) >= 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+		re, err := regexp.Compile(entry[1 : len(entry)-1])
+		if err != nil {
+			return allowMatcher{}, err
+		}
+		return allowMatcher{re: re}, nil
+	}
+
+	if strings.ContainsAny(entry, "*?") {
+		pattern := regexp.QuoteMeta(entry)
+		pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+		pattern = strings.ReplaceAll(pattern, `\?`, ".")
+		re, err := regexp.Compile("^" + pattern + "$")
+		if err != nil {
+			return allowMatcher{}, err
+		}
+		return allowMatcher{re: re}, nil
+	}
+
+	return allowMatcher{exact: entry}, nil
+}
+
+// match reports whether senderID is allowed by this entry. Sender IDs of the
+// form "id|username" are also matched against each part separately.
+func (m allowMatcher) match(senderID string) bool {
+	if m.re == nil {
+		if senderID == m.exact {
+			return true
+		}
+		// Support "428660|username" matching against "428660"
+		return len(senderID) > len(m.exact) && senderID[:len(m.exact)] == m.exact && senderID[len(m.exact)] == '|'
+	}
+
+	if m.re.MatchString(senderID) {
+		return true
+	}
+	if strings.Contains(senderID, "|") {
+		for _, part := range strings.Split(senderID, "|") {
+			if part != "" && m.re.MatchString(part) {
+				return true
+			}
+		}
+	}
+	return false
+}
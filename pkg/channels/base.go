@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -18,15 +19,47 @@ type Channel interface {
 	IsAllowed(senderID string) bool
 }
 
+// TypingIndicator is implemented by channels that can show a "typing..."
+// status while the agent is working on a reply. The indicator is expected to
+// expire on its own after a few seconds, so callers refresh it periodically.
+type TypingIndicator interface {
+	SendTyping(ctx context.Context, chatID string) error
+}
+
 type BaseChannel struct {
-	config    interface{}
-	bus       *bus.MessageBus
-	running   bool
-	mu        sync.RWMutex
-	name      string
-	allowList []string
+	config      interface{}
+	bus         *bus.MessageBus
+	running     bool
+	mu          sync.RWMutex
+	name        string
+	allowList   []string
+	matchers    []allowMatcher
+	middlewares []Middleware
+	handler     InboundHandler
+	onReject    func(RejectEvent)
+	identity    func(senderID string) string
+	inflight    sync.WaitGroup
+	draining    bool
+}
+
+// defaultDrainTimeout bounds Drain when the caller's context has no deadline.
+const defaultDrainTimeout = 10 * time.Second
+
+// RejectEvent describes an inbound message dropped by the allow list. It
+// deliberately carries no message content.
+type RejectEvent struct {
+	Channel  string
+	SenderID string
+	ChatID   string
 }
 
+// InboundHandler processes an inbound message that passed the allow list.
+type InboundHandler func(msg bus.InboundMessage)
+
+// Middleware wraps an InboundHandler. A middleware can inspect or rewrite the
+// message before calling next, or drop it by not calling next at all.
+type Middleware func(next InboundHandler) InboundHandler
+
 func NewBaseChannel(name string, config interface{}, bus *bus.MessageBus, allowList []string) *BaseChannel {
 	if len(allowList) == 0 {
 		logger.WarnCF("channels", "allow_from is empty — all users can interact", map[string]interface{}{
@@ -34,13 +67,29 @@ func NewBaseChannel(name string, config interface{}, bus *bus.MessageBus, allowL
 		})
 	}
 
-	return &BaseChannel{
+	c := &BaseChannel{
 		config:    config,
 		bus:       bus,
 		name:      name,
 		allowList: allowList,
+		matchers:  compileAllowList(name, allowList),
 		running:   false,
 	}
+	c.handler = c.publish
+	return c
+}
+
+// Use appends middlewares to the inbound chain. They run in the order given,
+// after the allow-list check and before the message is published to the bus.
+func (c *BaseChannel) Use(middlewares ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares = append(c.middlewares, middlewares...)
+	handler := InboundHandler(c.publish)
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i](handler)
+	}
+	c.handler = handler
 }
 
 func (c *BaseChannel) Name() string {
@@ -58,12 +107,8 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 		return true
 	}
 
-	for _, allowed := range c.allowList {
-		if senderID == allowed {
-			return true
-		}
-		// Support "428660|username" matching against "428660"
-		if len(senderID) > len(allowed) && senderID[:len(allowed)] == allowed && senderID[len(allowed)] == '|' {
+	for _, m := range c.matchers {
+		if m.match(senderID) {
 			return true
 		}
 	}
@@ -71,8 +116,79 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 	return false
 }
 
+// Start marks the channel as running. Channels that embed BaseChannel call it
+// from their own Start once they are connected.
+func (c *BaseChannel) Start(ctx context.Context) error {
+	c.setRunning(true)
+	return nil
+}
+
+// Stop stops accepting new inbound messages, waits for in-flight ones to
+// finish (see Drain) and marks the channel as stopped. Channels that embed
+// BaseChannel call it from their own Stop before closing connections.
+func (c *BaseChannel) Stop(ctx context.Context) error {
+	err := c.Drain(ctx)
+	if err != nil {
+		logger.WarnCF("channels", "Channel stopped before in-flight messages drained", map[string]interface{}{
+			"channel": c.name,
+			"error":   err.Error(),
+		})
+	}
+	c.setRunning(false)
+	return err
+}
+
+// Track runs fn as an in-flight unit of inbound work that Drain waits for.
+// Channels wrap each received message's handling (download, transcription,
+// HandleMessage) in Track. It returns false without running fn once the
+// channel is draining.
+func (c *BaseChannel) Track(fn func()) bool {
+	c.mu.RLock()
+	if c.draining {
+		c.mu.RUnlock()
+		logger.DebugCF("channels", "Dropping inbound message while channel is stopping", map[string]interface{}{
+			"channel": c.name,
+		})
+		return false
+	}
+	c.inflight.Add(1)
+	c.mu.RUnlock()
+
+	defer c.inflight.Done()
+	fn()
+	return true
+}
+
+// Drain rejects new inbound messages and waits for in-flight ones to finish,
+// up to ctx's deadline (or defaultDrainTimeout if it has none).
+func (c *BaseChannel) Drain(ctx context.Context) error {
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultDrainTimeout)
+		defer cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("drain %s channel: %w", c.name, ctx.Err())
+	}
+}
+
 func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []string, metadata map[string]string) {
 	if !c.IsAllowed(senderID) {
+		c.reject(senderID, chatID)
 		return
 	}
 
@@ -81,6 +197,7 @@ func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []st
 	msg := bus.InboundMessage{
 		Channel:    c.name,
 		SenderID:   senderID,
+		UserID:     c.ResolveIdentity(senderID),
 		ChatID:     chatID,
 		Content:    content,
 		Media:      media,
@@ -88,6 +205,60 @@ func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []st
 		SessionKey: sessionKey,
 	}
 
+	c.mu.RLock()
+	handler := c.handler
+	c.mu.RUnlock()
+
+	handler(msg)
+}
+
+// SetIdentityResolver installs a function that maps a channel-native sender
+// ID to a canonical user identity, used as InboundMessage.UserID. Without
+// one, or when it returns "", UserID is left empty.
+func (c *BaseChannel) SetIdentityResolver(fn func(senderID string) string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.identity = fn
+}
+
+// ResolveIdentity returns the canonical user identity for senderID, or "" if
+// no mapping applies.
+func (c *BaseChannel) ResolveIdentity(senderID string) string {
+	c.mu.RLock()
+	resolve := c.identity
+	c.mu.RUnlock()
+
+	if resolve == nil {
+		return ""
+	}
+	return resolve(senderID)
+}
+
+// SetRejectHandler registers a callback invoked whenever a message is dropped
+// by the allow list.
+func (c *BaseChannel) SetRejectHandler(fn func(RejectEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onReject = fn
+}
+
+func (c *BaseChannel) reject(senderID, chatID string) {
+	logger.WarnCF("channels", "Message rejected by allow list", map[string]interface{}{
+		"channel":   c.name,
+		"sender_id": senderID,
+		"chat_id":   chatID,
+	})
+
+	c.mu.RLock()
+	onReject := c.onReject
+	c.mu.RUnlock()
+
+	if onReject != nil {
+		onReject(RejectEvent{Channel: c.name, SenderID: senderID, ChatID: chatID})
+	}
+}
+
+func (c *BaseChannel) publish(msg bus.InboundMessage) {
 	c.bus.PublishInbound(msg)
 }
 
@@ -95,4 +266,7 @@ func (c *BaseChannel) setRunning(running bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.running = running
+	if running {
+		c.draining = false
+	}
 }
@@ -0,0 +1,45 @@
+package channels
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultDedupTTL is how long a sent idempotency key is remembered.
+const defaultDedupTTL = 10 * time.Minute
+
+// sendDedup remembers recently sent idempotency keys so a retried or
+// re-published outbound message is delivered only once.
+type sendDedup struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	sent map[string]time.Time
+}
+
+func newSendDedup(ttl time.Duration) *sendDedup {
+	return &sendDedup{
+		ttl:  ttl,
+		sent: make(map[string]time.Time),
+	}
+}
+
+// seen reports whether key was marked within the TTL window.
+func (d *sendDedup) seen(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	at, ok := d.sent[key]
+	return ok && time.Since(at) < d.ttl
+}
+
+// mark records key as sent and drops expired entries.
+func (d *sendDedup) mark(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for k, at := range d.sent {
+		if now.Sub(at) >= d.ttl {
+			delete(d.sent, k)
+		}
+	}
+	d.sent[key] = now
+}
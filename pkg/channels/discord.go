@@ -48,7 +48,9 @@ func (c *DiscordChannel) SetTranscriber(transcriber *voice.GroqTranscriber) {
 func (c *DiscordChannel) Start(ctx context.Context) error {
 	logger.InfoC("discord", "Starting Discord bot")
 
-	c.session.AddHandler(c.handleMessage)
+	c.session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		c.Track(func() { c.handleMessage(s, m) })
+	})
 
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
@@ -70,7 +72,7 @@ func (c *DiscordChannel) Start(ctx context.Context) error {
 
 func (c *DiscordChannel) Stop(ctx context.Context) error {
 	logger.InfoC("discord", "Stopping Discord bot")
-	c.setRunning(false)
+	c.BaseChannel.Stop(ctx)
 
 	if err := c.session.Close(); err != nil {
 		return fmt.Errorf("failed to close discord session: %w", err)
@@ -98,6 +100,19 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 	return nil
 }
 
+// SendTyping triggers Discord's typing indicator, which lasts about ten
+// seconds or until a message is sent.
+func (c *DiscordChannel) SendTyping(ctx context.Context, chatID string) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("discord bot not running")
+	}
+	if chatID == "" {
+		return fmt.Errorf("channel ID is empty")
+	}
+
+	return c.session.ChannelTyping(chatID)
+}
+
 func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if m == nil || m.Author == nil {
 		return
@@ -208,7 +223,7 @@ func isAudioFile(filename, contentType string) bool {
 }
 
 func (c *DiscordChannel) downloadAttachment(url, filename string) string {
-	mediaDir := filepath.Join(os.TempDir(), "picoclaw_media")
+	mediaDir := MediaDir()
 	if err := os.MkdirAll(mediaDir, 0755); err != nil {
 		log.Printf("Failed to create media directory: %v", err)
 		return ""
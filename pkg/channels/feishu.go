@@ -2,6 +2,8 @@ package channels
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -43,7 +45,11 @@ func (c *FeishuChannel) Start(ctx context.Context) error {
 	}
 
 	dispatcher := larkdispatcher.NewEventDispatcher(c.config.VerificationToken, c.config.EncryptKey).
-		OnP2MessageReceiveV1(c.handleMessageReceive)
+		OnP2MessageReceiveV1(func(ctx context.Context, event *larkim.P2MessageReceiveV1) error {
+			var err error
+			c.Track(func() { err = c.handleMessageReceive(ctx, event) })
+			return err
+		})
 
 	runCtx, cancel := context.WithCancel(ctx)
 
@@ -72,6 +78,8 @@ func (c *FeishuChannel) Start(ctx context.Context) error {
 }
 
 func (c *FeishuChannel) Stop(ctx context.Context) error {
+	c.BaseChannel.Stop(ctx)
+
 	c.mu.Lock()
 	if c.cancel != nil {
 		c.cancel()
@@ -80,7 +88,6 @@ func (c *FeishuChannel) Stop(ctx context.Context) error {
 	c.wsClient = nil
 	c.mu.Unlock()
 
-	c.setRunning(false)
 	logger.InfoC("feishu", "Feishu channel stopped")
 	return nil
 }
@@ -105,7 +112,7 @@ func (c *FeishuChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 			ReceiveId(msg.ChatID).
 			MsgType(larkim.MsgTypeText).
 			Content(string(payload)).
-			Uuid(fmt.Sprintf("picoclaw-%d", time.Now().UnixNano())).
+			Uuid(feishuMessageUUID(msg)).
 			Build()).
 		Build()
 
@@ -125,6 +132,17 @@ func (c *FeishuChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	return nil
 }
 
+// feishuMessageUUID returns the uuid Feishu uses to dedupe sends for an hour.
+// It is derived from the idempotency key when there is one (uuid is capped at
+// 50 characters, so the key is hashed).
+func feishuMessageUUID(msg bus.OutboundMessage) string {
+	if msg.IdempotencyKey == "" {
+		return fmt.Sprintf("picoclaw-%d", time.Now().UnixNano())
+	}
+	sum := sha256.Sum256([]byte(msg.ChatID + ":" + msg.IdempotencyKey))
+	return "picoclaw-" + hex.EncodeToString(sum[:16])
+}
+
 func (c *FeishuChannel) handleMessageReceive(_ context.Context, event *larkim.P2MessageReceiveV1) error {
 	if event == nil || event.Event == nil || event.Event.Message == nil {
 		return nil
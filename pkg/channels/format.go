@@ -0,0 +1,234 @@
+package channels
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Formatter converts a reply from the Markdown the model writes into a
+// channel's native markup before it is sent.
+type Formatter func(markdown string) string
+
+// PassthroughFormatter sends Markdown unchanged. It is the default for
+// channels without a formatter.
+func PassthroughFormatter(markdown string) string {
+	return markdown
+}
+
+// Format names accepted in the channels.formats config.
+const (
+	FormatMarkdown = "markdown"
+	FormatSlack    = "slack"
+)
+
+// formattersFromConfig picks each channel's formatter from the config.
+// Channels left out get PassthroughFormatter.
+func formattersFromConfig(cfg config.ChannelsConfig) map[string]Formatter {
+	formatters := make(map[string]Formatter)
+	for name, format := range cfg.Formats {
+		switch strings.ToLower(format) {
+		case "", FormatMarkdown:
+		case FormatSlack:
+			formatters[name] = MarkdownToSlack
+		default:
+			logger.WarnCF("channels", "Unknown reply format, sending Markdown", map[string]interface{}{
+				"channel": name,
+				"format":  format,
+			})
+		}
+	}
+	// Telegram's parse mode has to match the markup it is sent.
+	formatters["telegram"] = TelegramFormatter(cfg.Telegram)
+	return formatters
+}
+
+// markupStyle describes how a target markup renders each Markdown construct.
+// Inner text passed to bold, italic, strike and link has already been
+// converted.
+type markupStyle struct {
+	escape  func(string) string
+	code    func(string) string
+	pre     func(lang, code string) string
+	bold    func(string) string
+	italic  func(string) string
+	strike  func(string) string
+	link    func(text, url string) string
+	heading func(string) string
+	bullet  string
+	quote   string
+}
+
+// MarkdownToTelegramV2 converts Markdown to Telegram's MarkdownV2, escaping
+// every reserved character outside entities as the Bot API requires.
+func MarkdownToTelegramV2(markdown string) string {
+	return convertMarkdown(markdown, telegramV2Style)
+}
+
+// MarkdownToSlack converts Markdown to Slack mrkdwn.
+func MarkdownToSlack(markdown string) string {
+	return convertMarkdown(markdown, slackStyle)
+}
+
+var telegramV2Reserved = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`,
+	"=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// Inside code entities only ` and \ are escaped; inside a link URL, ) and \.
+var (
+	telegramV2Code = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	telegramV2URL  = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+var telegramV2Style = markupStyle{
+	escape: telegramV2Reserved.Replace,
+	code:   func(s string) string { return "`" + telegramV2Code.Replace(s) + "`" },
+	pre: func(lang, code string) string {
+		return "```" + lang + "\n" + telegramV2Code.Replace(code) + "```"
+	},
+	bold:    func(s string) string { return "*" + s + "*" },
+	italic:  func(s string) string { return "_" + s + "_" },
+	strike:  func(s string) string { return "~" + s + "~" },
+	link:    func(text, url string) string { return "[" + text + "](" + telegramV2URL.Replace(url) + ")" },
+	heading: func(s string) string { return "*" + s + "*" },
+	bullet:  "• ",
+	quote:   ">",
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var slackStyle = markupStyle{
+	escape:  slackEscaper.Replace,
+	code:    func(s string) string { return "`" + slackEscaper.Replace(s) + "`" },
+	pre:     func(_, code string) string { return "```\n" + slackEscaper.Replace(code) + "```" },
+	bold:    func(s string) string { return "*" + s + "*" },
+	italic:  func(s string) string { return "_" + s + "_" },
+	strike:  func(s string) string { return "~" + s + "~" },
+	link:    func(text, url string) string { return "<" + url + "|" + text + ">" },
+	heading: func(s string) string { return "*" + s + "*" },
+	bullet:  "• ",
+	quote:   "> ",
+}
+
+var (
+	fencedBlockRe = regexp.MustCompile("(?s)```([\\w+#-]*)[^\\n]*\\n(.*?)```")
+	headingRe     = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+	bulletRe      = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	// inlineRe matches, in order of precedence: code, link, bold, strike
+	// and italic.
+	inlineRe = regexp.MustCompile("`([^`\\n]+)`" +
+		`|\[([^\]\n]+)\]\(((?:[^()\s]|\([^()\s]*\))+)\)` +
+		`|\*\*(.+?)\*\*|__(.+?)__` +
+		`|~~(.+?)~~` +
+		`|\*([^*\s](?:[^*\n]*[^*\s])?)\*|_([^_\s](?:[^_\n]*[^_\s])?)_`)
+)
+
+func convertMarkdown(text string, style markupStyle) string {
+	var sb strings.Builder
+	pos := 0
+	for _, m := range fencedBlockRe.FindAllStringSubmatchIndex(text, -1) {
+		sb.WriteString(convertLines(text[pos:m[0]], style))
+		sb.WriteString(style.pre(text[m[2]:m[3]], text[m[4]:m[5]]))
+		pos = m[1]
+	}
+	sb.WriteString(convertLines(text[pos:], style))
+	return sb.String()
+}
+
+// convertLines converts text outside code blocks line by line, so headings,
+// bullets and quotes are recognised at the start of each line.
+func convertLines(text string, style markupStyle) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		prefix := ""
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			lines[i] = style.heading(convertInline(m[1], style))
+			continue
+		}
+		if strings.HasPrefix(line, ">") {
+			prefix = style.quote
+			line = strings.TrimPrefix(strings.TrimPrefix(line, ">"), " ")
+		}
+		if m := bulletRe.FindStringSubmatch(line); m != nil {
+			prefix += m[1] + style.bullet
+			line = line[len(m[0]):]
+		}
+		lines[i] = prefix + convertInline(line, style)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convertInline converts inline code, links and emphasis, escaping all
+// other text.
+func convertInline(text string, style markupStyle) string {
+	var sb strings.Builder
+	pos := 0
+	for pos < len(text) {
+		m := inlineRe.FindStringSubmatchIndex(text[pos:])
+		if m == nil {
+			break
+		}
+		for i := range m {
+			if m[i] >= 0 {
+				m[i] += pos
+			}
+		}
+		start, end := m[0], m[1]
+		group := func(n int) string { return text[m[2*n]:m[2*n+1]] }
+
+		var rendered string
+		switch {
+		case m[2] >= 0:
+			rendered = style.code(group(1))
+		case m[4] >= 0:
+			rendered = style.link(convertInline(group(2), style), group(3))
+		case m[8] >= 0:
+			rendered = style.bold(convertInline(group(4), style))
+		case m[10] >= 0:
+			rendered = style.bold(convertInline(group(5), style))
+		case m[12] >= 0:
+			rendered = style.strike(convertInline(group(6), style))
+		case m[14] >= 0 || m[16] >= 0:
+			// Emphasis markers must not be inside a word, as in snake_case.
+			if isWordRune(text, start, true) || isWordRune(text, end, false) {
+				sb.WriteString(style.escape(text[pos : start+1]))
+				pos = start + 1
+				continue
+			}
+			inner := 7
+			if m[14] < 0 {
+				inner = 8
+			}
+			rendered = style.italic(convertInline(group(inner), style))
+		}
+		sb.WriteString(style.escape(text[pos:start]))
+		sb.WriteString(rendered)
+		pos = end
+	}
+	sb.WriteString(style.escape(text[pos:]))
+	return sb.String()
+}
+
+// isWordRune reports whether the rune just before (before is true) or at
+// offset i of s is a letter or digit.
+func isWordRune(s string, i int, before bool) bool {
+	var r rune
+	if before {
+		if i == 0 {
+			return false
+		}
+		r, _ = utf8.DecodeLastRuneInString(s[:i])
+	} else {
+		if i >= len(s) {
+			return false
+		}
+		r, _ = utf8.DecodeRuneInString(s[i:])
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,85 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestMarkdownToTelegramV2(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"reserved characters", "Costs $1.50 (approx.) - 2+2=4!", `Costs $1\.50 \(approx\.\) \- 2\+2\=4\!`},
+		{"emphasis", "**bold** *it* _it_ ~~gone~~", "*bold* _it_ _it_ ~gone~"},
+		{"intraword underscores", "snake_case_name and 2*3*4", `snake\_case\_name and 2\*3\*4`},
+		{"inline code", "run `a_b.c` now", "run `a_b.c` now"},
+		{"code block", "```go\nx := `s` + \"\\\\\"\n```", "```go\nx := \\`s\\` + \"\\\\\\\\\"\n```"},
+		{"link", "[the docs](https://x.io/a_(b))", `[the docs](https://x.io/a_(b\))`},
+		{"heading and bullet", "## v1.2\n- item", "*v1\\.2*\n• item"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarkdownToTelegramV2(tt.in); got != tt.want {
+				t.Errorf("MarkdownToTelegramV2(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToSlack(t *testing.T) {
+	in := "**Note** <b> & [site](https://x.io)\n```\na < b\n```"
+	want := "*Note* &lt;b&gt; &amp; <https://x.io|site>\n```\na &lt; b\n```"
+	if got := MarkdownToSlack(in); got != want {
+		t.Errorf("MarkdownToSlack() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitFormattedFitsEscapedParts(t *testing.T) {
+	// Every "." doubles under MarkdownV2 escaping.
+	content := strings.Repeat("a. b. c. ", 200)
+	parts := splitFormatted(content, 100, MarkdownToTelegramV2)
+	if len(parts) < 2 {
+		t.Fatalf("got %d parts, want several", len(parts))
+	}
+	var joined strings.Builder
+	for i, part := range parts {
+		if n := utf8.RuneCountInString(part); n > 100 {
+			t.Errorf("part %d is %d characters, want at most 100", i, n)
+		}
+		joined.WriteString(part)
+	}
+	if got := strings.Count(joined.String(), `\.`); got != 600 {
+		t.Errorf("parts hold %d escaped dots, want 600", got)
+	}
+}
+
+func TestFormattersFromConfig(t *testing.T) {
+	cfg := config.ChannelsConfig{
+		Telegram: config.TelegramConfig{ParseMode: "MarkdownV2"},
+		Formats:  map[string]string{"discord": "markdown", "feishu": "slack"},
+	}
+	formatters := formattersFromConfig(cfg)
+	if got := formatters["feishu"]("**hi**"); got != "*hi*" {
+		t.Errorf("feishu formatter gave %q, want Slack mrkdwn", got)
+	}
+	if got := formatters["telegram"]("1.5"); got != `1\.5` {
+		t.Errorf("telegram formatter gave %q, want MarkdownV2", got)
+	}
+	if _, ok := formatters["discord"]; ok {
+		t.Error("markdown format should leave the channel on the passthrough")
+	}
+}
+
+func TestMarkdownToTelegramHTMLKeepsCodeInOrder(t *testing.T) {
+	// Each placeholder must point at its own match, not the last one.
+	in := "```\none\n```\nand `a` or `b`\n```\ntwo\n```"
+	want := "<pre><code>one\n</code></pre>\nand <code>a</code> or <code>b</code>\n<pre><code>two\n</code></pre>"
+	if got := markdownToTelegramHTML(in); got != want {
+		t.Errorf("markdownToTelegramHTML() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package channels
+
+import "strings"
+
+// IdentityMap returns an identity resolver for channel backed by identities,
+// whose keys have the form "channel:sender_id". Sender IDs in the
+// "id|username" form are looked up as a whole, then by id, then by
+// "@username". Unmapped senders resolve to "" so the caller falls back to the
+// sender ID.
+func IdentityMap(channel string, identities map[string]string) func(senderID string) string {
+	return func(senderID string) string {
+		candidates := []string{senderID}
+		if id, username, ok := strings.Cut(senderID, "|"); ok {
+			candidates = append(candidates, id)
+			if username != "" {
+				candidates = append(candidates, "@"+username)
+			}
+		}
+		for _, candidate := range candidates {
+			if canonical, ok := identities[channel+":"+candidate]; ok {
+				return canonical
+			}
+		}
+		return ""
+	}
+}
@@ -121,7 +121,7 @@ func (c *MaixCamChannel) handleConnection(conn net.Conn, ctx context.Context) {
 				return
 			}
 
-			c.processMessage(msg, conn)
+			c.Track(func() { c.processMessage(msg, conn) })
 		}
 	}
 }
@@ -185,7 +185,7 @@ func (c *MaixCamChannel) handleStatusUpdate(msg MaixCamMessage) {
 
 func (c *MaixCamChannel) Stop(ctx context.Context) error {
 	logger.InfoC("maixcam", "Stopping MaixCam channel")
-	c.setRunning(false)
+	c.BaseChannel.Stop(ctx)
 
 	if c.listener != nil {
 		c.listener.Close()
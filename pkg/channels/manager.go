@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -21,6 +22,9 @@ type Manager struct {
 	bus          *bus.MessageBus
 	config       *config.Config
 	dispatchTask *asyncTask
+	dedup        *sendDedup
+	maxLengths   map[string]int
+	formatters   map[string]Formatter
 	mu           sync.RWMutex
 }
 
@@ -33,6 +37,10 @@ func NewManager(cfg *config.Config, messageBus *bus.MessageBus) (*Manager, error
 		channels: make(map[string]Channel),
 		bus:      messageBus,
 		config:   cfg,
+		dedup:    newSendDedup(defaultDedupTTL),
+
+		maxLengths: cfg.Channels.MaxMessageLengths(),
+		formatters: formattersFromConfig(cfg.Channels),
 	}
 
 	if err := m.initChannels(); err != nil {
@@ -110,6 +118,29 @@ func (m *Manager) initChannels() error {
 		}
 	}
 
+	if m.config.Channels.Web.Enabled {
+		logger.DebugC("channels", "Attempting to initialize web channel")
+		web, err := NewWebChannel(m.config.Channels.Web, m.bus)
+		if err != nil {
+			logger.ErrorCF("channels", "Failed to initialize web channel", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else {
+			m.channels["web"] = web
+			logger.InfoC("channels", "Web channel enabled successfully")
+		}
+	}
+
+	if len(m.config.Channels.Identities) > 0 {
+		for name, channel := range m.channels {
+			if ic, ok := channel.(interface {
+				SetIdentityResolver(func(string) string)
+			}); ok {
+				ic.SetIdentityResolver(IdentityMap(name, m.config.Channels.Identities))
+			}
+		}
+	}
+
 	logger.InfoCF("channels", "Channel initialization completed", map[string]interface{}{
 		"enabled_channels": len(m.channels),
 	})
@@ -155,11 +186,8 @@ func (m *Manager) StopAll(ctx context.Context) error {
 
 	logger.InfoC("channels", "Stopping all channels")
 
-	if m.dispatchTask != nil {
-		m.dispatchTask.cancel()
-		m.dispatchTask = nil
-	}
-
+	// Channels drain their in-flight messages within ctx; the dispatcher
+	// keeps delivering replies until they are done.
 	for name, channel := range m.channels {
 		logger.InfoCF("channels", "Stopping channel", map[string]interface{}{
 			"channel": name,
@@ -172,6 +200,11 @@ func (m *Manager) StopAll(ctx context.Context) error {
 		}
 	}
 
+	if m.dispatchTask != nil {
+		m.dispatchTask.cancel()
+		m.dispatchTask = nil
+	}
+
 	logger.InfoC("channels", "All channels stopped")
 	return nil
 }
@@ -201,12 +234,122 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 				continue
 			}
 
-			if err := channel.Send(ctx, msg); err != nil {
+			dedupKey := ""
+			if msg.IdempotencyKey != "" {
+				dedupKey = msg.Channel + ":" + msg.IdempotencyKey
+				if m.dedup.seen(dedupKey) {
+					logger.InfoCF("channels", "Skipping duplicate outbound message", map[string]interface{}{
+						"channel":         msg.Channel,
+						"idempotency_key": msg.IdempotencyKey,
+					})
+					continue
+				}
+			}
+
+			if err := m.sendSplit(ctx, channel, msg); err != nil {
 				logger.ErrorCF("channels", "Error sending message to channel", map[string]interface{}{
 					"channel": msg.Channel,
 					"error":   err.Error(),
 				})
+				continue
+			}
+			if dedupKey != "" {
+				m.dedup.mark(dedupKey)
+			}
+		}
+	}
+}
+
+// formatter returns the formatter that converts replies to the named
+// channel's markup before they are sent.
+func (m *Manager) formatter(channelName string) Formatter {
+	if f, ok := m.formatters[channelName]; ok {
+		return f
+	}
+	return PassthroughFormatter
+}
+
+// sendSplit sends msg, split into several messages if it is longer than the
+// channel's maximum message length, with each part run through the
+// channel's formatter. Splitting happens on the Markdown, so code blocks are
+// closed and reopened cleanly. It stops at the first part that fails.
+func (m *Manager) sendSplit(ctx context.Context, channel Channel, msg bus.OutboundMessage) error {
+	parts := splitFormatted(msg.Content, m.maxLengths[msg.Channel], m.formatter(msg.Channel))
+	if len(parts) > 1 {
+		logger.DebugCF("channels", "Splitting long outbound message", map[string]interface{}{
+			"channel": msg.Channel,
+			"length":  len(msg.Content),
+			"parts":   len(parts),
+		})
+	}
+	for i, part := range parts {
+		partMsg := msg
+		partMsg.Content = part
+		if msg.IdempotencyKey != "" && len(parts) > 1 {
+			// Channels dedupe on the key, so each part needs its own.
+			partMsg.IdempotencyKey = fmt.Sprintf("%s:%d", msg.IdempotencyKey, i+1)
+		}
+		if err := SendWithRetry(ctx, channel, partMsg, DefaultSendRetryPolicy); err != nil {
+			if len(parts) > 1 {
+				return fmt.Errorf("part %d of %d: %w", i+1, len(parts), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// typingRefreshInterval is how often StartTyping re-sends the indicator;
+// platforms expire it after roughly 5-10 seconds.
+const typingRefreshInterval = 4 * time.Second
+
+// StartTyping shows a typing indicator in chatID on the named channel until
+// the returned stop function is called or ctx ends. It is a no-op for
+// channels that don't implement TypingIndicator.
+func (m *Manager) StartTyping(ctx context.Context, channelName, chatID string) func() {
+	m.mu.RLock()
+	channel, exists := m.channels[channelName]
+	m.mu.RUnlock()
+
+	ti, ok := channel.(TypingIndicator)
+	if !exists || !ok {
+		return func() {}
+	}
+
+	typingCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(typingRefreshInterval)
+		defer ticker.Stop()
+		for {
+			if err := ti.SendTyping(typingCtx, chatID); err != nil && typingCtx.Err() == nil {
+				logger.DebugCF("channels", "Failed to send typing indicator", map[string]interface{}{
+					"channel": channelName,
+					"error":   err.Error(),
+				})
 			}
+			select {
+			case <-typingCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// Use installs inbound middlewares on every registered channel that supports
+// them (all channels built on BaseChannel do).
+func (m *Manager) Use(middlewares ...Middleware) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for name, channel := range m.channels {
+		if mc, ok := channel.(interface{ Use(...Middleware) }); ok {
+			mc.Use(middlewares...)
+		} else {
+			logger.WarnCF("channels", "Channel does not support middlewares", map[string]interface{}{
+				"channel": name,
+			})
 		}
 	}
 }
@@ -270,5 +413,11 @@ func (m *Manager) SendToChannel(ctx context.Context, channelName, chatID, conten
 		Content: content,
 	}
 
-	return channel.Send(ctx, msg)
+	for _, part := range splitFormatted(content, m.maxLengths[channelName], m.formatter(channelName)) {
+		msg.Content = part
+		if err := channel.Send(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,12 @@
+package channels
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// MediaDir is where channels save downloaded media before passing their
+// paths on in InboundMessage.Media.
+func MediaDir() string {
+	return filepath.Join(os.TempDir(), "picoclaw_media")
+}
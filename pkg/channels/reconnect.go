@@ -0,0 +1,126 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ReconnectPolicy bounds how Reconnect retries a dropped connection.
+// MaxAttempts <= 0 retries until the context ends. Jitter spreads each delay
+// by up to that fraction either way, so bots that dropped together don't
+// reconnect in lockstep.
+type ReconnectPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Jitter       float64
+}
+
+// DefaultReconnectPolicy is used by channels with long-lived connections.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	MaxAttempts:  20,
+	InitialDelay: time.Second,
+	MaxDelay:     2 * time.Minute,
+	Jitter:       0.2,
+}
+
+// Reconnect calls connect until it succeeds, waiting with exponential
+// backoff between attempts and logging each one. It returns the last error
+// once policy.MaxAttempts is reached, or the context error if ctx ends
+// first. The channel is marked not running while reconnecting.
+//
+// connect returns the new connection so that, if Stop runs while it is
+// being established, Reconnect can close it instead of reviving a stopped
+// channel.
+func (c *BaseChannel) Reconnect(ctx context.Context, policy ReconnectPolicy, connect func(ctx context.Context) (io.Closer, error)) error {
+	c.setRunning(false)
+
+	var err error
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		wait := policy.delay(attempt)
+		logger.WarnCF("channels", "Reconnecting channel", map[string]interface{}{
+			"channel":      c.name,
+			"attempt":      attempt,
+			"max_attempts": policy.MaxAttempts,
+			"wait_ms":      wait.Milliseconds(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		if c.stopping() {
+			return errStoppedWhileReconnecting(c.name)
+		}
+		var conn io.Closer
+		if conn, err = connect(ctx); err == nil {
+			if !c.resume() {
+				conn.Close()
+				return errStoppedWhileReconnecting(c.name)
+			}
+			logger.InfoCF("channels", "Channel reconnected", map[string]interface{}{
+				"channel": c.name,
+				"attempt": attempt,
+			})
+			return nil
+		}
+		logger.WarnCF("channels", "Reconnect attempt failed", map[string]interface{}{
+			"channel": c.name,
+			"attempt": attempt,
+			"error":   err.Error(),
+		})
+	}
+
+	logger.ErrorCF("channels", "Giving up reconnecting channel", map[string]interface{}{
+		"channel":  c.name,
+		"attempts": policy.MaxAttempts,
+		"error":    err.Error(),
+	})
+	return fmt.Errorf("reconnect %s channel failed after %d attempts: %w", c.name, policy.MaxAttempts, err)
+}
+
+func errStoppedWhileReconnecting(name string) error {
+	return fmt.Errorf("%s channel stopped while reconnecting", name)
+}
+
+// resume marks the channel running again unless Stop has been called,
+// checking and setting under one lock so a concurrent Stop can't be undone.
+func (c *BaseChannel) resume() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		return false
+	}
+	c.running = true
+	return true
+}
+
+// stopping reports whether Stop has been called since the channel last
+// started.
+func (c *BaseChannel) stopping() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.draining
+}
+
+// delay returns the jittered wait before the given attempt, counting from 1.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	d := p.InitialDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(float64(d) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return d
+}
@@ -0,0 +1,72 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	p := ReconnectPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		50: time.Second,
+	} {
+		if got := p.delay(attempt); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 200; i++ {
+		if got := p.delay(10); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("jittered delay = %v, want within 20%% of 1s", got)
+		}
+	}
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestReconnectGivesUpAfterMaxAttempts(t *testing.T) {
+	c := NewBaseChannel("test", nil, nil, []string{"x"})
+	policy := ReconnectPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond}
+	boom := errors.New("boom")
+
+	attempts := 0
+	err := c.Reconnect(context.Background(), policy, func(context.Context) (io.Closer, error) {
+		attempts++
+		return nil, boom
+	})
+	if !errors.Is(err, boom) || attempts != 3 {
+		t.Errorf("err = %v after %d attempts, want boom after 3", err, attempts)
+	}
+	if c.IsRunning() {
+		t.Error("channel running after giving up")
+	}
+}
+
+func TestReconnectClosesConnectionWhenStoppedMeanwhile(t *testing.T) {
+	c := NewBaseChannel("test", nil, nil, []string{"x"})
+	c.setRunning(true)
+	policy := ReconnectPolicy{MaxAttempts: 1, InitialDelay: time.Millisecond}
+
+	closed := false
+	err := c.Reconnect(context.Background(), policy, func(context.Context) (io.Closer, error) {
+		// Stop lands while the connection is being established.
+		c.Stop(context.Background())
+		return closerFunc(func() error { closed = true; return nil }), nil
+	})
+	if err == nil || !closed {
+		t.Errorf("err = %v, closed = %v; want an error and the new connection closed", err, closed)
+	}
+	if c.IsRunning() {
+		t.Error("stopped channel was marked running again")
+	}
+}
@@ -0,0 +1,127 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// SendRetryPolicy bounds how often and how long SendWithRetry keeps trying.
+type SendRetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// DefaultSendRetryPolicy is used by the Manager's outbound dispatcher.
+var DefaultSendRetryPolicy = SendRetryPolicy{
+	MaxAttempts:  4,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+}
+
+// SendWithRetry calls ch.Send, retrying transient failures (rate limits,
+// server errors, network blips) with exponential backoff. Non-retriable errors
+// are returned immediately, as is the context error if ctx ends while waiting.
+func SendWithRetry(ctx context.Context, ch Channel, msg bus.OutboundMessage, policy SendRetryPolicy) error {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := policy.InitialDelay
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = ch.Send(ctx, msg)
+		if err == nil || attempt == attempts || !IsRetriableSendError(err) {
+			break
+		}
+
+		wait := delay
+		if ra := sendRetryAfter(err); ra > wait {
+			wait = ra
+		}
+		if policy.MaxDelay > 0 && wait > policy.MaxDelay {
+			wait = policy.MaxDelay
+		}
+
+		logger.WarnCF("channels", "Send failed, retrying", map[string]interface{}{
+			"channel": ch.Name(),
+			"attempt": attempt,
+			"wait_ms": wait.Milliseconds(),
+			"error":   err.Error(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+
+	if err != nil && attempts > 1 && IsRetriableSendError(err) {
+		return fmt.Errorf("send failed after %d attempts: %w", attempts, err)
+	}
+	return err
+}
+
+// IsRetriableSendError reports whether a Send error is likely transient.
+// Client errors such as "message is too long" or "forbidden" are not.
+func IsRetriableSendError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) {
+		return tgErr.Code == 429 || tgErr.Code >= 500 || tgErr.RetryAfter > 0
+	}
+	var rlErr *discordgo.RateLimitError
+	if errors.As(err, &rlErr) {
+		return true
+	}
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		code := restErr.Response.StatusCode
+		return code == 429 || code >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"too many requests", "rate limit", "timeout", "connection reset", "connection refused", "temporarily unavailable", "unexpected eof"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendRetryAfter returns the server-requested wait, if the error carries one.
+func sendRetryAfter(err error) time.Duration {
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		return time.Duration(tgErr.RetryAfter) * time.Second
+	}
+	var rlErr *discordgo.RateLimitError
+	if errors.As(err, &rlErr) && rlErr.RateLimit != nil && rlErr.TooManyRequests != nil {
+		return rlErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+package channels
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// fenceClose ends a code block that continues in the next part.
+const fenceClose = "\n```"
+
+// splitMessage breaks content into parts of at most maxLen characters,
+// preferring paragraph, line, sentence and word boundaries in that order.
+// A code block cut in two is closed at the end of one part and reopened,
+// with its language tag, at the start of the next. maxLen <= 0 disables
+// splitting.
+func splitMessage(content string, maxLen int) []string {
+	if maxLen <= 0 || utf8.RuneCountInString(content) <= maxLen {
+		return []string{content}
+	}
+
+	var parts []string
+	reopen := "" // fence line of a code block carried into the next part
+	rest := content
+	for rest != "" {
+		prefix := ""
+		if reopen != "" {
+			prefix = reopen + "\n"
+		}
+		if utf8.RuneCountInString(prefix)+utf8.RuneCountInString(rest) <= maxLen {
+			parts = append(parts, prefix+rest)
+			break
+		}
+
+		// Leave room for the fence lines in case the cut falls inside a
+		// code block.
+		budget := maxLen - utf8.RuneCountInString(prefix) - len(fenceClose)
+		if budget < 1 {
+			budget = 1
+		}
+		end, next := splitPoint(rest, budget)
+		chunk := strings.TrimRight(rest[:end], " \n")
+		// A hard cut can land just before a line break, which would start
+		// the next part with an empty line.
+		rest = strings.TrimPrefix(rest[next:], "\n")
+
+		reopen = openFence(reopen, chunk)
+		if reopen != "" && utf8.RuneCountInString(reopen)+1+len(fenceClose) >= maxLen {
+			// No room to reopen the block in the next part; carry on
+			// with plain cuts rather than overflow every part.
+			reopen = ""
+		}
+		part := prefix + chunk
+		if reopen != "" {
+			part += fenceClose
+		}
+		if strings.TrimSpace(chunk) != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// splitFormatted splits content like splitMessage and runs each part
+// through format. Escaping can make a part longer than maxLen, so such a
+// part is split again with its budget shrunk by the growth until every
+// formatted part fits.
+func splitFormatted(content string, maxLen int, format Formatter) []string {
+	return splitFormattedWithin(content, maxLen, maxLen, format)
+}
+
+func splitFormattedWithin(content string, maxLen, budget int, format Formatter) []string {
+	var out []string
+	for _, part := range splitMessage(content, budget) {
+		formatted := format(part)
+		size, grown := utf8.RuneCountInString(part), utf8.RuneCountInString(formatted)
+		if maxLen <= 0 || grown <= maxLen || budget <= 1 {
+			out = append(out, formatted)
+			continue
+		}
+		// The budget shrinks on every pass, so this ends even when fence
+		// lines alone overflow maxLen.
+		smaller := size * maxLen / grown
+		if smaller >= budget {
+			smaller = budget - 1
+		}
+		if smaller < 1 {
+			smaller = 1
+		}
+		out = append(out, splitFormattedWithin(part, maxLen, smaller, format)...)
+	}
+	return out
+}
+
+// splitPoint picks where to cut s so the first part has at most budget
+// characters. It returns the end of the first part and the start of the
+// rest, which skips the separator.
+func splitPoint(s string, budget int) (end, next int) {
+	limit := len(s)
+	for i := range s {
+		if budget == 0 {
+			limit = i
+			break
+		}
+		budget--
+	}
+
+	window := s[:limit]
+	// Only accept boundaries in the second half, so parts don't get tiny.
+	if i := strings.LastIndex(window, "\n\n"); i > limit/2 {
+		return i, i + 2
+	}
+	if i := strings.LastIndex(window, "\n"); i > limit/2 {
+		return i, i + 1
+	}
+	best := -1
+	for _, sep := range []string{". ", "! ", "? ", "。"} {
+		if i := strings.LastIndex(window, sep); i >= 0 {
+			// Keep the punctuation with the sentence it ends.
+			if e := i + len(strings.TrimRight(sep, " ")); e > best {
+				best = e
+			}
+		}
+	}
+	if best > limit/2 {
+		next = best
+		if next < len(s) && s[next] == ' ' {
+			next++
+		}
+		return best, next
+	}
+	if i := strings.LastIndex(window, " "); i > limit/2 {
+		return i, i + 1
+	}
+	return limit, limit
+}
+
+// openFence returns the fence line of the code block still open at the end
+// of chunk, given the one open at its start, or "" if none is.
+func openFence(open, chunk string) string {
+	for _, line := range strings.Split(chunk, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "```") {
+			continue
+		}
+		if open != "" {
+			open = ""
+		} else {
+			open = line
+		}
+	}
+	return open
+}
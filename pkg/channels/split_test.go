@@ -0,0 +1,114 @@
+package channels
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		maxLen  int
+		want    []string
+	}{
+		{"fits", "short", 10, []string{"short"}},
+		{"no limit", strings.Repeat("x", 50), 0, []string{strings.Repeat("x", 50)}},
+		{
+			"paragraph boundary", "First paragraph here.\n\nSecond one follows.", 30,
+			[]string{"First paragraph here.", "Second one follows."},
+		},
+		{
+			"line boundary", "line one is here\nline two is here\nline three", 25,
+			[]string{"line one is here", "line two is here", "line three"},
+		},
+		{
+			"sentence boundary", "One sentence here. Another sentence. Third bit", 30,
+			[]string{"One sentence here.", "Another sentence. Third bit"},
+		},
+		{
+			"code block reopened with its language",
+			"intro\n```go\nfmt.Println(1)\nfmt.Println(2)\nfmt.Println(3)\n```\nend", 30,
+			[]string{
+				"intro\n```go\nfmt.Println(1)\n```",
+				"```go\nfmt.Println(2)\n```",
+				"```go\nfmt.Println(3)\n```\nend",
+			},
+		},
+		{
+			"multibyte text at the limit", strings.Repeat("日本語", 10), 10,
+			[]string{"日本語日本語", "日本語日本語", "日本語日本語", "日本語日本語", "日本語日本語"},
+		},
+		{"multibyte text exactly fitting", strings.Repeat("é", 10), 10, []string{strings.Repeat("é", 10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.content, tt.maxLen)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage() = %q, want %q", got, tt.want)
+			}
+			for i, part := range got {
+				if !utf8.ValidString(part) {
+					t.Errorf("part %d is not valid UTF-8: %q", i, part)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageLimitBelowFenceLine(t *testing.T) {
+	content := "```python\nx = 1\ny = 2\n```"
+	parts := splitMessage(content, 12)
+	for i, part := range parts {
+		if n := utf8.RuneCountInString(part); n > 12 {
+			t.Errorf("part %d is %d characters, want at most 12: %q", i, n, part)
+		}
+	}
+	strip := func(s string) string { return strings.Join(strings.Fields(s), "") }
+	if got := strip(strings.Join(parts, "")); got != strip(content) {
+		t.Errorf("parts %q lost or added content", parts)
+	}
+}
+
+type recordingChannel struct {
+	*BaseChannel
+	sent []bus.OutboundMessage
+}
+
+func (c *recordingChannel) Start(ctx context.Context) error { return nil }
+
+func (c *recordingChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+func TestSendSplitIdempotencyKeys(t *testing.T) {
+	ch := &recordingChannel{BaseChannel: NewBaseChannel("test", nil, nil, []string{"x"})}
+	m := &Manager{maxLengths: map[string]int{"test": 30}}
+
+	msg := bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "First paragraph here.\n\nSecond one follows.", IdempotencyKey: "reply-7"}
+	if err := m.sendSplit(context.Background(), ch, msg); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, sent := range ch.sent {
+		keys = append(keys, sent.IdempotencyKey)
+	}
+	if want := []string{"reply-7:1", "reply-7:2"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("idempotency keys = %q, want %q", keys, want)
+	}
+
+	ch.sent = nil
+	msg.Content = "short"
+	if err := m.sendSplit(context.Background(), ch, msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.sent) != 1 || ch.sent[0].IdempotencyKey != "reply-7" {
+		t.Errorf("unsplit message sent as %+v, want the key unchanged", ch.sent)
+	}
+}
@@ -3,6 +3,7 @@ package channels
 import (
 	"context"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
@@ -20,6 +21,10 @@ import (
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
+// TelegramMarkdownV2 selects Telegram's MarkdownV2 parse mode for replies
+// instead of the default HTML.
+const TelegramMarkdownV2 = "markdownv2"
+
 type TelegramChannel struct {
 	*BaseChannel
 	bot          *tgbotapi.BotAPI
@@ -83,7 +88,7 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 					return
 				}
 				if update.Message != nil {
-					c.handleMessage(update)
+					c.Track(func() { c.handleMessage(update) })
 				}
 			}
 		}
@@ -94,7 +99,7 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 
 func (c *TelegramChannel) Stop(ctx context.Context) error {
 	log.Println("Stopping Telegram bot...")
-	c.setRunning(false)
+	c.BaseChannel.Stop(ctx)
 
 	if c.updates != nil {
 		c.bot.StopReceivingUpdates()
@@ -120,13 +125,17 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		c.stopThinking.Delete(msg.ChatID)
 	}
 
-	htmlContent := markdownToTelegramHTML(msg.Content)
+	// The manager has already run the content through TelegramFormatter.
+	content, parseMode := msg.Content, tgbotapi.ModeHTML
+	if strings.EqualFold(c.config.ParseMode, TelegramMarkdownV2) {
+		parseMode = tgbotapi.ModeMarkdownV2
+	}
 
 	// Try to edit placeholder
 	if pID, ok := c.placeholders.Load(msg.ChatID); ok {
 		c.placeholders.Delete(msg.ChatID)
-		editMsg := tgbotapi.NewEditMessageText(chatID, pID.(int), htmlContent)
-		editMsg.ParseMode = tgbotapi.ModeHTML
+		editMsg := tgbotapi.NewEditMessageText(chatID, pID.(int), content)
+		editMsg.ParseMode = parseMode
 
 		if _, err := c.bot.Send(editMsg); err == nil {
 			return nil
@@ -134,12 +143,12 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		// Fallback to new message if edit fails
 	}
 
-	tgMsg := tgbotapi.NewMessage(chatID, htmlContent)
-	tgMsg.ParseMode = tgbotapi.ModeHTML
+	tgMsg := tgbotapi.NewMessage(chatID, content)
+	tgMsg.ParseMode = parseMode
 
 	if _, err := c.bot.Send(tgMsg); err != nil {
-		log.Printf("HTML parse failed, falling back to plain text: %v", err)
-		tgMsg = tgbotapi.NewMessage(chatID, msg.Content)
+		log.Printf("%s parse failed, falling back to plain text: %v", parseMode, err)
+		tgMsg = tgbotapi.NewMessage(chatID, telegramPlainText(content, parseMode))
 		tgMsg.ParseMode = ""
 		_, err = c.bot.Send(tgMsg)
 		return err
@@ -148,6 +157,22 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// SendTyping shows the "typing..." chat action, which Telegram clears after
+// about five seconds or when a message is sent.
+func (c *TelegramChannel) SendTyping(ctx context.Context, chatID string) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("telegram bot not running")
+	}
+
+	id, err := parseChatID(chatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+
+	_, err = c.bot.Request(tgbotapi.NewChatAction(id, tgbotapi.ChatTyping))
+	return err
+}
+
 func (c *TelegramChannel) handleMessage(update tgbotapi.Update) {
 	message := update.Message
 	if message == nil {
@@ -313,7 +338,7 @@ func (c *TelegramChannel) downloadFileWithInfo(file *tgbotapi.File, ext string)
 	redactedURL := strings.Replace(url, c.bot.Token, "REDACTED", 1)
 	log.Printf("File URL: %s", redactedURL)
 
-	mediaDir := filepath.Join(os.TempDir(), "picoclaw_media")
+	mediaDir := MediaDir()
 	if err := os.MkdirAll(mediaDir, 0755); err != nil {
 		log.Printf("Failed to create media directory: %v", err)
 		return ""
@@ -377,7 +402,7 @@ func (c *TelegramChannel) downloadFile(fileID, ext string) string {
 	redactedURL := strings.Replace(url, c.bot.Token, "REDACTED", 1)
 	log.Printf("File URL: %s", redactedURL)
 
-	mediaDir := filepath.Join(os.TempDir(), "picoclaw_media")
+	mediaDir := MediaDir()
 	if err := os.MkdirAll(mediaDir, 0755); err != nil {
 		log.Printf("Failed to create media directory: %v", err)
 		return ""
@@ -406,6 +431,29 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen]
 }
 
+// TelegramFormatter returns the formatter for replies sent with the
+// configured parse mode.
+func TelegramFormatter(cfg config.TelegramConfig) Formatter {
+	if strings.EqualFold(cfg.ParseMode, TelegramMarkdownV2) {
+		return MarkdownToTelegramV2
+	}
+	return markdownToTelegramHTML
+}
+
+var (
+	telegramHTMLTag   = regexp.MustCompile(`<[^>]+>`)
+	telegramV2Escaped = regexp.MustCompile(`\\(.)`)
+)
+
+// telegramPlainText strips the markup from formatted content, for resending
+// it as plain text when Telegram rejects the markup.
+func telegramPlainText(content, parseMode string) string {
+	if parseMode == tgbotapi.ModeMarkdownV2 {
+		return telegramV2Escaped.ReplaceAllString(content, "$1")
+	}
+	return html.UnescapeString(telegramHTMLTag.ReplaceAllString(content, ""))
+}
+
 func markdownToTelegramHTML(text string) string {
 	if text == "" {
 		return ""
@@ -469,8 +517,11 @@ func extractCodeBlocks(text string) codeBlockMatch {
 		codes = append(codes, match[1])
 	}
 
+	i := 0
 	text = re.ReplaceAllStringFunc(text, func(m string) string {
-		return fmt.Sprintf("\x00CB%d\x00", len(codes)-1)
+		placeholder := fmt.Sprintf("\x00CB%d\x00", i)
+		i++
+		return placeholder
 	})
 
 	return codeBlockMatch{text: text, codes: codes}
@@ -490,8 +541,11 @@ func extractInlineCodes(text string) inlineCodeMatch {
 		codes = append(codes, match[1])
 	}
 
+	i := 0
 	text = re.ReplaceAllStringFunc(text, func(m string) string {
-		return fmt.Sprintf("\x00IC%d\x00", len(codes)-1)
+		placeholder := fmt.Sprintf("\x00IC%d\x00", i)
+		i++
+		return placeholder
 	})
 
 	return inlineCodeMatch{text: text, codes: codes}
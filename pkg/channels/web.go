@@ -0,0 +1,307 @@
+package channels
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	webWriteTimeout   = 10 * time.Second
+	webMaxFrameBytes  = 1 << 20
+	webShutdownPeriod = 5 * time.Second
+)
+
+// WebMessage is a frame exchanged with web clients over the WebSocket.
+// Clients send {"type":"message","content":"..."} and optionally a chat_id
+// to keep several conversations apart; the server sends "typing" frames
+// while the agent works, a "message" frame with each reply, and "error"
+// frames for rejected input. Replies arrive whole: the agent doesn't stream
+// partial output, so clients see nothing but typing frames until the reply
+// is done.
+type WebMessage struct {
+	Type    string `json:"type"`
+	ChatID  string `json:"chat_id,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// WebChannel serves a WebSocket endpoint at /ws for embedding picoclaw in a
+// web app. Clients authenticate with a session token, sent as a bearer
+// Authorization header or, since browsers can't set headers on WebSocket
+// requests, a token query parameter.
+type WebChannel struct {
+	*BaseChannel
+	config   config.WebConfig
+	server   *http.Server
+	upgrader websocket.Upgrader
+	// clients holds the open connections of each chat. A chat can have
+	// several, such as one per browser tab.
+	clients    map[string]map[*webClient]bool
+	clientsMux sync.RWMutex
+}
+
+type webClient struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (wc *webClient) write(msg WebMessage) error {
+	wc.writeMu.Lock()
+	defer wc.writeMu.Unlock()
+	wc.conn.SetWriteDeadline(time.Now().Add(webWriteTimeout))
+	return wc.conn.WriteJSON(msg)
+}
+
+func NewWebChannel(cfg config.WebConfig, bus *bus.MessageBus) (*WebChannel, error) {
+	if len(cfg.Tokens) == 0 {
+		return nil, fmt.Errorf("web channel requires at least one session token")
+	}
+
+	base := NewBaseChannel("web", cfg, bus, cfg.AllowFrom)
+	c := &WebChannel{
+		BaseChannel: base,
+		config:      cfg,
+		clients:     make(map[string]map[*webClient]bool),
+	}
+	c.upgrader = websocket.Upgrader{CheckOrigin: c.checkOrigin}
+	return c, nil
+}
+
+func (c *WebChannel) Start(ctx context.Context) error {
+	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", c.handleWebSocket)
+	c.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := c.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.ErrorCF("web", "Web channel server stopped", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	c.setRunning(true)
+	logger.InfoCF("web", "Web channel listening", map[string]interface{}{
+		"host": c.config.Host,
+		"port": c.config.Port,
+	})
+	return nil
+}
+
+func (c *WebChannel) Stop(ctx context.Context) error {
+	logger.InfoC("web", "Stopping web channel")
+	c.BaseChannel.Stop(ctx)
+
+	if c.server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webShutdownPeriod)
+		defer cancel()
+		// Shutdown doesn't touch hijacked WebSocket connections; they are
+		// closed below.
+		c.server.Shutdown(shutdownCtx)
+	}
+
+	c.clientsMux.Lock()
+	defer c.clientsMux.Unlock()
+	for _, clients := range c.clients {
+		for client := range clients {
+			client.conn.Close()
+		}
+	}
+	c.clients = make(map[string]map[*webClient]bool)
+	return nil
+}
+
+// Send delivers msg to every open connection of its chat. A reply to a chat
+// with no open connection, such as one whose tab was closed while the agent
+// worked, is dropped.
+func (c *WebChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	return c.broadcast(msg.ChatID, WebMessage{Type: "message", ChatID: msg.ChatID, Content: msg.Content})
+}
+
+// SendTyping implements TypingIndicator.
+func (c *WebChannel) SendTyping(ctx context.Context, chatID string) error {
+	return c.broadcast(chatID, WebMessage{Type: "typing", ChatID: chatID})
+}
+
+func (c *WebChannel) broadcast(chatID string, msg WebMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("web channel not running")
+	}
+
+	c.clientsMux.RLock()
+	clients := make([]*webClient, 0, len(c.clients[chatID]))
+	for client := range c.clients[chatID] {
+		clients = append(clients, client)
+	}
+	c.clientsMux.RUnlock()
+
+	if len(clients) == 0 {
+		// Nobody can receive it now, so retrying wouldn't help.
+		logger.DebugCF("web", "No web clients connected, dropping message", map[string]interface{}{
+			"chat_id": chatID,
+			"type":    msg.Type,
+		})
+		return nil
+	}
+
+	var sendErr error
+	for _, client := range clients {
+		if err := client.write(msg); err != nil {
+			logger.WarnCF("web", "Failed to send to web client", map[string]interface{}{
+				"chat_id": chatID,
+				"error":   err.Error(),
+			})
+			sendErr = err
+		}
+	}
+	return sendErr
+}
+
+func (c *WebChannel) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	senderID, ok := c.authenticate(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response.
+		logger.WarnCF("web", "WebSocket upgrade failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	conn.SetReadLimit(webMaxFrameBytes)
+
+	client := &webClient{conn: conn}
+	logger.InfoCF("web", "Web client connected", map[string]interface{}{
+		"sender_id":   senderID,
+		"remote_addr": r.RemoteAddr,
+	})
+
+	var joined []string
+	defer func() {
+		c.clientsMux.Lock()
+		for _, chatID := range joined {
+			delete(c.clients[chatID], client)
+			if len(c.clients[chatID]) == 0 {
+				delete(c.clients, chatID)
+			}
+		}
+		c.clientsMux.Unlock()
+		conn.Close()
+		logger.DebugCF("web", "Web client disconnected", map[string]interface{}{
+			"sender_id": senderID,
+		})
+	}()
+
+	for {
+		var msg WebMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.DebugCF("web", "Web client read error", map[string]interface{}{
+					"sender_id": senderID,
+					"error":     err.Error(),
+				})
+			}
+			return
+		}
+
+		if msg.Type != "message" {
+			client.write(WebMessage{Type: "error", Content: fmt.Sprintf("unsupported message type %q", msg.Type)})
+			continue
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+
+		chatID := webChatID(senderID, msg.ChatID)
+		if c.join(chatID, client) {
+			joined = append(joined, chatID)
+		}
+		c.Track(func() { c.HandleMessage(senderID, chatID, msg.Content, nil, nil) })
+	}
+}
+
+// join registers client to receive replies for chatID and reports whether
+// it wasn't already. A connection joins a chat when it first sends to it.
+func (c *WebChannel) join(chatID string, client *webClient) bool {
+	c.clientsMux.Lock()
+	defer c.clientsMux.Unlock()
+	if c.clients[chatID] == nil {
+		c.clients[chatID] = make(map[*webClient]bool)
+	}
+	if c.clients[chatID][client] {
+		return false
+	}
+	c.clients[chatID][client] = true
+	return true
+}
+
+// webChatID scopes a client-chosen chat ID to its sender, so one user can't
+// join another's conversation by guessing its ID.
+func webChatID(senderID, chatID string) string {
+	if chatID == "" {
+		return senderID
+	}
+	return senderID + "/" + chatID
+}
+
+// authenticate maps the request's session token to a sender ID.
+func (c *WebChannel) authenticate(r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return "", false
+	}
+
+	// Compare against every token so the time taken doesn't reveal how many
+	// characters of a guess were right.
+	senderID, found := "", false
+	for candidate, id := range c.config.Tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			senderID, found = id, true
+		}
+	}
+	return senderID, found && senderID != ""
+}
+
+func (c *WebChannel) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser; the token is all that matters.
+		return true
+	}
+	if len(c.config.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range c.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
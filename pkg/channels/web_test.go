@@ -0,0 +1,150 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestWebChannel(t *testing.T, cfg config.WebConfig) (*WebChannel, *bus.MessageBus) {
+	t.Helper()
+	if cfg.Tokens == nil {
+		cfg.Tokens = map[string]string{"alice-token": "alice", "bob-token": "bob", "blank-token": ""}
+	}
+	mb := bus.NewMessageBus()
+	c, err := NewWebChannel(cfg, mb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, mb
+}
+
+func TestWebAuthenticate(t *testing.T) {
+	c, _ := newTestWebChannel(t, config.WebConfig{})
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{"bearer header", "Bearer alice-token", "", "alice", true},
+		{"query parameter", "", "token=bob-token", "bob", true},
+		{"header wins over query", "Bearer alice-token", "token=bob-token", "alice", true},
+		{"wrong token", "Bearer nope", "", "", false},
+		{"token prefix", "Bearer alice", "", "", false},
+		{"no token", "", "", "", false},
+		{"token without sender", "", "token=blank-token", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws?"+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			got, ok := c.authenticate(r)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("authenticate() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWebCheckOrigin(t *testing.T) {
+	sameOrigin, _ := newTestWebChannel(t, config.WebConfig{})
+	listed, _ := newTestWebChannel(t, config.WebConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	anyOrigin, _ := newTestWebChannel(t, config.WebConfig{AllowedOrigins: []string{"*"}})
+
+	tests := []struct {
+		name   string
+		c      *WebChannel
+		origin string
+		want   bool
+	}{
+		{"no origin", sameOrigin, "", true},
+		{"same origin", sameOrigin, "http://chat.local:8080", true},
+		{"cross origin", sameOrigin, "https://evil.example", false},
+		{"listed origin", listed, "https://APP.example.com", true},
+		{"unlisted origin", listed, "https://chat.local:8080", false},
+		{"wildcard", anyOrigin, "https://evil.example", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://chat.local:8080/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := tt.c.checkOrigin(r); got != tt.want {
+				t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebChatsAreScopedToSender(t *testing.T) {
+	c, mb := newTestWebChannel(t, config.WebConfig{})
+	c.setRunning(true)
+	srv := httptest.NewServer(http.HandlerFunc(c.handleWebSocket))
+	defer srv.Close()
+
+	dial := func(token string) *websocket.Conn {
+		t.Helper()
+		conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?token="+token, nil)
+		if err != nil {
+			t.Fatalf("dial with %s: %v", token, err)
+		}
+		resp.Body.Close()
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil); err == nil {
+		t.Fatal("dial without a token succeeded")
+	} else if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial without a token: %v, want 401", err)
+	}
+
+	alice, bob := dial("alice-token"), dial("bob-token")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	chats := make(map[string]string)
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := conn.WriteJSON(WebMessage{Type: "message", ChatID: "shared", Content: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+		msg, ok := mb.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatal("no inbound message")
+		}
+		chats[msg.SenderID] = msg.ChatID
+	}
+	if chats["alice"] != "alice/shared" || chats["bob"] != "bob/shared" {
+		t.Fatalf("chat IDs = %v, want each scoped to its sender", chats)
+	}
+
+	if err := c.Send(ctx, bus.OutboundMessage{ChatID: "alice/shared", Content: "for alice"}); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+	var got WebMessage
+	alice.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := alice.ReadJSON(&got); err != nil || got.Content != "for alice" {
+		t.Fatalf("alice read %+v, %v", got, err)
+	}
+	bob.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := bob.ReadJSON(&got); err == nil {
+		t.Fatalf("bob received alice's reply: %+v", got)
+	}
+
+	// A reply nobody can receive is dropped rather than retried.
+	if err := c.Send(ctx, bus.OutboundMessage{ChatID: "carol", Content: "gone"}); err != nil {
+		t.Errorf("Send() to a chat with no clients = %v, want nil", err)
+	}
+}
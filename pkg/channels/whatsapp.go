@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"sync"
 	"time"
@@ -37,29 +38,43 @@ func NewWhatsAppChannel(cfg config.WhatsAppConfig, bus *bus.MessageBus) (*WhatsA
 func (c *WhatsAppChannel) Start(ctx context.Context) error {
 	log.Printf("Starting WhatsApp channel connecting to %s...", c.url)
 
-	dialer := websocket.DefaultDialer
+	if _, err := c.connect(ctx); err != nil {
+		return err
+	}
+
+	c.setRunning(true)
+	log.Println("WhatsApp channel connected")
+
+	go c.listen(ctx)
+
+	return nil
+}
+
+// connect dials the bridge and replaces the current connection, which it
+// returns.
+func (c *WhatsAppChannel) connect(ctx context.Context) (io.Closer, error) {
+	dialer := *websocket.DefaultDialer
 	dialer.HandshakeTimeout = 10 * time.Second
 
-	conn, _, err := dialer.Dial(c.url, nil)
+	conn, _, err := dialer.DialContext(ctx, c.url, nil)
 	if err != nil {
-		return fmt.Errorf("failed to connect to WhatsApp bridge: %w", err)
+		return nil, fmt.Errorf("failed to connect to WhatsApp bridge: %w", err)
 	}
 
 	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
 	c.conn = conn
 	c.connected = true
 	c.mu.Unlock()
 
-	c.setRunning(true)
-	log.Println("WhatsApp channel connected")
-
-	go c.listen(ctx)
-
-	return nil
+	return conn, nil
 }
 
 func (c *WhatsAppChannel) Stop(ctx context.Context) error {
 	log.Println("Stopping WhatsApp channel...")
+	c.BaseChannel.Stop(ctx)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -72,7 +87,6 @@ func (c *WhatsAppChannel) Stop(ctx context.Context) error {
 	}
 
 	c.connected = false
-	c.setRunning(false)
 
 	return nil
 }
@@ -120,8 +134,17 @@ func (c *WhatsAppChannel) listen(ctx context.Context) {
 
 			_, message, err := conn.ReadMessage()
 			if err != nil {
+				if !c.IsRunning() {
+					return
+				}
 				log.Printf("WhatsApp read error: %v", err)
-				time.Sleep(2 * time.Second)
+				c.mu.Lock()
+				c.connected = false
+				c.mu.Unlock()
+				if err := c.Reconnect(ctx, DefaultReconnectPolicy, c.connect); err != nil {
+					log.Printf("WhatsApp channel disconnected: %v", err)
+					return
+				}
 				continue
 			}
 
@@ -137,7 +160,7 @@ func (c *WhatsAppChannel) listen(ctx context.Context) {
 			}
 
 			if msgType == "message" {
-				c.handleIncomingMessage(msg)
+				c.Track(func() { c.handleIncomingMessage(msg) })
 			}
 		}
 	}
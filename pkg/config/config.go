@@ -16,9 +16,20 @@ type Config struct {
 	Gateway   GatewayConfig   `json:"gateway"`
 	Tools     ToolsConfig     `json:"tools"`
 	Memory    MemoryConfig    `json:"memory"`
+	Tracing   TracingConfig   `json:"tracing"`
 	mu        sync.RWMutex
 }
 
+// TracingConfig exports spans for memory, provider and tool calls to an
+// OpenTelemetry collector over OTLP/HTTP.
+type TracingConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_TRACING_ENABLED"`
+	// Endpoint is the collector URL, e.g. "http://localhost:4318". Empty
+	// falls back to the standard OTEL_EXPORTER_OTLP_* variables.
+	Endpoint    string `json:"endpoint" env:"PICOCLAW_TRACING_ENDPOINT"`
+	ServiceName string `json:"service_name" env:"PICOCLAW_TRACING_SERVICE_NAME"`
+}
+
 type MemoryConfig struct {
 	MemDB MemDBConfig `json:"memdb"`
 }
@@ -29,6 +40,59 @@ type MemDBConfig struct {
 	UserID  string `json:"user_id" env:"PICOCLAW_MEMORY_MEMDB_USER_ID"`
 	CubeID  string `json:"cube_id" env:"PICOCLAW_MEMORY_MEMDB_CUBE_ID"`
 	Secret  string `json:"secret" env:"PICOCLAW_MEMORY_MEMDB_SECRET"`
+	// SecretFile or SecretEnv name a file or environment variable that is
+	// re-read for the secret (at most every 30s) so it can be rotated
+	// without a restart. Secret is used if neither can be read.
+	SecretFile string `json:"secret_file,omitempty" env:"PICOCLAW_MEMORY_MEMDB_SECRET_FILE"`
+	SecretEnv  string `json:"secret_env,omitempty" env:"PICOCLAW_MEMORY_MEMDB_SECRET_ENV"`
+	// PerUser keeps a separate memory pool for each sender, keyed by the
+	// channel's resolved identity or, without one, by "channel:sender",
+	// instead of sharing UserID.
+	PerUser bool `json:"per_user" env:"PICOCLAW_MEMORY_MEMDB_PER_USER"`
+	// Gzip compresses request bodies of at least GzipMinBytes (default
+	// 1 KiB). Only enable it if the MemDB server accepts
+	// Content-Encoding: gzip.
+	Gzip         bool `json:"gzip" env:"PICOCLAW_MEMORY_MEMDB_GZIP"`
+	GzipMinBytes int  `json:"gzip_min_bytes" env:"PICOCLAW_MEMORY_MEMDB_GZIP_MIN_BYTES"`
+	// Debug logs MemDB request and response bodies, masking the secret and
+	// the values of JSON fields listed in RedactFields.
+	Debug        bool     `json:"debug" env:"PICOCLAW_MEMORY_MEMDB_DEBUG"`
+	RedactFields []string `json:"redact_fields" env:"PICOCLAW_MEMORY_MEMDB_REDACT_FIELDS"`
+	// TopK and Relativity bound MemDB searches: at most TopK memories with
+	// a relevance score of at least Relativity (0-1) are returned.
+	TopK       int     `json:"top_k" env:"PICOCLAW_MEMORY_MEMDB_TOP_K"`
+	Relativity float64 `json:"relativity" env:"PICOCLAW_MEMORY_MEMDB_RELATIVITY"`
+	// MaxItemChars caps each memory shown in the prompt, cut at a word
+	// boundary with an ellipsis. 0 means no limit.
+	MaxItemChars int `json:"max_item_chars" env:"PICOCLAW_MEMORY_MEMDB_MAX_ITEM_CHARS"`
+	// RecencyHalfLifeDays re-ranks memories by age: a memory's score halves
+	// for every this many days since the timestamp in its metadata, so
+	// recent preferences win over older, similar ones. 0 disables it.
+	RecencyHalfLifeDays float64 `json:"recency_half_life_days,omitempty" env:"PICOCLAW_MEMORY_MEMDB_RECENCY_HALF_LIFE_DAYS"`
+	// StoreMode is MemDB's extraction mode: "fast" or the slower, more
+	// thorough "fine".
+	StoreMode string `json:"store_mode" env:"PICOCLAW_MEMORY_MEMDB_STORE_MODE"`
+	// TLS client certificate and key for MemDB servers that require mutual
+	// TLS, and an optional CA bundle to verify the server.
+	TLSCertFile string `json:"tls_cert_file,omitempty" env:"PICOCLAW_MEMORY_MEMDB_TLS_CERT_FILE"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty" env:"PICOCLAW_MEMORY_MEMDB_TLS_KEY_FILE"`
+	TLSCAFile   string `json:"tls_ca_file,omitempty" env:"PICOCLAW_MEMORY_MEMDB_TLS_CA_FILE"`
+	// DownCooldownSeconds is how long searches are skipped after MemDB was
+	// found unreachable (default 30, negative disables).
+	DownCooldownSeconds int `json:"down_cooldown_seconds,omitempty" env:"PICOCLAW_MEMORY_MEMDB_DOWN_COOLDOWN_SECONDS"`
+	// PathPrefix is where the MemDB API is mounted (default "/product").
+	// SearchPath, AddPath and HealthPath override single endpoints.
+	PathPrefix string `json:"path_prefix,omitempty" env:"PICOCLAW_MEMORY_MEMDB_PATH_PREFIX"`
+	SearchPath string `json:"search_path,omitempty" env:"PICOCLAW_MEMORY_MEMDB_SEARCH_PATH"`
+	AddPath    string `json:"add_path,omitempty" env:"PICOCLAW_MEMORY_MEMDB_ADD_PATH"`
+	HealthPath string `json:"health_path,omitempty" env:"PICOCLAW_MEMORY_MEMDB_HEALTH_PATH"`
+	// StoreEveryTurns sends buffered conversation to MemDB after this many
+	// user turns in a session.
+	StoreEveryTurns int `json:"store_every_turns" env:"PICOCLAW_MEMORY_MEMDB_STORE_EVERY_TURNS"`
+	// StoreIntervalSeconds sends a session's buffered conversation once this
+	// long has passed since it started buffering, even if StoreEveryTurns
+	// hasn't been reached. 0 disables the time trigger.
+	StoreIntervalSeconds int `json:"store_interval_seconds" env:"PICOCLAW_MEMORY_MEMDB_STORE_INTERVAL_SECONDS"`
 }
 
 type AgentsConfig struct {
@@ -36,11 +100,49 @@ type AgentsConfig struct {
 }
 
 type AgentDefaults struct {
-	Workspace         string  `json:"workspace" env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
-	Model             string  `json:"model" env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
-	MaxTokens         int     `json:"max_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
-	Temperature       float64 `json:"temperature" env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
-	MaxToolIterations int     `json:"max_tool_iterations" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	Workspace         string   `json:"workspace" env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
+	Model             string   `json:"model" env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
+	Provider          string   `json:"provider,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
+	Fallbacks         []string `json:"fallbacks,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_FALLBACKS"`
+	MaxTokens         int      `json:"max_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
+	Temperature       float64  `json:"temperature" env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
+	MaxToolIterations int      `json:"max_tool_iterations" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	// MaxParallelTools caps how many independent tool calls from one turn
+	// run at once. 1 runs them sequentially.
+	MaxParallelTools int `json:"max_parallel_tools" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOLS"`
+	// MaxHistoryTurns and MaxHistoryTokens bound the conversation history
+	// sent with each request. 0 disables the limit.
+	MaxHistoryTurns  int `json:"max_history_turns" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_HISTORY_TURNS"`
+	MaxHistoryTokens int `json:"max_history_tokens" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_HISTORY_TOKENS"`
+	// ThinkingBudget caps reasoning tokens on models that support it (Gemini
+	// 2.5). 0 disables thinking; unset leaves the model default.
+	ThinkingBudget *int `json:"thinking_budget,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_THINKING_BUDGET"`
+	// GoogleSearch grounds Gemini answers with Google Search and appends
+	// their sources. Gemini can't combine it with function calling, so it
+	// only applies to requests sent without tools.
+	GoogleSearch bool `json:"google_search,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_GOOGLE_SEARCH"`
+	// ResponseCacheTTLSeconds caches responses to temperature-0 requests
+	// for this long. 0 disables the cache.
+	ResponseCacheTTLSeconds int `json:"response_cache_ttl_seconds,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_RESPONSE_CACHE_TTL_SECONDS"`
+	// BusyPolicy is what happens to a message that arrives while the same
+	// conversation is still busy: "queue" (default) or "drop".
+	BusyPolicy string `json:"busy_policy,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_BUSY_POLICY"`
+	// LLMTimeoutSeconds bounds each provider call and ToolTimeoutSeconds
+	// each tool call, independently of one another. 0 disables the limit;
+	// exec keeps its own timeout either way.
+	LLMTimeoutSeconds  int `json:"llm_timeout_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_LLM_TIMEOUT_SECONDS"`
+	ToolTimeoutSeconds int `json:"tool_timeout_seconds" env:"PICOCLAW_AGENTS_DEFAULTS_TOOL_TIMEOUT_SECONDS"`
+	// ModelPrices maps model names (or name prefixes) to their prices, used
+	// to estimate request cost. MaxRequestCostUSD refuses any single call
+	// whose estimated input cost exceeds it; 0 disables the check.
+	ModelPrices       map[string]ModelPrice `json:"model_prices,omitempty"`
+	MaxRequestCostUSD float64               `json:"max_request_cost_usd,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_REQUEST_COST_USD"`
+}
+
+// ModelPrice is a model's price in US dollars per million tokens.
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
 }
 
 type ChannelsConfig struct {
@@ -49,18 +151,72 @@ type ChannelsConfig struct {
 	Feishu   FeishuConfig   `json:"feishu"`
 	Discord  DiscordConfig  `json:"discord"`
 	MaixCam  MaixCamConfig  `json:"maixcam"`
+	Web      WebConfig      `json:"web"`
+	// Identities maps "channel:sender_id" to a canonical user identity, so
+	// the same person is recognised across channels.
+	Identities map[string]string `json:"identities"`
+	// Formats selects the markup replies are converted to, keyed by channel
+	// name: "markdown" (unchanged, the default) or "slack". Telegram's is
+	// set by its parse_mode.
+	Formats map[string]string `json:"formats"`
+}
+
+// MaxMessageLengths returns each channel's outbound message length limit,
+// in characters, keyed by channel name. Longer replies are split into
+// several messages; channels with a limit of 0 are omitted and never split.
+func (c ChannelsConfig) MaxMessageLengths() map[string]int {
+	limits := make(map[string]int)
+	for name, limit := range map[string]int{
+		"whatsapp": c.WhatsApp.MaxMessageLength,
+		"telegram": c.Telegram.MaxMessageLength,
+		"feishu":   c.Feishu.MaxMessageLength,
+		"discord":  c.Discord.MaxMessageLength,
+		"maixcam":  c.MaixCam.MaxMessageLength,
+		"web":      c.Web.MaxMessageLength,
+	} {
+		if limit > 0 {
+			limits[name] = limit
+		}
+	}
+	return limits
+}
+
+// SystemPrompts returns the configured system prompt overrides keyed by
+// channel name. Channels without one are omitted.
+func (c ChannelsConfig) SystemPrompts() map[string]string {
+	prompts := make(map[string]string)
+	for name, prompt := range map[string]string{
+		"whatsapp": c.WhatsApp.SystemPrompt,
+		"telegram": c.Telegram.SystemPrompt,
+		"feishu":   c.Feishu.SystemPrompt,
+		"discord":  c.Discord.SystemPrompt,
+		"maixcam":  c.MaixCam.SystemPrompt,
+		"web":      c.Web.SystemPrompt,
+	} {
+		if prompt != "" {
+			prompts[name] = prompt
+		}
+	}
+	return prompts
 }
 
 type WhatsAppConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_WHATSAPP_ENABLED"`
-	BridgeURL string   `json:"bridge_url" env:"PICOCLAW_CHANNELS_WHATSAPP_BRIDGE_URL"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_WHATSAPP_ALLOW_FROM"`
+	Enabled          bool     `json:"enabled" env:"PICOCLAW_CHANNELS_WHATSAPP_ENABLED"`
+	BridgeURL        string   `json:"bridge_url" env:"PICOCLAW_CHANNELS_WHATSAPP_BRIDGE_URL"`
+	AllowFrom        []string `json:"allow_from" env:"PICOCLAW_CHANNELS_WHATSAPP_ALLOW_FROM"`
+	SystemPrompt     string   `json:"system_prompt,omitempty" env:"PICOCLAW_CHANNELS_WHATSAPP_SYSTEM_PROMPT"`
+	MaxMessageLength int      `json:"max_message_length" env:"PICOCLAW_CHANNELS_WHATSAPP_MAX_MESSAGE_LENGTH"`
 }
 
 type TelegramConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
-	Token     string   `json:"token" env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	Enabled          bool     `json:"enabled" env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
+	Token            string   `json:"token" env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
+	AllowFrom        []string `json:"allow_from" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	SystemPrompt     string   `json:"system_prompt,omitempty" env:"PICOCLAW_CHANNELS_TELEGRAM_SYSTEM_PROMPT"`
+	MaxMessageLength int      `json:"max_message_length" env:"PICOCLAW_CHANNELS_TELEGRAM_MAX_MESSAGE_LENGTH"`
+	// ParseMode is how replies are formatted: "html" (default) or
+	// "markdownv2".
+	ParseMode string `json:"parse_mode,omitempty" env:"PICOCLAW_CHANNELS_TELEGRAM_PARSE_MODE"`
 }
 
 type FeishuConfig struct {
@@ -70,19 +226,42 @@ type FeishuConfig struct {
 	EncryptKey        string   `json:"encrypt_key" env:"PICOCLAW_CHANNELS_FEISHU_ENCRYPT_KEY"`
 	VerificationToken string   `json:"verification_token" env:"PICOCLAW_CHANNELS_FEISHU_VERIFICATION_TOKEN"`
 	AllowFrom         []string `json:"allow_from" env:"PICOCLAW_CHANNELS_FEISHU_ALLOW_FROM"`
+	SystemPrompt      string   `json:"system_prompt,omitempty" env:"PICOCLAW_CHANNELS_FEISHU_SYSTEM_PROMPT"`
+	MaxMessageLength  int      `json:"max_message_length" env:"PICOCLAW_CHANNELS_FEISHU_MAX_MESSAGE_LENGTH"`
 }
 
 type DiscordConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
-	Token     string   `json:"token" env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
+	Enabled          bool     `json:"enabled" env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
+	Token            string   `json:"token" env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
+	AllowFrom        []string `json:"allow_from" env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
+	SystemPrompt     string   `json:"system_prompt,omitempty" env:"PICOCLAW_CHANNELS_DISCORD_SYSTEM_PROMPT"`
+	MaxMessageLength int      `json:"max_message_length" env:"PICOCLAW_CHANNELS_DISCORD_MAX_MESSAGE_LENGTH"`
 }
 
 type MaixCamConfig struct {
-	Enabled   bool     `json:"enabled" env:"PICOCLAW_CHANNELS_MAIXCAM_ENABLED"`
-	Host      string   `json:"host" env:"PICOCLAW_CHANNELS_MAIXCAM_HOST"`
-	Port      int      `json:"port" env:"PICOCLAW_CHANNELS_MAIXCAM_PORT"`
-	AllowFrom []string `json:"allow_from" env:"PICOCLAW_CHANNELS_MAIXCAM_ALLOW_FROM"`
+	Enabled          bool     `json:"enabled" env:"PICOCLAW_CHANNELS_MAIXCAM_ENABLED"`
+	Host             string   `json:"host" env:"PICOCLAW_CHANNELS_MAIXCAM_HOST"`
+	Port             int      `json:"port" env:"PICOCLAW_CHANNELS_MAIXCAM_PORT"`
+	AllowFrom        []string `json:"allow_from" env:"PICOCLAW_CHANNELS_MAIXCAM_ALLOW_FROM"`
+	SystemPrompt     string   `json:"system_prompt,omitempty" env:"PICOCLAW_CHANNELS_MAIXCAM_SYSTEM_PROMPT"`
+	MaxMessageLength int      `json:"max_message_length" env:"PICOCLAW_CHANNELS_MAIXCAM_MAX_MESSAGE_LENGTH"`
+}
+
+// WebConfig configures the WebSocket channel used to embed picoclaw in a
+// web app.
+type WebConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_CHANNELS_WEB_ENABLED"`
+	Host    string `json:"host" env:"PICOCLAW_CHANNELS_WEB_HOST"`
+	Port    int    `json:"port" env:"PICOCLAW_CHANNELS_WEB_PORT"`
+	// Tokens maps each session token a client may present to the sender ID
+	// it authenticates as, which is what AllowFrom matches.
+	Tokens    map[string]string `json:"tokens"`
+	AllowFrom []string          `json:"allow_from" env:"PICOCLAW_CHANNELS_WEB_ALLOW_FROM"`
+	// AllowedOrigins lists the browser origins that may connect. When empty
+	// only same-origin pages may.
+	AllowedOrigins   []string `json:"allowed_origins,omitempty" env:"PICOCLAW_CHANNELS_WEB_ALLOWED_ORIGINS"`
+	SystemPrompt     string   `json:"system_prompt,omitempty" env:"PICOCLAW_CHANNELS_WEB_SYSTEM_PROMPT"`
+	MaxMessageLength int      `json:"max_message_length" env:"PICOCLAW_CHANNELS_WEB_MAX_MESSAGE_LENGTH"`
 }
 
 type ProvidersConfig struct {
@@ -98,6 +277,10 @@ type ProvidersConfig struct {
 type ProviderConfig struct {
 	APIKey  string `json:"api_key" env:"PICOCLAW_PROVIDERS_{{.Name}}_API_KEY"`
 	APIBase string `json:"api_base" env:"PICOCLAW_PROVIDERS_{{.Name}}_API_BASE"`
+	Proxy   string `json:"proxy,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_PROXY"`
+	// APIVersion selects the API version segment for providers that have
+	// one (Gemini: "v1beta" by default).
+	APIVersion string `json:"api_version,omitempty" env:"PICOCLAW_PROVIDERS_{{.Name}}_API_VERSION"`
 }
 
 type GatewayConfig struct {
@@ -110,35 +293,96 @@ type WebSearchConfig struct {
 	MaxResults int    `json:"max_results" env:"PICOCLAW_TOOLS_WEB_SEARCH_MAX_RESULTS"`
 }
 
+type WebFetchConfig struct {
+	// AllowPrivateNetworks lets web_fetch reach loopback and private
+	// addresses. Only enable it in trusted environments.
+	AllowPrivateNetworks bool `json:"allow_private_networks,omitempty" env:"PICOCLAW_TOOLS_WEB_FETCH_ALLOW_PRIVATE_NETWORKS"`
+}
+
 type WebToolsConfig struct {
 	Search WebSearchConfig `json:"search"`
+	Fetch  WebFetchConfig  `json:"fetch"`
+}
+
+type ExecToolConfig struct {
+	MaxOutputLen int      `json:"max_output_len" env:"PICOCLAW_TOOLS_EXEC_MAX_OUTPUT_LEN"`
+	DenyPatterns []string `json:"deny_patterns,omitempty" env:"PICOCLAW_TOOLS_EXEC_DENY_PATTERNS"`
+	// DenyRules are deny patterns with a severity: "block" (the default)
+	// refuses matching commands, "warn" logs them and notes the match in
+	// the output but lets them run.
+	DenyRules []ExecDenyRule `json:"deny_rules,omitempty"`
+	// DefaultWorkingDir is where commands run when there is no workspace
+	// and the call gives no working_dir. Without it they run in the
+	// process's current directory.
+	DefaultWorkingDir string `json:"default_working_dir,omitempty" env:"PICOCLAW_TOOLS_EXEC_DEFAULT_WORKING_DIR"`
+	// AllowPatternsFile points to a file with one allowed-command regex per line.
+	AllowPatternsFile string `json:"allow_patterns_file,omitempty" env:"PICOCLAW_TOOLS_EXEC_ALLOW_PATTERNS_FILE"`
+	// ExtraPathWhitelist lists paths outside the workspace that a single
+	// command may be granted via its extra_paths argument.
+	ExtraPathWhitelist []string `json:"extra_path_whitelist,omitempty" env:"PICOCLAW_TOOLS_EXEC_EXTRA_PATH_WHITELIST"`
+	// DisableGuard turns off every exec safety check (deny and allow
+	// patterns, workspace restriction). DANGEROUS: only for trusted
+	// single-user machines.
+	DisableGuard bool `json:"disable_guard,omitempty" env:"PICOCLAW_TOOLS_EXEC_DISABLE_GUARD"`
+	// RedactPatterns are regexes whose matches are replaced with
+	// [REDACTED] in command output, in addition to built-in secret formats
+	// (tokens, API keys, JWTs, private keys, long hex strings). A group
+	// named "secret" limits redaction to that group.
+	RedactPatterns []string `json:"redact_patterns,omitempty" env:"PICOCLAW_TOOLS_EXEC_REDACT_PATTERNS"`
+	// DisableRedaction returns command output unredacted.
+	DisableRedaction bool `json:"disable_redaction,omitempty" env:"PICOCLAW_TOOLS_EXEC_DISABLE_REDACTION"`
+	// MaxConcurrent limits how many commands run at once; 0 removes the
+	// limit. Excess commands wait up to QueueTimeoutSeconds for a slot and
+	// are then rejected.
+	MaxConcurrent       int `json:"max_concurrent" env:"PICOCLAW_TOOLS_EXEC_MAX_CONCURRENT"`
+	QueueTimeoutSeconds int `json:"queue_timeout_seconds" env:"PICOCLAW_TOOLS_EXEC_QUEUE_TIMEOUT_SECONDS"`
+}
+
+// ExecDenyRule is a deny pattern and its severity, "block" or "warn".
+type ExecDenyRule struct {
+	Pattern  string `json:"pattern"`
+	Severity string `json:"severity,omitempty"`
 }
 
 type ToolsConfig struct {
-	Web WebToolsConfig `json:"web"`
+	Web  WebToolsConfig `json:"web"`
+	Exec ExecToolConfig `json:"exec"`
+	// IgnorePatterns are gitignore-style patterns that grep and list_dir
+	// skip in addition to any .gitignore files.
+	IgnorePatterns []string `json:"ignore_patterns,omitempty" env:"PICOCLAW_TOOLS_IGNORE_PATTERNS"`
+	// MaxResultChars caps every tool result sent to the model. Longer
+	// results are saved under workspace/tool_output and replaced by a
+	// preview and the file's path. 0 disables the cap.
+	MaxResultChars int `json:"max_result_chars" env:"PICOCLAW_TOOLS_MAX_RESULT_CHARS"`
 }
 
 func DefaultConfig() *Config {
 	return &Config{
 		Agents: AgentsConfig{
 			Defaults: AgentDefaults{
-				Workspace:         "~/.picoclaw/workspace",
-				Model:             "glm-4.7",
-				MaxTokens:         8192,
-				Temperature:       0.7,
-				MaxToolIterations: 20,
+				Workspace:          "~/.picoclaw/workspace",
+				Model:              "glm-4.7",
+				MaxTokens:          8192,
+				Temperature:        0.7,
+				MaxToolIterations:  20,
+				MaxParallelTools:   4,
+				MaxHistoryTurns:    50,
+				ToolTimeoutSeconds: 120,
 			},
 		},
 		Channels: ChannelsConfig{
 			WhatsApp: WhatsAppConfig{
-				Enabled:   false,
-				BridgeURL: "ws://localhost:3001",
-				AllowFrom: []string{},
+				Enabled:          false,
+				BridgeURL:        "ws://localhost:3001",
+				AllowFrom:        []string{},
+				MaxMessageLength: 65536,
 			},
 			Telegram: TelegramConfig{
 				Enabled:   false,
 				Token:     "",
 				AllowFrom: []string{},
+				// Telegram allows 4096 after Markdown is converted to HTML.
+				MaxMessageLength: 4000,
 			},
 			Feishu: FeishuConfig{
 				Enabled:           false,
@@ -147,11 +391,13 @@ func DefaultConfig() *Config {
 				EncryptKey:        "",
 				VerificationToken: "",
 				AllowFrom:         []string{},
+				MaxMessageLength:  30000,
 			},
 			Discord: DiscordConfig{
-				Enabled:   false,
-				Token:     "",
-				AllowFrom: []string{},
+				Enabled:          false,
+				Token:            "",
+				AllowFrom:        []string{},
+				MaxMessageLength: 2000,
 			},
 			MaixCam: MaixCamConfig{
 				Enabled:   false,
@@ -159,6 +405,13 @@ func DefaultConfig() *Config {
 				Port:      18790,
 				AllowFrom: []string{},
 			},
+			Web: WebConfig{
+				Enabled:   false,
+				Host:      "127.0.0.1",
+				Port:      18791,
+				Tokens:    map[string]string{},
+				AllowFrom: []string{},
+			},
 		},
 		Providers: ProvidersConfig{
 			Anthropic:  ProviderConfig{},
@@ -180,15 +433,29 @@ func DefaultConfig() *Config {
 					MaxResults: 5,
 				},
 			},
+			Exec: ExecToolConfig{
+				MaxOutputLen:        10000,
+				MaxConcurrent:       2,
+				QueueTimeoutSeconds: 30,
+			},
+			MaxResultChars: 30000,
 		},
 		Memory: MemoryConfig{
 			MemDB: MemDBConfig{
-				Enabled: false,
-				URL:     "http://127.0.0.1:8080",
-				UserID:  "memos",
-				CubeID:  "memos",
+				Enabled:              false,
+				URL:                  "http://127.0.0.1:8080",
+				UserID:               "memos",
+				CubeID:               "memos",
+				TopK:                 8,
+				Relativity:           0.85,
+				StoreMode:            "fast",
+				StoreEveryTurns:      1,
+				StoreIntervalSeconds: 0,
 			},
 		},
+		Tracing: TracingConfig{
+			ServiceName: "picoclaw",
+		},
 	}
 }
 
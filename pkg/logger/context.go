@@ -0,0 +1,66 @@
+package logger
+
+import "context"
+
+type ctxFieldsKey struct{}
+
+// With returns a copy of ctx carrying fields in addition to any already
+// attached. Fields attached this way are added to every line logged with the
+// *Ctx functions, so one conversation's logs can be correlated across
+// channels, providers and tools.
+func With(ctx context.Context, fields map[string]interface{}) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	parent := FieldsFrom(ctx)
+	merged := make(map[string]interface{}, len(parent)+len(fields))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, ctxFieldsKey{}, merged)
+}
+
+// FieldsFrom returns the fields attached to ctx with With, or nil.
+func FieldsFrom(ctx context.Context) map[string]interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(ctxFieldsKey{}).(map[string]interface{})
+	return fields
+}
+
+// mergeCtxFields combines context fields with call-site fields; the latter
+// win on conflicts.
+func mergeCtxFields(ctx context.Context, fields map[string]interface{}) map[string]interface{} {
+	ctxFields := FieldsFrom(ctx)
+	if len(ctxFields) == 0 {
+		return fields
+	}
+	merged := make(map[string]interface{}, len(ctxFields)+len(fields))
+	for k, v := range ctxFields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return merged
+}
+
+func DebugCtx(ctx context.Context, component string, message string, fields map[string]interface{}) {
+	logMessage(DEBUG, component, message, mergeCtxFields(ctx, fields))
+}
+
+func InfoCtx(ctx context.Context, component string, message string, fields map[string]interface{}) {
+	logMessage(INFO, component, message, mergeCtxFields(ctx, fields))
+}
+
+func WarnCtx(ctx context.Context, component string, message string, fields map[string]interface{}) {
+	logMessage(WARN, component, message, mergeCtxFields(ctx, fields))
+}
+
+func ErrorCtx(ctx context.Context, component string, message string, fields map[string]interface{}) {
+	logMessage(ERROR, component, message, mergeCtxFields(ctx, fields))
+}
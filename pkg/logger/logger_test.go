@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"testing"
 )
 
@@ -137,3 +138,30 @@ func TestLoggerHelperFunctions(t *testing.T) {
 	DebugC("test", "Debug with component")
 	WarnF("Warning with fields", map[string]interface{}{"key": "value"})
 }
+
+func TestContextFields(t *testing.T) {
+	ctx := With(context.Background(), map[string]interface{}{"session_key": "telegram:1", "sender_id": "42"})
+	ctx = With(ctx, map[string]interface{}{"trace_id": "abc", "sender_id": "43"})
+
+	got := mergeCtxFields(ctx, map[string]interface{}{"tool": "exec", "trace_id": "override"})
+	want := map[string]interface{}{
+		"session_key": "telegram:1",
+		"sender_id":   "43",
+		"trace_id":    "override",
+		"tool":        "exec",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("mergeCtxFields() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("field %q = %v, want %v", k, got[k], v)
+		}
+	}
+
+	if fields := FieldsFrom(context.Background()); fields != nil {
+		t.Errorf("FieldsFrom(empty ctx) = %v, want nil", fields)
+	}
+
+	InfoCtx(ctx, "test", "Context message", nil)
+}
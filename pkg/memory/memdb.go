@@ -2,24 +2,54 @@ package memory
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tracing"
 )
 
 // MemDBClient is an HTTP client for the MemDB memory API.
+//
+// A MemDBClient is safe for concurrent use by multiple goroutines and is
+// meant to be shared process-wide. Its configuration is set by
+// NewMemDBClient and never modified afterwards; per-call state such as the
+// user ID is passed as arguments. The only mutable state, the readiness
+// and secret caches, is guarded by their own mutexes, as must be anything
+// added later.
 type MemDBClient struct {
 	apiURL     string
 	userID     string
 	cubeID     string
-	secret     string
+	secret     *secretSource
 	httpClient *http.Client
+	// gzipMinBytes is the request body size from which bodies are gzipped;
+	// 0 disables compression.
+	gzipMinBytes int
+	topK         int
+	storeMode    string
+	relativity   float64
+	maxItemChars int
+	// recencyHalfLife enables re-ranking by age; see
+	// SearchResult.ApplyRecencyDecay.
+	recencyHalfLife time.Duration
+	// debug logs request and response bodies, masking redactFields.
+	debug        bool
+	redactFields map[string]bool
+	ready        readiness
+	searchPath   string
+	addPath      string
+	healthPath   string
 }
 
 // MemDBConfig holds configuration for the MemDB client.
@@ -29,13 +59,143 @@ type MemDBConfig struct {
 	UserID  string `json:"user_id" env:"PICOCLAW_MEMORY_MEMDB_USER_ID"`
 	CubeID  string `json:"cube_id" env:"PICOCLAW_MEMORY_MEMDB_CUBE_ID"`
 	Secret  string `json:"secret" env:"PICOCLAW_MEMORY_MEMDB_SECRET"`
+	// SecretProvider, when set, supplies the secret at request time so it
+	// can be rotated; Secret is the fallback until it first succeeds.
+	// Fetched secrets are reused for SecretTTL (default 30s).
+	SecretProvider SecretProvider `json:"-"`
+	SecretTTL      time.Duration  `json:"-"`
+	// Gzip compresses request bodies of at least GzipMinBytes (default
+	// 1 KiB) with Content-Encoding: gzip. The server must accept it.
+	Gzip         bool `json:"gzip" env:"PICOCLAW_MEMORY_MEMDB_GZIP"`
+	GzipMinBytes int  `json:"gzip_min_bytes" env:"PICOCLAW_MEMORY_MEMDB_GZIP_MIN_BYTES"`
+	// Debug logs every request and response body. The secret header is
+	// always masked, as are the values of any JSON field named in
+	// RedactFields (e.g. "content", "query"). Keep it off in production.
+	Debug        bool     `json:"debug" env:"PICOCLAW_MEMORY_MEMDB_DEBUG"`
+	RedactFields []string `json:"redact_fields" env:"PICOCLAW_MEMORY_MEMDB_REDACT_FIELDS"`
+	// TopK is the maximum number of memories a search returns (default 8).
+	TopK int `json:"top_k" env:"PICOCLAW_MEMORY_MEMDB_TOP_K"`
+	// Relativity is the minimum relevance score, from 0 to 1, of returned
+	// memories (default 0.85).
+	Relativity float64 `json:"relativity" env:"PICOCLAW_MEMORY_MEMDB_RELATIVITY"`
+	// MaxItemChars caps the length of each memory in formatted results, so
+	// one long memory cannot crowd out the rest. 0 means no limit.
+	MaxItemChars int `json:"max_item_chars" env:"PICOCLAW_MEMORY_MEMDB_MAX_ITEM_CHARS"`
+	// RecencyHalfLife, when positive, re-ranks search results so a memory's
+	// score halves for every RecencyHalfLife of age, going by the timestamp
+	// in its metadata. Twice TopK memories are fetched so recent ones just
+	// below the cut can move up. Memories without a timestamp are not
+	// decayed.
+	RecencyHalfLife time.Duration `json:"-"`
+	// StoreMode is the extraction mode for Store: "fast" (default) or the
+	// slower, more thorough "fine".
+	StoreMode string `json:"store_mode" env:"PICOCLAW_MEMORY_MEMDB_STORE_MODE"`
+	// TLS client certificate for servers that require mutual TLS, and an
+	// optional CA bundle to verify the server with. Load them into TLSConfig
+	// with LoadTLSConfig.
+	TLSCertFile string `json:"tls_cert_file" env:"PICOCLAW_MEMORY_MEMDB_TLS_CERT_FILE"`
+	TLSKeyFile  string `json:"tls_key_file" env:"PICOCLAW_MEMORY_MEMDB_TLS_KEY_FILE"`
+	TLSCAFile   string `json:"tls_ca_file" env:"PICOCLAW_MEMORY_MEMDB_TLS_CA_FILE"`
+	// TLSConfig, when set, is used for HTTPS connections to MemDB.
+	TLSConfig *tls.Config `json:"-"`
+	// DownCooldownSeconds is how long Search returns no memories without
+	// calling MemDB after it was found unreachable (default 30). A negative
+	// value disables the shortcut.
+	DownCooldownSeconds int `json:"down_cooldown_seconds" env:"PICOCLAW_MEMORY_MEMDB_DOWN_COOLDOWN_SECONDS"`
+	// PathPrefix is where the memory API is mounted under URL (default
+	// "/product"); search and add live beneath it. SearchPath, AddPath and
+	// HealthPath override individual endpoints with full paths (health
+	// defaults to "/health", outside the prefix).
+	PathPrefix string `json:"path_prefix" env:"PICOCLAW_MEMORY_MEMDB_PATH_PREFIX"`
+	SearchPath string `json:"search_path" env:"PICOCLAW_MEMORY_MEMDB_SEARCH_PATH"`
+	AddPath    string `json:"add_path" env:"PICOCLAW_MEMORY_MEMDB_ADD_PATH"`
+	HealthPath string `json:"health_path" env:"PICOCLAW_MEMORY_MEMDB_HEALTH_PATH"`
 }
 
+// defaultPathPrefix is where MemDB mounts its product API.
+const defaultPathPrefix = "/product"
+
+// endpointPath returns override if set, otherwise prefix joined with name.
+// Both are normalized to start with a single slash.
+func endpointPath(override, prefix, name string) string {
+	if override != "" {
+		return "/" + strings.TrimLeft(override, "/")
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return "/" + name
+	}
+	return "/" + prefix + "/" + name
+}
+
+// LoadTLSConfig builds cfg.TLSConfig from the certificate files, if any are
+// configured. A client certificate needs both TLSCertFile and TLSKeyFile.
+func (cfg *MemDBConfig) LoadTLSConfig() error {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" && cfg.TLSCAFile == "" {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("load MemDB client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return fmt.Errorf("read MemDB CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in MemDB CA file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	cfg.TLSConfig = tlsConfig
+	return nil
+}
+
+// Extraction modes accepted by MemDB's /product/add.
+const (
+	StoreModeFast = "fast"
+	StoreModeFine = "fine"
+)
+
+// ValidateStoreMode returns an error unless mode is a store mode MemDB
+// accepts.
+func ValidateStoreMode(mode string) error {
+	switch mode {
+	case StoreModeFast, StoreModeFine:
+		return nil
+	}
+	return fmt.Errorf("invalid MemDB store mode %q (want %q or %q)", mode, StoreModeFast, StoreModeFine)
+}
+
+// Search defaults used when TopK or Relativity are unset.
+const (
+	defaultTopK       = 8
+	defaultRelativity = 0.85
+)
+
+// defaultGzipMinBytes is the compression threshold when Gzip is on and
+// GzipMinBytes is unset. Smaller bodies gain little from compression.
+const defaultGzipMinBytes = 1024
+
 // SearchResult holds formatted search results from MemDB.
 type SearchResult struct {
 	TextMemories  []MemoryItem
 	SkillMemories []MemoryItem
 	PrefMemories  []MemoryItem
+	// MaxItemChars caps each memory in FormatForPrompt, cutting at a word
+	// boundary and adding an ellipsis. 0 means no limit.
+	MaxItemChars int
 }
 
 // MemoryItem is a single memory entry.
@@ -43,31 +203,123 @@ type MemoryItem struct {
 	ID      string
 	Content string
 	Score   float64
+	// Skill holds the structured fields of a skill memory, for callers that
+	// render them separately; Content is the flattened form used in prompts.
+	// It is nil for other memory types.
+	Skill *SkillMemory
+	// Metadata is the memory's metadata as returned by MemDB, such as its
+	// timestamps.
+	Metadata map[string]interface{}
+}
+
+// SkillMemory is a learned procedure, as stored in skill memory metadata.
+type SkillMemory struct {
+	Name        string
+	Description string
+	Procedure   string
 }
 
 // NewMemDBClient creates a new MemDB HTTP client.
 func NewMemDBClient(cfg MemDBConfig) *MemDBClient {
+	gzipMinBytes := 0
+	if cfg.Gzip {
+		gzipMinBytes = cfg.GzipMinBytes
+		if gzipMinBytes <= 0 {
+			gzipMinBytes = defaultGzipMinBytes
+		}
+	}
+
+	topK := cfg.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+	relativity := cfg.Relativity
+	if relativity <= 0 {
+		relativity = defaultRelativity
+	}
+
+	storeMode := cfg.StoreMode
+	if storeMode == "" {
+		storeMode = StoreModeFast
+	} else if err := ValidateStoreMode(storeMode); err != nil {
+		logger.WarnCF("memdb", "Falling back to fast store mode", map[string]interface{}{
+			"error": err.Error(),
+		})
+		storeMode = StoreModeFast
+	}
+
+	cooldown := defaultDownCooldown
+	if cfg.DownCooldownSeconds != 0 {
+		cooldown = time.Duration(cfg.DownCooldownSeconds) * time.Second
+	}
+
+	prefix := cfg.PathPrefix
+	if prefix == "" {
+		prefix = defaultPathPrefix
+	}
+
+	redactFields := make(map[string]bool, len(cfg.RedactFields))
+	for _, f := range cfg.RedactFields {
+		redactFields[strings.ToLower(f)] = true
+	}
+
+	secretTTL := cfg.SecretTTL
+	if secretTTL <= 0 {
+		secretTTL = defaultSecretTTL
+	}
+
 	return &MemDBClient{
-		apiURL: strings.TrimRight(cfg.URL, "/"),
-		userID: cfg.UserID,
-		cubeID: cfg.CubeID,
-		secret: cfg.Secret,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		apiURL:       strings.TrimRight(cfg.URL, "/"),
+		userID:       cfg.UserID,
+		cubeID:       cfg.CubeID,
+		secret:       &secretSource{static: cfg.Secret, provider: cfg.SecretProvider, ttl: secretTTL},
+		httpClient:   newHTTPClient(cfg.TLSConfig),
+		gzipMinBytes: gzipMinBytes,
+		topK:         topK,
+		storeMode:    storeMode,
+		relativity:   relativity,
+		maxItemChars: cfg.MaxItemChars,
+		debug:        cfg.Debug,
+		redactFields: redactFields,
+		ready:        readiness{cooldown: cooldown},
+		searchPath:   endpointPath(cfg.SearchPath, prefix, "search"),
+		addPath:      endpointPath(cfg.AddPath, prefix, "add"),
+		healthPath:   endpointPath(cfg.HealthPath, "", "health"),
+
+		recencyHalfLife: cfg.RecencyHalfLife,
 	}
 }
 
 // Search queries MemDB for memories relevant to the given query.
 func (c *MemDBClient) Search(ctx context.Context, query string) (*SearchResult, error) {
+	return c.SearchForUser(ctx, "", query)
+}
+
+// SearchForUser is Search in the memory pool of userID. An empty userID
+// uses the configured one.
+//
+// If MemDB was recently found unreachable, by Health or a failed search, it
+// returns an empty result without making a request until the cooldown
+// passes, so conversations continue without memories during an outage.
+func (c *MemDBClient) SearchForUser(ctx context.Context, userID, query string) (result *SearchResult, err error) {
+	ctx, span := tracing.Start(ctx, "memdb.search", map[string]interface{}{
+		"memdb.user_id": c.resolveUserID(userID),
+	})
+	defer func() { tracing.End(span, err) }()
+
+	if c.ready.down() {
+		logger.DebugCF("memdb", "skipping search, MemDB marked down", nil)
+		return &SearchResult{}, nil
+	}
+
 	body := map[string]interface{}{
 		"query":                query,
-		"user_id":              c.userID,
+		"user_id":              c.resolveUserID(userID),
 		"readable_cube_ids":    []string{c.cubeID},
-		"top_k":                8,
+		"top_k":                c.searchTopK(),
 		"include_skill_memory": true,
 		"dedup":                "mmr",
-		"relativity":           0.85,
+		"relativity":           c.relativity,
 	}
 
 	jsonData, err := json.Marshal(body)
@@ -75,94 +327,301 @@ func (c *MemDBClient) Search(ctx context.Context, query string) (*SearchResult,
 		return nil, fmt.Errorf("marshal search request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL+"/product/search", bytes.NewReader(jsonData))
+	req, err := c.newPostRequest(ctx, c.searchPath, jsonData)
 	if err != nil {
 		return nil, fmt.Errorf("create search request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	if c.secret != "" {
-		req.Header.Set("X-Internal-Service", c.secret)
-	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if ctx.Err() == nil {
+			c.ready.markDown()
+		}
 		return nil, fmt.Errorf("search request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 500 {
+		c.ready.markDown()
+	} else {
+		c.ready.markUp()
+	}
+
 	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 5*1024*1024)) // 5 MB max
 	if err != nil {
 		return nil, fmt.Errorf("read search response: %w", err)
 	}
+	c.logResponse(c.searchPath, resp.StatusCode, respBody)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, newMemDBError("search", resp.StatusCode, respBody)
+	}
+
+	result, err = parseSearchResponse(respBody)
+	if err != nil {
+		return nil, err
 	}
+	result.MaxItemChars = c.maxItemChars
+	result.ApplyRecencyDecay(c.recencyHalfLife, time.Now(), c.topK)
+	return result, nil
+}
 
-	return parseSearchResponse(respBody)
+// searchTopK is the number of memories to request: twice topK when results
+// are re-ranked by age, so there are candidates to promote.
+func (c *MemDBClient) searchTopK() int {
+	if c.recencyHalfLife > 0 {
+		return 2 * c.topK
+	}
+	return c.topK
 }
 
 // Store sends conversation messages to MemDB for extraction and storage.
 // This is fire-and-forget — errors are logged but not returned.
 func (c *MemDBClient) Store(ctx context.Context, messages []map[string]string) {
+	c.StoreForUser(ctx, "", messages)
+}
+
+// StoreForUser is Store into the memory pool of userID. An empty userID uses
+// the configured one.
+func (c *MemDBClient) StoreForUser(ctx context.Context, userID string, messages []map[string]string) {
+	if err := c.AddMessages(ctx, userID, messages); err != nil {
+		var apiErr *MemDBError
+		if errors.As(err, &apiErr) {
+			logger.ErrorCF("memdb", "store API error", map[string]interface{}{
+				"status": apiErr.StatusCode,
+				"code":   apiErr.Code,
+				"error":  apiErr.Error(),
+			})
+			return
+		}
+		logger.ErrorCF("memdb", "store failed", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	logger.DebugCF("memdb", "stored conversation", map[string]interface{}{
+		"messages": len(messages),
+	})
+}
+
+// AddMessages is the synchronous form of StoreForUser: it sends messages to
+// MemDB and returns any error instead of logging it.
+func (c *MemDBClient) AddMessages(ctx context.Context, userID string, messages []map[string]string) error {
+	return c.AddMessagesWithMode(ctx, userID, "", messages)
+}
+
+// AddMessagesWithMode is AddMessages with a per-call store mode, e.g.
+// StoreModeFine for a substantive session. An empty mode uses the
+// configured one.
+func (c *MemDBClient) AddMessagesWithMode(ctx context.Context, userID, mode string, messages []map[string]string) (err error) {
+	ctx, span := tracing.Start(ctx, "memdb.store", map[string]interface{}{
+		"memdb.user_id":  c.resolveUserID(userID),
+		"memdb.messages": len(messages),
+	})
+	defer func() { tracing.End(span, err) }()
+
+	if mode == "" {
+		mode = c.storeMode
+	} else if err := ValidateStoreMode(mode); err != nil {
+		return err
+	}
+
 	body := map[string]interface{}{
-		"user_id":            c.userID,
-		"writable_cube_ids":  []string{c.cubeID},
-		"messages":           messages,
-		"mode":               "fast",
+		"user_id":           c.resolveUserID(userID),
+		"writable_cube_ids": []string{c.cubeID},
+		"messages":          messages,
+		"mode":              mode,
 	}
 
 	jsonData, err := json.Marshal(body)
 	if err != nil {
-		logger.ErrorCF("memdb", "marshal store request", map[string]interface{}{"error": err.Error()})
-		return
+		return fmt.Errorf("marshal store request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL+"/product/add", bytes.NewReader(jsonData))
+	req, err := c.newPostRequest(ctx, c.addPath, jsonData)
 	if err != nil {
-		logger.ErrorCF("memdb", "create store request", map[string]interface{}{"error": err.Error()})
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if c.secret != "" {
-		req.Header.Set("X-Internal-Service", c.secret)
+		return fmt.Errorf("create store request: %w", err)
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		logger.ErrorCF("memdb", "store request failed", map[string]interface{}{"error": err.Error()})
-		return
+		return fmt.Errorf("store request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	c.logResponse(c.addPath, resp.StatusCode, respBody)
+
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		logger.ErrorCF("memdb", "store API error", map[string]interface{}{
-			"status": resp.StatusCode,
-			"body":   string(body),
-		})
-		return
+		return newMemDBError("store", resp.StatusCode, respBody)
 	}
+	return nil
+}
 
-	logger.DebugCF("memdb", "stored conversation", map[string]interface{}{
-		"messages": len(messages),
-	})
+// newPostRequest builds a JSON POST to path with the auth header set,
+// gzipping the body when it reaches the configured threshold.
+func (c *MemDBClient) newPostRequest(ctx context.Context, path string, jsonData []byte) (*http.Request, error) {
+	body := jsonData
+	compressed := false
+	if c.gzipMinBytes > 0 && len(jsonData) >= c.gzipMinBytes {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(jsonData); err != nil {
+			return nil, fmt.Errorf("gzip request body: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("gzip request body: %w", err)
+		}
+		body = buf.Bytes()
+		compressed = true
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if secret := c.secret.get(); secret != "" {
+		req.Header.Set("X-Internal-Service", secret)
+	}
+	c.logRequest(req, jsonData)
+	return req, nil
+}
+
+func newHTTPClient(tlsConfig *tls.Config) *http.Client {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		client.Transport = transport
+	}
+	return client
+}
+
+func (c *MemDBClient) resolveUserID(userID string) string {
+	if userID == "" {
+		return c.userID
+	}
+	return userID
 }
 
 // Health checks if MemDB is reachable.
 func (c *MemDBClient) Health(ctx context.Context) bool {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL+"/health", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.apiURL+c.healthPath, nil)
 	if err != nil {
 		return false
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if ctx.Err() == nil {
+			c.ready.markDown()
+		}
 		return false
 	}
 	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK
+	if resp.StatusCode != http.StatusOK {
+		c.ready.markDown()
+		return false
+	}
+	c.ready.markUp()
+	return true
+}
+
+// WaitHealthy polls Health until it succeeds, ctx is done or maxWait has
+// elapsed, and reports whether MemDB became healthy. The delay between probes
+// starts at interval and doubles after each failure, capped at 8x interval.
+// maxWait <= 0 waits until ctx is done.
+func (c *MemDBClient) WaitHealthy(ctx context.Context, interval, maxWait time.Duration) bool {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if maxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxWait)
+		defer cancel()
+	}
+
+	delay := interval
+	for attempt := 1; ; attempt++ {
+		if c.Health(ctx) {
+			return true
+		}
+
+		logger.DebugCF("memdb", "health probe failed", map[string]interface{}{
+			"attempt":  attempt,
+			"retry_in": delay.String(),
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+
+		if delay *= 2; delay > 8*interval {
+			delay = 8 * interval
+		}
+	}
+}
+
+// Dedup returns a copy of r in which each memory content appears only once,
+// keeping the first occurrence in text, skill, pref order.
+func (r *SearchResult) Dedup() *SearchResult {
+	if r == nil {
+		return &SearchResult{}
+	}
+	seen := make(map[string]bool)
+	keep := func(items []MemoryItem) []MemoryItem {
+		var out []MemoryItem
+		for _, m := range items {
+			key := strings.ToLower(strings.TrimSpace(m.Content))
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, m)
+		}
+		return out
+	}
+	return &SearchResult{
+		TextMemories:  keep(r.TextMemories),
+		SkillMemories: keep(r.SkillMemories),
+		PrefMemories:  keep(r.PrefMemories),
+		MaxItemChars:  r.MaxItemChars,
+	}
+}
+
+// Filter removes, in place, the memories for which keep returns false.
+func (r *SearchResult) Filter(keep func(MemoryItem) bool) {
+	if r == nil {
+		return
+	}
+	filter := func(items []MemoryItem) []MemoryItem {
+		out := items[:0]
+		for _, m := range items {
+			if keep(m) {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	r.TextMemories = filter(r.TextMemories)
+	r.SkillMemories = filter(r.SkillMemories)
+	r.PrefMemories = filter(r.PrefMemories)
+}
+
+// Total returns the number of memories across all categories.
+func (r *SearchResult) Total() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.TextMemories) + len(r.SkillMemories) + len(r.PrefMemories)
 }
 
 // FormatForPrompt formats search results as a text block for the system prompt.
@@ -176,7 +635,7 @@ func (r *SearchResult) FormatForPrompt() string {
 	if len(r.TextMemories) > 0 {
 		var items []string
 		for _, m := range r.TextMemories {
-			items = append(items, fmt.Sprintf("- %s", m.Content))
+			items = append(items, fmt.Sprintf("- %s", truncateWords(m.Content, r.MaxItemChars)))
 		}
 		parts = append(parts, "### Facts & Knowledge\n"+strings.Join(items, "\n"))
 	}
@@ -184,7 +643,7 @@ func (r *SearchResult) FormatForPrompt() string {
 	if len(r.SkillMemories) > 0 {
 		var items []string
 		for _, m := range r.SkillMemories {
-			items = append(items, fmt.Sprintf("- %s", m.Content))
+			items = append(items, fmt.Sprintf("- %s", truncateWords(m.Content, r.MaxItemChars)))
 		}
 		parts = append(parts, "### Skills & Procedures\n"+strings.Join(items, "\n"))
 	}
@@ -192,7 +651,7 @@ func (r *SearchResult) FormatForPrompt() string {
 	if len(r.PrefMemories) > 0 {
 		var items []string
 		for _, m := range r.PrefMemories {
-			items = append(items, fmt.Sprintf("- %s", m.Content))
+			items = append(items, fmt.Sprintf("- %s", truncateWords(m.Content, r.MaxItemChars)))
 		}
 		parts = append(parts, "### User Preferences\n"+strings.Join(items, "\n"))
 	}
@@ -204,6 +663,23 @@ func (r *SearchResult) FormatForPrompt() string {
 	return "## Relevant Memories (from MemDB)\n\n" + strings.Join(parts, "\n\n")
 }
 
+// truncateWords shortens s to at most max characters, cutting at the last
+// word boundary and appending an ellipsis. max <= 0 means no limit.
+func truncateWords(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+	cut := string(runes[:max])
+	// Drop the partial word, unless the cut fell exactly between words.
+	if next := runes[max]; next != ' ' && next != '\t' && next != '\n' {
+		if i := strings.LastIndexAny(cut, " \t\n"); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " \t\n.,;:") + "…"
+}
+
 // parseSearchResponse parses the MemDB search API response.
 // Response shape: data.text_mem[0].memories[], data.skill_mem[0].memories[], data.pref_mem[0].memories[]
 func parseSearchResponse(body []byte) (*SearchResult, error) {
@@ -249,16 +725,18 @@ func parseSearchResponse(body []byte) (*SearchResult, error) {
 				continue
 			}
 			result.TextMemories = append(result.TextMemories, MemoryItem{
-				ID:      m.ID,
-				Content: content,
-				Score:   m.Score,
+				ID:       m.ID,
+				Content:  content,
+				Score:    m.Score,
+				Metadata: m.Metadata,
 			})
 		}
 	}
 
 	if len(resp.Data.SkillMem) > 0 {
 		for _, m := range resp.Data.SkillMem[0].Memories {
-			content := formatSkillMemory(m.Metadata)
+			skill := skillMemoryFromMetadata(m.Metadata)
+			content := formatSkillMemory(skill)
 			if content == "" {
 				content = m.Memory
 			}
@@ -266,9 +744,11 @@ func parseSearchResponse(body []byte) (*SearchResult, error) {
 				continue
 			}
 			result.SkillMemories = append(result.SkillMemories, MemoryItem{
-				ID:      m.ID,
-				Content: content,
-				Score:   m.Score,
+				ID:       m.ID,
+				Content:  content,
+				Score:    m.Score,
+				Skill:    skill,
+				Metadata: m.Metadata,
 			})
 		}
 	}
@@ -280,9 +760,10 @@ func parseSearchResponse(body []byte) (*SearchResult, error) {
 				continue
 			}
 			result.PrefMemories = append(result.PrefMemories, MemoryItem{
-				ID:      m.ID,
-				Content: content,
-				Score:   m.Score,
+				ID:       m.ID,
+				Content:  content,
+				Score:    m.Score,
+				Metadata: m.Metadata,
 			})
 		}
 	}
@@ -290,29 +771,39 @@ func parseSearchResponse(body []byte) (*SearchResult, error) {
 	return result, nil
 }
 
-// formatSkillMemory formats a skill memory entry from its metadata.
-func formatSkillMemory(metadata map[string]interface{}) string {
+// skillMemoryFromMetadata extracts a skill memory's fields from its
+// metadata, returning nil if it has neither a name nor a description.
+func skillMemoryFromMetadata(metadata map[string]interface{}) *SkillMemory {
 	if metadata == nil {
-		return ""
+		return nil
 	}
 
-	name, _ := metadata["name"].(string)
-	description, _ := metadata["description"].(string)
-	procedure, _ := metadata["procedure"].(string)
+	var skill SkillMemory
+	skill.Name, _ = metadata["name"].(string)
+	skill.Description, _ = metadata["description"].(string)
+	skill.Procedure, _ = metadata["procedure"].(string)
+
+	if skill.Name == "" && skill.Description == "" {
+		return nil
+	}
+	return &skill
+}
 
-	if name == "" && description == "" {
+// formatSkillMemory flattens a skill memory into a single prompt line.
+func formatSkillMemory(skill *SkillMemory) string {
+	if skill == nil {
 		return ""
 	}
 
 	var parts []string
-	if name != "" {
-		parts = append(parts, fmt.Sprintf("**%s**", name))
+	if skill.Name != "" {
+		parts = append(parts, fmt.Sprintf("**%s**", skill.Name))
 	}
-	if description != "" {
-		parts = append(parts, description)
+	if skill.Description != "" {
+		parts = append(parts, skill.Description)
 	}
-	if procedure != "" {
-		parts = append(parts, fmt.Sprintf("Procedure: %s", procedure))
+	if skill.Procedure != "" {
+		parts = append(parts, fmt.Sprintf("Procedure: %s", skill.Procedure))
 	}
 
 	return strings.Join(parts, " — ")
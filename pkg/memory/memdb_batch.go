@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// BatchError reports the queries of a SearchBatch that failed. Errs is
+// indexed like the queries, with nil entries for those that succeeded.
+type BatchError struct {
+	Errs []error
+}
+
+func (e *BatchError) Error() string {
+	var parts []string
+	for i, err := range e.Errs {
+		if err != nil {
+			parts = append(parts, fmt.Sprintf("query %d: %v", i, err))
+		}
+	}
+	return fmt.Sprintf("%d of %d searches failed: %s", len(parts), len(e.Errs), strings.Join(parts, "; "))
+}
+
+// Unwrap returns the individual query errors, so errors.Is and errors.As
+// match any of them.
+func (e *BatchError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// SearchBatch runs Search for each query, at most maxConcurrency at a time.
+func (c *MemDBClient) SearchBatch(ctx context.Context, queries []string, maxConcurrency int) ([]*SearchResult, error) {
+	return c.SearchBatchForUser(ctx, "", queries, maxConcurrency)
+}
+
+// SearchBatchForUser is SearchBatch in the memory pool of userID.
+//
+// Results are in query order. A failed query leaves a nil result and does
+// not stop the others; if any failed, the error is a *BatchError. Once ctx
+// is cancelled no further queries are started and those left fail with
+// ctx.Err().
+func (c *MemDBClient) SearchBatchForUser(ctx context.Context, userID string, queries []string, maxConcurrency int) ([]*SearchResult, error) {
+	results := make([]*SearchResult, len(queries))
+	errs := make([]error, len(queries))
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, query string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = c.SearchForUser(ctx, userID, query)
+		}(i, query)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return results, &BatchError{Errs: errs}
+		}
+	}
+	return results, nil
+}
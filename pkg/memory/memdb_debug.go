@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// debugBodyLimit caps how much of a request or response body is logged.
+const debugBodyLimit = 8 * 1024
+
+// redactedValue replaces secrets and redacted fields in debug logs.
+const redactedValue = "[REDACTED]"
+
+// logRequest logs an outgoing request and its JSON body when debug logging
+// is enabled. The secret header is always masked.
+func (c *MemDBClient) logRequest(req *http.Request, jsonData []byte) {
+	if !c.debug {
+		return
+	}
+
+	headers := make(map[string]string, len(req.Header))
+	for name, values := range req.Header {
+		if strings.EqualFold(name, "X-Internal-Service") {
+			headers[name] = redactedValue
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	logger.InfoCF("memdb", "request", map[string]interface{}{
+		"method":  req.Method,
+		"url":     req.URL.String(),
+		"headers": headers,
+		"body":    c.redactBody(jsonData),
+	})
+}
+
+// logResponse logs a raw response body when debug logging is enabled.
+func (c *MemDBClient) logResponse(path string, status int, body []byte) {
+	if !c.debug {
+		return
+	}
+
+	logger.InfoCF("memdb", "response", map[string]interface{}{
+		"path":   path,
+		"status": status,
+		"body":   c.redactBody(body),
+	})
+}
+
+// redactBody masks the values of the configured fields, at any depth, in a
+// JSON body and truncates the result for logging. Non-JSON bodies are logged
+// as is.
+func (c *MemDBClient) redactBody(body []byte) string {
+	out := string(body)
+	if len(c.redactFields) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err == nil {
+			if redacted, err := json.Marshal(c.redactValue(v)); err == nil {
+				out = string(redacted)
+			}
+		}
+	}
+	if len(out) > debugBodyLimit {
+		out = out[:debugBodyLimit] + "... (truncated)"
+	}
+	return out
+}
+
+func (c *MemDBClient) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, field := range val {
+			if c.redactFields[strings.ToLower(k)] {
+				val[k] = redactedValue
+			} else {
+				val[k] = c.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = c.redactValue(item)
+		}
+	}
+	return v
+}
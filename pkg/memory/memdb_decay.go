@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// timestampKeys are the metadata fields checked, in order, for when a memory
+// was last written.
+var timestampKeys = []string{"updated_at", "created_at", "timestamp"}
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Timestamp returns when the memory was last written, from its metadata.
+// It accepts RFC 3339 and similar date strings, and Unix seconds or
+// milliseconds. ok is false if the metadata has no usable timestamp.
+func (m MemoryItem) Timestamp() (t time.Time, ok bool) {
+	for _, key := range timestampKeys {
+		switch v := m.Metadata[key].(type) {
+		case string:
+			for _, layout := range timestampLayouts {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t, true
+				}
+			}
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return unixTime(n), true
+			}
+		case float64:
+			return unixTime(v), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// unixTime converts Unix seconds, or milliseconds for values too large to
+// be seconds, to a time.
+func unixTime(n float64) time.Time {
+	if n > 1e11 {
+		return time.UnixMilli(int64(n))
+	}
+	return time.Unix(int64(n), 0)
+}
+
+// ApplyRecencyDecay re-ranks each category by score weighted for age: a
+// memory's score halves every halfLife, so newer memories win over older
+// ones of similar relevance. Memories without a timestamp keep their score.
+// Each category is then trimmed to limit items; limit <= 0 keeps them all.
+// Score is left as returned by MemDB.
+func (r *SearchResult) ApplyRecencyDecay(halfLife time.Duration, now time.Time, limit int) {
+	if r == nil || halfLife <= 0 {
+		return
+	}
+	rerank := func(items []MemoryItem) []MemoryItem {
+		weighted := make([]float64, len(items))
+		for i, m := range items {
+			weighted[i] = m.Score
+			if t, ok := m.Timestamp(); ok && now.After(t) {
+				weighted[i] *= math.Exp2(-float64(now.Sub(t)) / float64(halfLife))
+			}
+		}
+		idx := make([]int, len(items))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.SliceStable(idx, func(a, b int) bool { return weighted[idx[a]] > weighted[idx[b]] })
+
+		out := make([]MemoryItem, 0, len(items))
+		for _, i := range idx {
+			if limit > 0 && len(out) == limit {
+				break
+			}
+			out = append(out, items[i])
+		}
+		return out
+	}
+	r.TextMemories = rerank(r.TextMemories)
+	r.SkillMemories = rerank(r.SkillMemories)
+	r.PrefMemories = rerank(r.PrefMemories)
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MemDBError is a non-200 response from the MemDB API. Code and Message come
+// from the JSON error body when it has one; Body always holds the raw body.
+type MemDBError struct {
+	Op         string // "search" or "store"
+	StatusCode int
+	Code       int
+	Message    string
+	Body       string
+}
+
+func (e *MemDBError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Body
+	}
+	if e.Code != 0 && e.Code != e.StatusCode {
+		return fmt.Sprintf("%s API error %d (code %d): %s", e.Op, e.StatusCode, e.Code, msg)
+	}
+	return fmt.Sprintf("%s API error %d: %s", e.Op, e.StatusCode, msg)
+}
+
+// newMemDBError builds a MemDBError from an error response. MemDB replies
+// with {"code": ..., "message": ...}; FastAPI validation errors use
+// {"detail": ...} instead.
+func newMemDBError(op string, statusCode int, body []byte) *MemDBError {
+	e := &MemDBError{
+		Op:         op,
+		StatusCode: statusCode,
+		Body:       string(body),
+	}
+
+	var parsed struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Detail  json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return e
+	}
+	e.Code = parsed.Code
+	e.Message = parsed.Message
+	if e.Message == "" && len(parsed.Detail) > 0 {
+		var detail string
+		if json.Unmarshal(parsed.Detail, &detail) == nil {
+			e.Message = detail
+		} else {
+			e.Message = strings.TrimSpace(string(parsed.Detail))
+		}
+	}
+	return e
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultDownCooldown is how long Search skips MemDB after it was observed
+// to be unreachable.
+const defaultDownCooldown = 30 * time.Second
+
+// readiness caches the last observed MemDB availability so Search can skip
+// requests during an outage instead of waiting for each one to time out.
+type readiness struct {
+	mu        sync.Mutex
+	cooldown  time.Duration
+	downUntil time.Time
+}
+
+// markDown records a failure; MemDB is considered down for the cooldown.
+func (r *readiness) markDown() {
+	if r.cooldown <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downUntil = time.Now().Add(r.cooldown)
+}
+
+// markUp records a success, ending any cooldown.
+func (r *readiness) markUp() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downUntil = time.Time{}
+}
+
+// down reports whether MemDB is within a cooldown after a failure.
+func (r *readiness) down() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Now().Before(r.downUntil)
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// defaultSecretTTL is how long a secret fetched from a SecretProvider is
+// reused before it is fetched again.
+const defaultSecretTTL = 30 * time.Second
+
+// SecretProvider returns the current X-Internal-Service secret. It is called
+// at request time, at most once per cache period, so the secret can be
+// rotated without recreating the client.
+type SecretProvider func() (string, error)
+
+// FileSecret reads the secret from path, e.g. a mounted Kubernetes or vault
+// secret, trimming surrounding whitespace.
+func FileSecret(path string) SecretProvider {
+	return func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+}
+
+// EnvSecret reads the secret from the environment variable name.
+func EnvSecret(name string) SecretProvider {
+	return func() (string, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	}
+}
+
+// secretSource caches the secret from a SecretProvider. Without a provider,
+// or when it fails and nothing has been fetched yet, the static secret is
+// used; after a failure the last good secret is kept.
+type secretSource struct {
+	static   string
+	provider SecretProvider
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	cached    string
+	fetched   bool
+	expiresAt time.Time
+}
+
+func (s *secretSource) get() string {
+	if s.provider == nil {
+		return s.static
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fetched && time.Now().Before(s.expiresAt) {
+		return s.cached
+	}
+
+	secret, err := s.provider()
+	if err == nil && secret != "" {
+		s.cached, s.fetched = secret, true
+		s.expiresAt = time.Now().Add(s.ttl)
+		return secret
+	}
+	if err == nil {
+		err = fmt.Errorf("secret is empty")
+	}
+	logger.WarnCF("memdb", "Failed to fetch secret, using previous value", map[string]interface{}{
+		"error": err.Error(),
+	})
+	// Retry on the next request rather than hammering a failing source.
+	s.expiresAt = time.Now().Add(s.ttl)
+	if s.fetched {
+		return s.cached
+	}
+	s.cached, s.fetched = s.static, true
+	return s.static
+}
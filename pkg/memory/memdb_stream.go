@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// StoreStreamResult counts what a StoreStream import did.
+type StoreStreamResult struct {
+	// Conversations is the number of valid lines read; InvalidLines those
+	// that weren't a JSON message array and were skipped.
+	Conversations int
+	InvalidLines  int
+	// Succeeded and Failed count batches; a batch fails if any of its
+	// conversations couldn't be stored.
+	Succeeded int
+	Failed    int
+}
+
+// streamConversation is one valid StoreStream line.
+type streamConversation struct {
+	messages []map[string]string
+	line     int
+}
+
+// StoreStream imports conversations from NDJSON, one JSON array of messages
+// (as passed to Store) per line. Lines are grouped batchSize at a time, with
+// at most maxConcurrency batches in flight. Within a batch each conversation
+// is still stored with its own request, so MemDB never sees two of them as
+// one exchange.
+func (c *MemDBClient) StoreStream(ctx context.Context, r io.Reader, batchSize, maxConcurrency int) (*StoreStreamResult, error) {
+	return c.StoreStreamForUser(ctx, "", r, batchSize, maxConcurrency)
+}
+
+// StoreStreamForUser is StoreStream into the memory pool of userID.
+//
+// Invalid lines and failed conversations are logged and counted but don't
+// stop the import. The error is non-nil only if reading fails or ctx is
+// cancelled; no further batches are started then, and the result counts
+// what was done so far.
+func (c *MemDBClient) StoreStreamForUser(ctx context.Context, userID string, r io.Reader, batchSize, maxConcurrency int) (*StoreStreamResult, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	result := &StoreStreamResult{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrency)
+
+	flush := func(batch []streamConversation) error {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			failed := false
+			for _, conv := range batch {
+				if err := c.AddMessages(ctx, userID, conv.messages); err != nil {
+					failed = true
+					logger.WarnCF("memdb", "stream store conversation failed", map[string]interface{}{
+						"line":  conv.line,
+						"error": err.Error(),
+					})
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if failed {
+				result.Failed++
+			} else {
+				result.Succeeded++
+			}
+		}()
+		return nil
+	}
+
+	reader := bufio.NewReader(r)
+	var batch []streamConversation
+	lineNum := 0
+	var err error
+	for err == nil {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("read line %d: %w", lineNum+1, err)
+			break
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+
+		if len(line) > 0 {
+			lineNum++
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var messages []map[string]string
+			if jsonErr := json.Unmarshal(line, &messages); jsonErr != nil || len(messages) == 0 {
+				mu.Lock()
+				result.InvalidLines++
+				mu.Unlock()
+				logger.WarnCF("memdb", "skipping invalid stream store line", map[string]interface{}{
+					"line": lineNum,
+				})
+			} else {
+				batch = append(batch, streamConversation{messages: messages, line: lineNum})
+				mu.Lock()
+				result.Conversations++
+				mu.Unlock()
+			}
+		}
+
+		if len(batch) > 0 && (len(batch) == batchSize || errors.Is(err, io.EOF)) {
+			if flushErr := flush(batch); flushErr != nil {
+				err = flushErr
+				break
+			}
+			batch = nil
+		}
+	}
+	wg.Wait()
+
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return result, err
+}
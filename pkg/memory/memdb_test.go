@@ -0,0 +1,238 @@
+package memory
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemDBClientConcurrentUse(t *testing.T) {
+	var searches, adds atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			reader = zr
+		}
+		var body map[string]interface{}
+		json.NewDecoder(reader).Decode(&body)
+		switch r.URL.Path {
+		case "/health":
+		case "/product/search":
+			searches.Add(1)
+			fmt.Fprintf(w, `{"code":200,"data":{"text_mem":[{"memories":[{"id":"1","memory":"fact for %v"}]}]}}`, body["user_id"])
+		case "/product/add":
+			adds.Add(1)
+			fmt.Fprint(w, `{"code":200}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewMemDBClient(MemDBConfig{
+		URL:          srv.URL,
+		UserID:       "default",
+		CubeID:       "cube",
+		Gzip:         true,
+		GzipMinBytes: 16,
+		Debug:        true,
+		RedactFields: []string{"content"},
+	})
+
+	const workers = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := context.Background()
+			user := fmt.Sprintf("user-%d", i)
+
+			result, err := client.SearchForUser(ctx, user, "query")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if want := "fact for " + user; len(result.TextMemories) != 1 || result.TextMemories[0].Content != want {
+				errs <- fmt.Errorf("user %s got %+v, want %q", user, result.TextMemories, want)
+				return
+			}
+
+			msgs := []map[string]string{{"role": "user", "content": "message from " + user}}
+			if err := client.AddMessages(ctx, user, msgs); err != nil {
+				errs <- err
+				return
+			}
+			client.Store(ctx, msgs)
+			client.Health(ctx)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := searches.Load(); got != workers {
+		t.Errorf("searches = %d, want %d", got, workers)
+	}
+	if got := adds.Load(); got != 2*workers {
+		t.Errorf("adds = %d, want %d", got, 2*workers)
+	}
+}
+
+func TestMemDBClientSecretRotation(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("X-Internal-Service"))
+		mu.Unlock()
+		fmt.Fprint(w, `{"code":200}`)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "secret")
+	client := NewMemDBClient(MemDBConfig{
+		URL:            srv.URL,
+		Secret:         "static",
+		SecretProvider: FileSecret(path),
+		SecretTTL:      time.Nanosecond,
+	})
+	search := func() {
+		if _, err := client.Search(context.Background(), "query"); err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+	}
+
+	search() // file missing: falls back to the static secret
+	os.WriteFile(path, []byte("first\n"), 0600)
+	search()
+	os.WriteFile(path, []byte("second"), 0600)
+	search()
+	os.Remove(path)
+	search() // file gone again: keeps the last good secret
+
+	want := []string{"static", "first", "second", "second"}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("secrets sent = %v, want %v", seen, want)
+	}
+}
+
+func TestMemDBClientStoreStream(t *testing.T) {
+	var mu sync.Mutex
+	var stored []string
+	var inFlight atomic.Int32
+	var maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		mu.Lock()
+		if n > maxInFlight {
+			maxInFlight = n
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		if body.Messages[0]["content"] == "fail" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		var contents []string
+		for _, m := range body.Messages {
+			contents = append(contents, m["content"])
+		}
+		mu.Lock()
+		stored = append(stored, strings.Join(contents, ","))
+		mu.Unlock()
+		fmt.Fprint(w, `{"code":200}`)
+	}))
+	defer srv.Close()
+
+	client := NewMemDBClient(MemDBConfig{URL: srv.URL, UserID: "u", CubeID: "c"})
+	input := `[{"role":"user","content":"a"},{"role":"assistant","content":"b"}]
+[{"role":"user","content":"c"}]
+
+not json
+[{"role":"user","content":"fail"}]
+[{"role":"user","content":"d"}]`
+
+	tests := []struct {
+		batchSize int
+		want      StoreStreamResult
+	}{
+		// Lines a,b / c / fail / d: "fail" sinks whichever batch it lands in.
+		{1, StoreStreamResult{Conversations: 4, InvalidLines: 1, Succeeded: 3, Failed: 1}},
+		{2, StoreStreamResult{Conversations: 4, InvalidLines: 1, Succeeded: 1, Failed: 1}},
+		{4, StoreStreamResult{Conversations: 4, InvalidLines: 1, Succeeded: 0, Failed: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("batch size %d", tt.batchSize), func(t *testing.T) {
+			stored, maxInFlight = nil, 0
+			result, err := client.StoreStream(context.Background(), strings.NewReader(input), tt.batchSize, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *result != tt.want {
+				t.Errorf("result = %+v, want %+v", *result, tt.want)
+			}
+			sort.Strings(stored)
+			if got := strings.Join(stored, " "); got != "a,b c d" {
+				t.Errorf("stored conversations = %q, want each stored on its own", got)
+			}
+			if maxInFlight > 2 {
+				t.Errorf("%d requests in flight, want at most 2", maxInFlight)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.StoreStream(ctx, strings.NewReader(input), 1, 1); err != context.Canceled {
+		t.Errorf("cancelled import: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestSearchResultApplyRecencyDecay(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	result := &SearchResult{TextMemories: []MemoryItem{
+		{ID: "old", Score: 0.95, Metadata: map[string]interface{}{"updated_at": "2025-06-01T00:00:00Z"}},
+		{ID: "undated", Score: 0.9},
+		{ID: "new", Score: 0.9, Metadata: map[string]interface{}{"created_at": float64(now.Add(-24 * time.Hour).Unix())}},
+		{ID: "newer", Score: 0.88, Metadata: map[string]interface{}{"timestamp": "2026-05-31 12:00:00"}},
+	}}
+
+	result.ApplyRecencyDecay(30*24*time.Hour, now, 3)
+
+	var got []string
+	for _, m := range result.TextMemories {
+		got = append(got, m.ID)
+	}
+	if want := "undated,new,newer"; strings.Join(got, ",") != want {
+		t.Errorf("order = %v, want %s", got, want)
+	}
+	if result.TextMemories[1].Score != 0.9 {
+		t.Errorf("score was modified: %v", result.TextMemories[1].Score)
+	}
+}
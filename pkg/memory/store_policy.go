@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StorePolicy batches conversation messages per session and sends them to
+// MemDB once a session has accumulated EveryTurns user turns or Interval has
+// passed since its first buffered message, whichever comes first.
+type StorePolicy struct {
+	store      func(ctx context.Context, userID string, messages []map[string]string)
+	everyTurns int
+	interval   time.Duration
+
+	mu      sync.Mutex
+	pending map[pendingKey]*pendingStore
+}
+
+// pendingKey identifies a buffer. Sessions are per chat, so in a group chat
+// each sender's turns are buffered separately and go to their own pool.
+type pendingKey struct {
+	session string
+	userID  string
+}
+
+type pendingStore struct {
+	userID   string
+	messages []map[string]string
+	turns    int
+	since    time.Time
+}
+
+// NewStorePolicy returns a policy that flushes to client. everyTurns < 1 is
+// treated as 1; interval <= 0 disables the time trigger.
+func NewStorePolicy(client *MemDBClient, everyTurns int, interval time.Duration) *StorePolicy {
+	return newStorePolicy(client.StoreForUser, everyTurns, interval)
+}
+
+func newStorePolicy(store func(context.Context, string, []map[string]string), everyTurns int, interval time.Duration) *StorePolicy {
+	if everyTurns < 1 {
+		everyTurns = 1
+	}
+	return &StorePolicy{
+		store:      store,
+		everyTurns: everyTurns,
+		interval:   interval,
+		pending:    make(map[pendingKey]*pendingStore),
+	}
+}
+
+// Add buffers messages for the session key, to be stored for userID (empty
+// means the client's configured user). Each (session, user) pair has its
+// own buffer and thresholds. Once a threshold is reached the
+// session's batch is sent in the background, so Add never blocks on MemDB.
+// Each "user" message counts as one turn.
+func (p *StorePolicy) Add(ctx context.Context, key, userID string, messages ...map[string]string) {
+	bufKey := pendingKey{session: key, userID: userID}
+	p.mu.Lock()
+	ps, ok := p.pending[bufKey]
+	if !ok {
+		ps = &pendingStore{userID: userID, since: time.Now()}
+		p.pending[bufKey] = ps
+	}
+	ps.messages = append(ps.messages, messages...)
+	for _, m := range messages {
+		if m["role"] == "user" {
+			ps.turns++
+		}
+	}
+	var batch *pendingStore
+	if p.due(ps, time.Now()) {
+		batch = ps
+		delete(p.pending, bufKey)
+	}
+	p.mu.Unlock()
+
+	if batch != nil {
+		go p.store(ctx, batch.userID, batch.messages)
+	}
+}
+
+// Flush sends every buffered session to MemDB, regardless of thresholds.
+func (p *StorePolicy) Flush(ctx context.Context) {
+	p.flush(ctx, func(*pendingStore) bool { return true })
+}
+
+// Run flushes sessions whose interval has elapsed until ctx is done, then
+// flushes whatever is left. It returns immediately if the time trigger is
+// disabled.
+func (p *StorePolicy) Run(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.Flush(context.Background())
+			return
+		case now := <-ticker.C:
+			p.flush(ctx, func(ps *pendingStore) bool { return p.due(ps, now) })
+		}
+	}
+}
+
+func (p *StorePolicy) due(ps *pendingStore, now time.Time) bool {
+	if ps.turns >= p.everyTurns {
+		return true
+	}
+	return p.interval > 0 && now.Sub(ps.since) >= p.interval
+}
+
+func (p *StorePolicy) flush(ctx context.Context, match func(*pendingStore) bool) {
+	var batches []*pendingStore
+
+	p.mu.Lock()
+	for key, ps := range p.pending {
+		if len(ps.messages) > 0 && match(ps) {
+			batches = append(batches, ps)
+			delete(p.pending, key)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, batch := range batches {
+		p.store(ctx, batch.userID, batch.messages)
+	}
+}
@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingStore is a fake store func that records each batch by user.
+type recordingStore struct {
+	mu      sync.Mutex
+	batches map[string][][]map[string]string
+	done    chan struct{}
+}
+
+func newRecordingStore() *recordingStore {
+	return &recordingStore{batches: make(map[string][][]map[string]string), done: make(chan struct{}, 16)}
+}
+
+func (r *recordingStore) store(_ context.Context, userID string, messages []map[string]string) {
+	r.mu.Lock()
+	r.batches[userID] = append(r.batches[userID], messages)
+	r.mu.Unlock()
+	r.done <- struct{}{}
+}
+
+func (r *recordingStore) users() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []string
+	for u := range r.batches {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	return users
+}
+
+func turn(content string) map[string]string {
+	return map[string]string{"role": "user", "content": content}
+}
+
+func TestStorePolicyKeepsUsersApartInSharedSession(t *testing.T) {
+	rec := newRecordingStore()
+	p := newStorePolicy(rec.store, 2, 0)
+	ctx := context.Background()
+
+	p.Add(ctx, "telegram:group", "alice", turn("alice 1"))
+	p.Add(ctx, "telegram:group", "bob", turn("bob 1"))
+	p.Add(ctx, "telegram:group", "alice", turn("alice 2"))
+	<-rec.done
+
+	if users := rec.users(); len(users) != 1 || users[0] != "alice" {
+		t.Fatalf("stored users = %v, want only alice", users)
+	}
+	for _, m := range rec.batches["alice"][0] {
+		if m["content"] == "bob 1" {
+			t.Fatalf("bob's turn was stored in alice's pool: %v", rec.batches["alice"][0])
+		}
+	}
+
+	p.Flush(ctx)
+	<-rec.done
+	if got := rec.batches["bob"]; len(got) != 1 || len(got[0]) != 1 || got[0][0]["content"] != "bob 1" {
+		t.Errorf("bob's batch = %v, want his single turn", got)
+	}
+}
+
+func reply(content string) map[string]string {
+	return map[string]string{"role": "assistant", "content": content}
+}
+
+// stored reports whether a batch is stored within wait.
+func (r *recordingStore) stored(wait time.Duration) bool {
+	select {
+	case <-r.done:
+		return true
+	case <-time.After(wait):
+		return false
+	}
+}
+
+func TestStorePolicyTurnThreshold(t *testing.T) {
+	rec := newRecordingStore()
+	p := newStorePolicy(rec.store, 2, 0)
+	ctx := context.Background()
+
+	p.Add(ctx, "s", "", turn("q1"), reply("a1"))
+	p.Add(ctx, "s", "", reply("follow-up"))
+	if rec.stored(50 * time.Millisecond) {
+		t.Fatal("stored after one user turn, want to wait for two")
+	}
+
+	p.Add(ctx, "s", "", turn("q2"), reply("a2"))
+	if !rec.stored(time.Second) {
+		t.Fatal("nothing stored after two user turns")
+	}
+	var contents []string
+	for _, m := range rec.batches[""][0] {
+		contents = append(contents, m["content"])
+	}
+	if got := strings.Join(contents, ","); got != "q1,a1,follow-up,q2,a2" {
+		t.Errorf("batch = %s, want every message in order", got)
+	}
+
+	// The buffer starts over after a flush.
+	p.Add(ctx, "s", "", turn("q3"))
+	if rec.stored(50 * time.Millisecond) {
+		t.Error("stored a fresh buffer after one turn")
+	}
+}
+
+func TestStorePolicyInterval(t *testing.T) {
+	rec := newRecordingStore()
+	p := newStorePolicy(rec.store, 100, 40*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	p.Add(ctx, "s", "", turn("q1"))
+	start := time.Now()
+	if !rec.stored(2 * time.Second) {
+		t.Fatal("nothing stored after the interval")
+	}
+	if waited := time.Since(start); waited < 40*time.Millisecond {
+		t.Errorf("stored after %v, before the 40ms interval", waited)
+	}
+
+	// A buffer older than the interval is also stored by the next Add.
+	p = newStorePolicy(rec.store, 100, 40*time.Millisecond)
+	p.Add(ctx, "s", "", turn("q2"))
+	time.Sleep(50 * time.Millisecond)
+	p.Add(ctx, "s", "", reply("a2"))
+	if !rec.stored(time.Second) {
+		t.Fatal("an overdue buffer wasn't stored on Add")
+	}
+}
+
+func TestStorePolicyFlush(t *testing.T) {
+	rec := newRecordingStore()
+	p := newStorePolicy(rec.store, 100, 0)
+	ctx := context.Background()
+
+	p.Add(ctx, "a", "alice", turn("one"))
+	p.Add(ctx, "b", "bob", turn("two"))
+	p.Flush(ctx)
+	if users := rec.users(); len(users) != 2 {
+		t.Fatalf("stored users after Flush = %v, want alice and bob", users)
+	}
+
+	p.Flush(ctx)
+	if n := len(rec.batches["alice"]) + len(rec.batches["bob"]); n != 2 {
+		t.Errorf("%d batches after a second Flush, want nothing more stored", n)
+	}
+
+	// Run flushes what is left when its context ends.
+	p = newStorePolicy(rec.store, 100, time.Hour)
+	p.Add(ctx, "c", "carol", turn("three"))
+	runCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	p.Run(runCtx)
+	if len(rec.batches["carol"]) != 1 {
+		t.Error("Run didn't flush the remaining buffer on shutdown")
+	}
+}
@@ -0,0 +1,345 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	defaultAnthropicTimeout   = 120 * time.Second
+	defaultAnthropicMaxTokens = 4096
+	anthropicAPIVersion       = "2023-06-01"
+)
+
+func init() {
+	RegisterProvider("anthropic", func(cfg *config.Config) (LLMProvider, error) {
+		pc := cfg.Providers.Anthropic
+		apiBase := pc.APIBase
+		if apiBase == "" {
+			apiBase = "https://api.anthropic.com/v1"
+		}
+		if pc.Proxy != "" {
+			transport, err := newProxyTransport(pc.Proxy)
+			if err != nil {
+				return nil, err
+			}
+			return NewAnthropicProviderWithClient(pc.APIKey, apiBase, &http.Client{Transport: transport}), nil
+		}
+		return NewAnthropicProvider(pc.APIKey, apiBase), nil
+	})
+}
+
+// AnthropicProvider implements LLMProvider using the Anthropic Messages API.
+type AnthropicProvider struct {
+	apiKey     string
+	apiBase    string
+	timeout    time.Duration
+	httpClient *http.Client
+}
+
+func NewAnthropicProvider(apiKey, apiBase string) *AnthropicProvider {
+	return NewAnthropicProviderWithClient(apiKey, apiBase, nil)
+}
+
+// NewAnthropicProviderWithClient creates an AnthropicProvider using the given
+// http.Client. A nil client falls back to the default.
+func NewAnthropicProviderWithClient(apiKey, apiBase string, client *http.Client) *AnthropicProvider {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &AnthropicProvider{
+		apiKey:     apiKey,
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		timeout:    defaultAnthropicTimeout,
+		httpClient: client,
+	}
+}
+
+// SetTimeout overrides the per-request timeout (default 120s).
+func (p *AnthropicProvider) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
+// anthropicMessage is a single turn in the Messages API.
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a union of text, image, tool_use and tool_result content
+// blocks.
+type anthropicBlock struct {
+	Type      string                 `json:"type"`
+	Text      string                 `json:"text,omitempty"`
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Input     map[string]interface{} `json:"input,omitempty"`
+	ToolUseID string                 `json:"tool_use_id,omitempty"`
+	Content   string                 `json:"content,omitempty"`
+	Source    *anthropicSource       `json:"source,omitempty"`
+}
+
+// MarshalJSON always sends a tool_use block's input, as the API requires,
+// even for a call without arguments.
+func (b anthropicBlock) MarshalJSON() ([]byte, error) {
+	type plain anthropicBlock
+	if b.Type != "tool_use" {
+		return json.Marshal(plain(b))
+	}
+	input := b.Input
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+	return json.Marshal(struct {
+		plain
+		Input map[string]interface{} `json:"input"`
+	}{plain(b), input})
+}
+
+// anthropicSource is the inline data of an image block.
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	if p.apiBase == "" {
+		return nil, fmt.Errorf("Anthropic API base not configured")
+	}
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	anthropicMessages, system := convertMessagesToAnthropic(messages)
+
+	maxTokens := defaultAnthropicMaxTokens
+	if mt, ok := options["max_tokens"].(int); ok && mt > 0 {
+		maxTokens = mt
+	}
+
+	body := map[string]interface{}{
+		"model":      model,
+		"messages":   anthropicMessages,
+		"max_tokens": maxTokens,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	if temperature, ok := options["temperature"].(float64); ok {
+		body["temperature"] = temperature
+	}
+
+	if len(tools) > 0 {
+		anthropicTools := make([]anthropicTool, 0, len(tools))
+		for _, t := range tools {
+			schema := t.Function.Parameters
+			if schema == nil {
+				schema = map[string]interface{}{"type": "object"}
+			}
+			anthropicTools = append(anthropicTools, anthropicTool{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				InputSchema: schema,
+			})
+		}
+		body["tools"] = anthropicTools
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Anthropic request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/messages", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Anthropic request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Anthropic API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Anthropic response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("Anthropic", resp.StatusCode, respBody)
+	}
+
+	return parseAnthropicResponse(respBody)
+}
+
+func (p *AnthropicProvider) GetDefaultModel() string {
+	return "claude-sonnet-4-5"
+}
+
+// convertMessagesToAnthropic converts OpenAI-style messages to the Messages
+// API format. System messages are pulled out into the top-level system
+// prompt, assistant tool calls become tool_use blocks, and tool results become
+// tool_result blocks on a user turn, keyed by the originating tool_use id.
+// Consecutive turns with the same role are merged, as the API requires
+// alternating roles.
+func convertMessagesToAnthropic(messages []Message) ([]anthropicMessage, string) {
+	var out []anthropicMessage
+	var systemParts []string
+
+	appendBlocks := func(role string, blocks ...anthropicBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			return
+		}
+		out = append(out, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				systemParts = append(systemParts, msg.Content)
+			}
+
+		case "assistant":
+			var blocks []anthropicBlock
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				name := tc.Name
+				args := tc.Arguments
+				if tc.Function != nil {
+					if name == "" {
+						name = tc.Function.Name
+					}
+					if args == nil && tc.Function.Arguments != "" {
+						_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+					}
+				}
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				blocks = append(blocks, anthropicBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  name,
+					Input: args,
+				})
+			}
+			appendBlocks("assistant", blocks...)
+
+		case "tool":
+			appendBlocks("user", anthropicBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			})
+
+		default: // "user"
+			var blocks []anthropicBlock
+			if msg.Content != "" || len(msg.Attachments) == 0 {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, a := range msg.Attachments {
+				blocks = append(blocks, anthropicBlock{
+					Type:   "image",
+					Source: &anthropicSource{Type: "base64", MediaType: a.MIMEType, Data: a.Base64()},
+				})
+			}
+			appendBlocks("user", blocks...)
+		}
+	}
+
+	return out, strings.Join(systemParts, "\n")
+}
+
+func parseAnthropicResponse(body []byte) (*LLMResponse, error) {
+	var resp struct {
+		Content []struct {
+			Type  string                 `json:"type"`
+			Text  string                 `json:"text"`
+			ID    string                 `json:"id"`
+			Name  string                 `json:"name"`
+			Input map[string]interface{} `json:"input"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      *struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse Anthropic response: %w", err)
+	}
+
+	var content string
+	var toolCalls []ToolCall
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			content += block.Text
+		case "tool_use":
+			args := block.Input
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			toolCalls = append(toolCalls, ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: args,
+			})
+		}
+	}
+
+	finishReason := "stop"
+	switch resp.StopReason {
+	case "max_tokens":
+		finishReason = "length"
+	case "tool_use":
+		finishReason = "tool_calls"
+	}
+
+	result := &LLMResponse{
+		Content:         content,
+		ToolCalls:       toolCalls,
+		FinishReason:    finishReason,
+		RawFinishReason: resp.StopReason,
+	}
+
+	if resp.Usage != nil {
+		result.Usage = &UsageInfo{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		}
+	}
+
+	return result, nil
+}
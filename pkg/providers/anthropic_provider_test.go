@@ -0,0 +1,28 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConvertMessagesToAnthropic(t *testing.T) {
+	messages := []Message{
+		NewUserMessage("", Attachment{MIMEType: "image/png", Data: []byte("png")}),
+		NewAssistantToolCalls("", []ToolCall{{ID: "toolu_1", Name: "list_dir"}}),
+		{Role: "tool", ToolCallID: "toolu_1", Content: "a.txt"},
+	}
+	converted, _ := convertMessagesToAnthropic(messages)
+	data, err := json.Marshal(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+
+	if !strings.Contains(got, `"type":"tool_use","id":"toolu_1","name":"list_dir","input":{}`) {
+		t.Errorf("tool_use without arguments lacks an empty input: %s", got)
+	}
+	if strings.Contains(got, `"type":"text"`) {
+		t.Errorf("attachment-only user message got an empty text block: %s", got)
+	}
+}
@@ -0,0 +1,163 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResponseCache stores LLM responses by key. Implementations must be safe for
+// concurrent use.
+type ResponseCache interface {
+	Get(key string) (*LLMResponse, bool)
+	Set(key string, resp *LLMResponse, ttl time.Duration)
+}
+
+type memoryCacheEntry struct {
+	resp    *LLMResponse
+	expires time.Time
+}
+
+// MemoryResponseCache is an in-process ResponseCache. Expired entries are
+// dropped lazily on Get and swept on Set.
+type MemoryResponseCache struct {
+	mu        sync.Mutex
+	entries   map[string]memoryCacheEntry
+	lastSweep time.Time
+}
+
+func NewMemoryResponseCache() *MemoryResponseCache {
+	return &MemoryResponseCache{entries: make(map[string]memoryCacheEntry)}
+}
+
+func (c *MemoryResponseCache) Get(key string) (*LLMResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.resp, true
+}
+
+func (c *MemoryResponseCache) Set(key string, resp *LLMResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[key] = memoryCacheEntry{resp: resp, expires: now.Add(ttl)}
+}
+
+// CachingProvider serves repeated deterministic requests from a cache. Only
+// calls with an explicit temperature <= 0 are cached; anything else is passed
+// straight through, since sampled responses are expected to vary.
+type CachingProvider struct {
+	provider LLMProvider
+	cache    ResponseCache
+	ttl      time.Duration
+}
+
+// NewCachingProvider wraps provider with a response cache. A nil cache uses a
+// MemoryResponseCache.
+func NewCachingProvider(provider LLMProvider, cache ResponseCache, ttl time.Duration) *CachingProvider {
+	if cache == nil {
+		cache = NewMemoryResponseCache()
+	}
+	return &CachingProvider{
+		provider: provider,
+		cache:    cache,
+		ttl:      ttl,
+	}
+}
+
+func (c *CachingProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	if !isDeterministic(options) {
+		return c.provider.Chat(ctx, messages, tools, model, options)
+	}
+
+	key, err := responseCacheKey(messages, tools, model, options)
+	if err != nil {
+		return c.provider.Chat(ctx, messages, tools, model, options)
+	}
+	if resp, ok := c.cache.Get(key); ok {
+		cp := *resp
+		return &cp, nil
+	}
+
+	resp, err := c.provider.Chat(ctx, messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+	cp := *resp
+	c.cache.Set(key, &cp, c.ttl)
+	return resp, nil
+}
+
+// CountTokens implements TokenCounter when the wrapped provider does.
+func (c *CachingProvider) CountTokens(ctx context.Context, messages []Message, tools []ToolDefinition, model string) (int, error) {
+	counter, ok := c.provider.(TokenCounter)
+	if !ok {
+		return 0, fmt.Errorf("provider does not support token counting")
+	}
+	return counter.CountTokens(ctx, messages, tools, model)
+}
+
+func (c *CachingProvider) GetDefaultModel() string {
+	return c.provider.GetDefaultModel()
+}
+
+func isDeterministic(options map[string]interface{}) bool {
+	switch t := options["temperature"].(type) {
+	case float64:
+		return t <= 0
+	case int:
+		return t <= 0
+	}
+	return false
+}
+
+// responseCacheKey hashes everything that determines a response. Map keys
+// are marshalled in sorted order, so equal options produce equal keys.
+// Attachments aren't marshalled with their message, so each is added as
+// its MIME type and a digest of its data.
+func responseCacheKey(messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (string, error) {
+	var attachments [][]string
+	if hasAttachments(messages) {
+		attachments = make([][]string, len(messages))
+		for i, msg := range messages {
+			for _, a := range msg.Attachments {
+				sum := sha256.Sum256(a.Data)
+				attachments[i] = append(attachments[i], a.MIMEType+":"+hex.EncodeToString(sum[:]))
+			}
+		}
+	}
+	data, err := json.Marshal(struct {
+		Model       string                 `json:"model"`
+		Messages    []Message              `json:"messages"`
+		Attachments [][]string             `json:"attachments,omitempty"`
+		Tools       []ToolDefinition       `json:"tools"`
+		Options     map[string]interface{} `json:"options"`
+	}{model, messages, attachments, tools, options})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,21 @@
+package providers
+
+import "testing"
+
+func TestResponseCacheKeyIncludesAttachments(t *testing.T) {
+	key := func(data string) string {
+		t.Helper()
+		messages := []Message{NewUserMessage("what is this?", Attachment{MIMEType: "image/png", Data: []byte(data)})}
+		k, err := responseCacheKey(messages, nil, "m", map[string]interface{}{"temperature": 0.0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	if key("cat") == key("dog") {
+		t.Error("requests with different images share a cache key")
+	}
+	if key("cat") != key("cat") {
+		t.Error("identical requests got different cache keys")
+	}
+}
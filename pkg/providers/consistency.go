@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// defaultVoteTemperature is used for separate sampling calls when the
+// caller's options don't set a temperature above zero; identical greedy
+// samples would make the vote meaningless.
+const defaultVoteTemperature = 0.7
+
+// AnswerExtractor reduces a completion to the answer it votes for. An empty
+// result abstains.
+type AnswerExtractor func(content string) string
+
+// VoteResult is the outcome of a SelfConsistency vote.
+type VoteResult struct {
+	// Answer is the most common normalized answer; Content is the first
+	// completion that gave it.
+	Answer  string
+	Content string
+	// Votes is the number of samples agreeing with Answer out of Samples
+	// that produced an answer.
+	Votes   int
+	Samples int
+	// Tally counts every answer seen.
+	Tally map[string]int
+	Usage UsageInfo
+}
+
+// Confidence returns the fraction of answering samples that agreed.
+func (v *VoteResult) Confidence() float64 {
+	if v.Samples == 0 {
+		return 0
+	}
+	return float64(v.Votes) / float64(v.Samples)
+}
+
+// SelfConsistency samples n completions for the same messages and returns the
+// majority answer. It first asks for n candidates in one request
+// (options["candidate_count"]) and makes separate calls for whatever the
+// provider didn't return, so it works with providers that ignore
+// candidate_count. extract defaults to NormalizeAnswer. Ties go to the answer
+// seen first.
+func SelfConsistency(ctx context.Context, provider LLMProvider, messages []Message, model string, options map[string]interface{}, n int, extract AnswerExtractor) (*VoteResult, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("self-consistency needs at least one sample, got %d", n)
+	}
+	if extract == nil {
+		extract = NormalizeAnswer
+	}
+
+	opts := make(map[string]interface{}, len(options)+2)
+	for k, v := range options {
+		opts[k] = v
+	}
+	if temperature, _ := opts["temperature"].(float64); temperature <= 0 {
+		opts["temperature"] = defaultVoteTemperature
+	}
+
+	usage := NewSessionUsage()
+	var contents []string
+	if n > 1 {
+		opts["candidate_count"] = n
+	}
+	for len(contents) < n {
+		resp, err := provider.Chat(ctx, messages, nil, model, opts)
+		if err != nil {
+			return nil, fmt.Errorf("self-consistency sample %d of %d: %w", len(contents)+1, n, err)
+		}
+		usage.Add(resp.Usage)
+		for _, c := range resp.AllCandidates() {
+			contents = append(contents, c.Content)
+		}
+		delete(opts, "candidate_count")
+	}
+	contents = contents[:n]
+
+	result := &VoteResult{Tally: make(map[string]int), Usage: usage.Totals()}
+	var order []string
+	first := make(map[string]string)
+	for _, content := range contents {
+		answer := extract(content)
+		if answer == "" {
+			continue
+		}
+		if _, seen := first[answer]; !seen {
+			first[answer] = content
+			order = append(order, answer)
+		}
+		result.Tally[answer]++
+		result.Samples++
+	}
+	for _, answer := range order {
+		if result.Tally[answer] > result.Votes {
+			result.Answer, result.Content, result.Votes = answer, first[answer], result.Tally[answer]
+		}
+	}
+	return result, nil
+}
+
+// answerMarker finds "answer:" in any case.
+var answerMarker = regexp.MustCompile(`(?i)answer:`)
+
+// NormalizeAnswer takes the first line after the last "answer:" marker, or
+// without a marker the last non-empty line, lowercases it, collapses
+// whitespace and trims surrounding punctuation, so "Answer: **Positive**."
+// and "positive" match.
+func NormalizeAnswer(content string) string {
+	var answer string
+	if locs := answerMarker.FindAllStringIndex(content, -1); locs != nil {
+		answer, _, _ = strings.Cut(strings.TrimLeftFunc(content[locs[len(locs)-1][1]:], unicode.IsSpace), "\n")
+	} else {
+		lines := strings.Split(strings.TrimSpace(content), "\n")
+		answer = lines[len(lines)-1]
+	}
+	answer = strings.Join(strings.Fields(strings.ToLower(answer)), " ")
+	return strings.TrimFunc(answer, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAnswer(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", "positive", "positive"},
+		{"marker with markup", "Reasoning first.\nAnswer: **Positive**.", "positive"},
+		{"first line after marker", "Answer: Positive\nThe review is upbeat.", "positive"},
+		{"marker on its own line", "ANSWER:\n\n  Negative \nbecause", "negative"},
+		{"last marker wins", "answer: no\nwait, answer: yes", "yes"},
+		{"last line without marker", "Let me think.\n\nNeutral!\n", "neutral"},
+		{"growing lowercase before marker", strings.Repeat("Ⱥ", 10) + " answer: yes", "yes"},
+		{"shrinking lowercase before marker", strings.Repeat("İ", 10) + " Answer: Yes", "yes"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeAnswer(tt.content); got != tt.want {
+				t.Errorf("NormalizeAnswer(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+// votingProvider returns up to maxCandidates of its answers per call,
+// cycling through them, and records the options of each call.
+type votingProvider struct {
+	answers       []string
+	maxCandidates int
+	next          int
+	calls         []map[string]interface{}
+}
+
+func (p *votingProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	opts := make(map[string]interface{}, len(options))
+	for k, v := range options {
+		opts[k] = v
+	}
+	p.calls = append(p.calls, opts)
+
+	count, _ := options["candidate_count"].(int)
+	if count > p.maxCandidates {
+		count = p.maxCandidates
+	}
+	if count < 1 {
+		count = 1
+	}
+	resp := &LLMResponse{Usage: &UsageInfo{TotalTokens: 10}}
+	for i := 0; i < count; i++ {
+		resp.Candidates = append(resp.Candidates, Candidate{Content: p.answers[p.next%len(p.answers)]})
+		p.next++
+	}
+	resp.Content = resp.Candidates[0].Content
+	if count == 1 {
+		resp.Candidates = nil
+	}
+	return resp, nil
+}
+
+func (p *votingProvider) GetDefaultModel() string { return "test" }
+
+func TestSelfConsistency(t *testing.T) {
+	t.Run("candidate_count", func(t *testing.T) {
+		p := &votingProvider{answers: []string{"Answer: yes", "Answer: no", "Answer: yes"}, maxCandidates: 3}
+		got, err := SelfConsistency(context.Background(), p, nil, "m", nil, 3, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.calls) != 1 || p.calls[0]["candidate_count"] != 3 {
+			t.Errorf("calls = %v, want one call with candidate_count 3", p.calls)
+		}
+		if got.Answer != "yes" || got.Votes != 2 || got.Samples != 3 || got.Content != "Answer: yes" {
+			t.Errorf("result = %+v, want yes with 2 of 3 votes", got)
+		}
+		if temp := p.calls[0]["temperature"]; temp != defaultVoteTemperature {
+			t.Errorf("temperature = %v, want %v", temp, defaultVoteTemperature)
+		}
+	})
+
+	t.Run("top-up calls", func(t *testing.T) {
+		p := &votingProvider{answers: []string{"a", "b", "b", "c", "b"}, maxCandidates: 2}
+		got, err := SelfConsistency(context.Background(), p, nil, "m", map[string]interface{}{"temperature": 0.9}, 5, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.calls) != 4 {
+			t.Fatalf("made %d calls, want 4 (2 candidates, then 3 single samples)", len(p.calls))
+		}
+		for i, call := range p.calls[1:] {
+			if _, ok := call["candidate_count"]; ok {
+				t.Errorf("top-up call %d asked for candidates: %v", i+1, call)
+			}
+		}
+		if got.Answer != "b" || got.Votes != 3 || got.Usage.TotalTokens != 40 {
+			t.Errorf("result = %+v, want b with 3 votes and 40 tokens", got)
+		}
+	})
+
+	t.Run("tie goes to the first answer", func(t *testing.T) {
+		p := &votingProvider{answers: []string{"no", "yes", "yes", "no"}, maxCandidates: 4}
+		got, err := SelfConsistency(context.Background(), p, nil, "m", nil, 4, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Answer != "no" || got.Votes != 2 || got.Confidence() != 0.5 {
+			t.Errorf("result = %+v, want no with 2 of 4 votes", got)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// TokenCounter is implemented by providers that can count prompt tokens
+// exactly, e.g. through Gemini's countTokens endpoint.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, messages []Message, tools []ToolDefinition, model string) (int, error)
+}
+
+// ModelPrice is a model's price in US dollars per million tokens.
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// CostEstimate is the projected size and price of a request's prompt.
+// Exact is true when InputTokens came from the provider rather than the
+// four-characters-per-token heuristic; Priced is false, and Cost 0, when the
+// model has no entry in the price map.
+type CostEstimate struct {
+	InputTokens int
+	Exact       bool
+	Cost        float64 // input cost in US dollars
+	Priced      bool
+}
+
+// EstimateCost estimates the prompt tokens and input cost of sending
+// messages and tools to model, without making the request. It asks the
+// provider to count tokens when it implements TokenCounter, and falls back to
+// a character-based estimate if it doesn't or the count fails.
+func EstimateCost(ctx context.Context, provider LLMProvider, messages []Message, tools []ToolDefinition, model string, prices map[string]ModelPrice) CostEstimate {
+	var est CostEstimate
+	if counter, ok := provider.(TokenCounter); ok {
+		if n, err := counter.CountTokens(ctx, messages, tools, model); err == nil {
+			est.InputTokens, est.Exact = n, true
+		}
+	}
+	if !est.Exact {
+		est.InputTokens = estimateTokens(messages, tools)
+	}
+
+	if price, ok := lookupPrice(prices, model); ok {
+		est.Cost = float64(est.InputTokens) * price.InputPerMillion / 1e6
+		est.Priced = true
+	}
+	return est
+}
+
+// estimateTokens approximates the token count at four characters per token.
+func estimateTokens(messages []Message, tools []ToolDefinition) int {
+	chars := 0
+	for _, m := range messages {
+		chars += len(m.Content) + 16
+		for _, tc := range m.ToolCalls {
+			chars += len(tc.Name)
+			if tc.Function != nil {
+				chars += len(tc.Function.Name) + len(tc.Function.Arguments)
+			}
+		}
+	}
+	if len(tools) > 0 {
+		if data, err := json.Marshal(tools); err == nil {
+			chars += len(data)
+		}
+	}
+	return chars / 4
+}
+
+// lookupPrice finds the price for model: an exact match first, then the
+// longest key that model starts with, so "gemini-2.5-flash" also prices
+// "gemini-2.5-flash-001". A "provider/" prefix on model is ignored.
+func lookupPrice(prices map[string]ModelPrice, model string) (ModelPrice, bool) {
+	if p, ok := prices[model]; ok {
+		return p, true
+	}
+	if _, name, found := strings.Cut(model, "/"); found {
+		if p, ok := prices[name]; ok {
+			return p, true
+		}
+		model = name
+	}
+
+	keys := make([]string, 0, len(prices))
+	for k := range prices {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, k := range keys {
+		if k != "" && strings.HasPrefix(model, k) {
+			return prices[k], true
+		}
+	}
+	return ModelPrice{}, false
+}
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Provider-agnostic error kinds. Providers wrap them (usually in an
+// *APIError) so callers can branch with errors.Is instead of parsing
+// messages.
+var (
+	// ErrRateLimited means the request was throttled or quota was exhausted.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrContextLength means the prompt exceeds the model's context window.
+	ErrContextLength = errors.New("context length exceeded")
+	// ErrSafetyBlocked means the prompt or response was blocked by the
+	// provider's safety filters.
+	ErrSafetyBlocked = errors.New("blocked by safety filters")
+	// ErrAuth means the API key is missing, invalid or lacks permission.
+	ErrAuth = errors.New("authentication failed")
+	// ErrServer means the provider failed on its side (HTTP 5xx).
+	ErrServer = errors.New("provider server error")
+)
+
+// APIError is a non-2xx response from a provider API. Kind is one of the
+// Err* sentinels above, or nil if the response couldn't be classified.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	Kind       error
+}
+
+// NewAPIError builds an APIError for a failed response and classifies it.
+func NewAPIError(provider string, statusCode int, body []byte) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		Body:       string(body),
+		Kind:       classifyAPIError(statusCode, string(body)),
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.Provider == "" {
+		return fmt.Sprintf("API error (%d): %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("%s API error (%d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// contextLengthMarkers are substrings providers use in context window errors.
+var contextLengthMarkers = []string{
+	"context_length_exceeded",
+	"context length",
+	"context window",
+	"maximum context",
+	"too many tokens",
+	"prompt is too long",
+	"input token count",
+	"exceeds the maximum number of tokens",
+}
+
+func classifyAPIError(statusCode int, body string) error {
+	lower := strings.ToLower(body)
+
+	switch {
+	case statusCode == http.StatusTooManyRequests,
+		strings.Contains(lower, "resource_exhausted"),
+		strings.Contains(lower, "rate limit"),
+		strings.Contains(lower, "rate_limit"):
+		return ErrRateLimited
+	case statusCode == http.StatusUnauthorized,
+		statusCode == http.StatusForbidden,
+		strings.Contains(lower, "api key not valid"),
+		strings.Contains(lower, "invalid_api_key"),
+		strings.Contains(lower, "authentication_error"):
+		return ErrAuth
+	case statusCode >= 500:
+		if strings.Contains(lower, "overloaded") {
+			return ErrRateLimited
+		}
+		return ErrServer
+	}
+
+	if statusCode == http.StatusRequestEntityTooLarge {
+		return ErrContextLength
+	}
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(lower, marker) {
+			return ErrContextLength
+		}
+	}
+	if strings.Contains(lower, "safety") || strings.Contains(lower, "content_filter") {
+		return ErrSafetyBlocked
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// FallbackEntry is one (provider, model) pair in a fallback chain. An empty
+// Model uses the model passed to Chat.
+type FallbackEntry struct {
+	Name     string
+	Provider LLMProvider
+	Model    string
+}
+
+// FallbackUsed identifies the FallbackEntry that served a call.
+type FallbackUsed struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Model string `json:"model"`
+}
+
+// FallbackProvider tries an ordered list of providers, moving on to the next
+// one when a call fails with a retriable error (rate limit, server error,
+// timeout, network failure). Non-retriable errors are returned immediately.
+// Each response records the entry that served it in LLMResponse.Fallback.
+type FallbackProvider struct {
+	entries []FallbackEntry
+}
+
+func NewFallbackProvider(entries ...FallbackEntry) *FallbackProvider {
+	return &FallbackProvider{entries: entries}
+}
+
+func (f *FallbackProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	if len(f.entries) == 0 {
+		return nil, fmt.Errorf("fallback provider has no entries")
+	}
+
+	var lastErr error
+	for i, entry := range f.entries {
+		entryModel := entry.Model
+		if entryModel == "" {
+			entryModel = model
+		}
+
+		resp, err := entry.Provider.Chat(ctx, messages, tools, entryModel, options)
+		if err == nil {
+			resp.Fallback = &FallbackUsed{Index: i, Name: entry.Name, Model: entryModel}
+			if i > 0 {
+				logger.WarnCtx(ctx, "provider", "Fallback provider used", map[string]interface{}{
+					"index":    i,
+					"provider": entry.Name,
+					"model":    entryModel,
+				})
+			}
+			return resp, nil
+		}
+
+		lastErr = err
+		if ctx.Err() != nil || !IsRetriableError(err) {
+			return nil, err
+		}
+
+		logger.WarnCtx(ctx, "provider", "Provider call failed, trying next fallback", map[string]interface{}{
+			"index":    i,
+			"provider": entry.Name,
+			"model":    entryModel,
+			"error":    err.Error(),
+		})
+	}
+
+	return nil, fmt.Errorf("all %d fallback providers failed: %w", len(f.entries), lastErr)
+}
+
+// CountTokens implements TokenCounter using the primary entry, when it
+// supports counting.
+func (f *FallbackProvider) CountTokens(ctx context.Context, messages []Message, tools []ToolDefinition, model string) (int, error) {
+	if len(f.entries) == 0 {
+		return 0, fmt.Errorf("fallback provider has no entries")
+	}
+	counter, ok := f.entries[0].Provider.(TokenCounter)
+	if !ok {
+		return 0, fmt.Errorf("provider does not support token counting")
+	}
+	if f.entries[0].Model != "" {
+		model = f.entries[0].Model
+	}
+	return counter.CountTokens(ctx, messages, tools, model)
+}
+
+func (f *FallbackProvider) GetDefaultModel() string {
+	if len(f.entries) == 0 {
+		return ""
+	}
+	if f.entries[0].Model != "" {
+		return f.entries[0].Model
+	}
+	return f.entries[0].Provider.GetDefaultModel()
+}
+
+var statusCodePattern = regexp.MustCompile(`\((\d{3})\)`)
+
+// IsRetriableError reports whether a provider error is worth retrying on
+// another provider: rate limits, server errors, HTTP 408, timeouts and network
+// failures. Auth, context-length and safety errors are not.
+func IsRetriableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServer) {
+		return true
+	}
+	if errors.Is(err, ErrAuth) || errors.Is(err, ErrContextLength) || errors.Is(err, ErrSafetyBlocked) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == 408
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// Errors from providers that don't return typed errors.
+	msg := err.Error()
+	if m := statusCodePattern.FindStringSubmatch(msg); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code == 408 || code == 429 || code >= 500
+	}
+
+	lower := strings.ToLower(msg)
+	return strings.Contains(lower, "rate limit") || strings.Contains(lower, "overloaded")
+}
@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// echoProvider answers with the last message, or fails with err when the
+// message is in failOn.
+type echoProvider struct {
+	failOn map[string]bool
+	err    error
+}
+
+func (p *echoProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	content := messages[len(messages)-1].Content
+	if p.failOn[content] {
+		return nil, p.err
+	}
+	return &LLMResponse{Content: content}, nil
+}
+
+func (p *echoProvider) GetDefaultModel() string { return "echo" }
+
+func TestFallbackProviderRecordsEntryPerResponse(t *testing.T) {
+	primary := &echoProvider{failOn: map[string]bool{"busy": true, "bad": true}, err: ErrRateLimited}
+	f := NewFallbackProvider(
+		FallbackEntry{Name: "primary", Provider: primary, Model: "big"},
+		FallbackEntry{Name: "backup", Provider: &echoProvider{}, Model: "small"},
+	)
+
+	// Concurrent calls served by different entries each see their own.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		content, want := "ok", "primary"
+		if i%2 == 1 {
+			content, want = "busy", "backup"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := f.Chat(context.Background(), []Message{{Role: "user", Content: content}}, nil, "", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.Fallback == nil || resp.Fallback.Name != want {
+				t.Errorf("%s served by %+v, want %s", content, resp.Fallback, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	primary.err = fmt.Errorf("bad request")
+	if _, err := f.Chat(context.Background(), []Message{{Role: "user", Content: "bad"}}, nil, "", nil); err == nil || err.Error() != "bad request" {
+		t.Errorf("non-retriable error = %v, want it returned without falling back", err)
+	}
+}
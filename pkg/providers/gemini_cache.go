@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CachedContentExpiredError is returned when a request references a Gemini
+// cachedContent that no longer exists (expired TTL or deleted). Callers should
+// recreate the cache with CreateCachedContent and retry.
+type CachedContentExpiredError struct {
+	Name       string
+	StatusCode int
+	Body       string
+}
+
+func (e *CachedContentExpiredError) Error() string {
+	return fmt.Sprintf("Gemini cached content %q expired or not found (%d): %s", e.Name, e.StatusCode, e.Body)
+}
+
+// CreateCachedContent uploads a system instruction and conversation prefix to
+// Gemini's cachedContents API so that subsequent Chat calls can reference it
+// via options["cached_content"]. Tools must be cached here too, since Gemini
+// does not accept them alongside a cache reference. Returns the cache name
+// (e.g. "cachedContents/abc").
+func (g *GeminiProvider) CreateCachedContent(ctx context.Context, model, systemInstruction string, contents []Message, tools []ToolDefinition, ttl time.Duration) (string, error) {
+	if g.apiBase == "" {
+		return "", fmt.Errorf("Gemini API base not configured")
+	}
+
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	geminiContents, extraSystem := convertMessagesToGemini(contents)
+	if extraSystem != "" {
+		if systemInstruction == "" {
+			systemInstruction = extraSystem
+		} else {
+			systemInstruction += "\n" + extraSystem
+		}
+	}
+
+	if !strings.HasPrefix(model, "models/") {
+		model = "models/" + model
+	}
+
+	body := map[string]interface{}{
+		"model": model,
+	}
+	if len(geminiContents) > 0 {
+		body["contents"] = geminiContents
+	}
+	if systemInstruction != "" {
+		body["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{
+				{"text": systemInstruction},
+			},
+		}
+	}
+	if len(tools) > 0 {
+		addGeminiTools(body, tools)
+	}
+	if ttl > 0 {
+		body["ttl"] = fmt.Sprintf("%ds", int64(ttl.Seconds()))
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal Gemini cache request: %w", err)
+	}
+
+	url := g.endpoint("cachedContents?key=" + g.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Gemini cache request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Gemini cache request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Gemini cache response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", NewAPIError("Gemini cache", resp.StatusCode, respBody)
+	}
+
+	var cacheResp struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(respBody, &cacheResp); err != nil {
+		return "", fmt.Errorf("failed to parse Gemini cache response: %w", err)
+	}
+	if cacheResp.Name == "" {
+		return "", fmt.Errorf("Gemini cache response missing name")
+	}
+
+	return cacheResp.Name, nil
+}
+
+// isCachedContentError reports whether an error response refers to a missing
+// or expired cachedContent resource.
+func isCachedContentError(statusCode int, body []byte) bool {
+	if statusCode != http.StatusNotFound && statusCode != http.StatusForbidden && statusCode != http.StatusBadRequest {
+		return false
+	}
+	lower := strings.ToLower(string(body))
+	return strings.Contains(lower, "cachedcontent") || strings.Contains(lower, "cached content")
+}
@@ -0,0 +1,50 @@
+package providers
+
+// geminiGroundingMetadata is the groundingMetadata block Gemini attaches to a
+// candidate when the googleSearch tool was used.
+type geminiGroundingMetadata struct {
+	WebSearchQueries []string `json:"webSearchQueries"`
+	GroundingChunks  []struct {
+		Web *struct {
+			URI   string `json:"uri"`
+			Title string `json:"title"`
+		} `json:"web"`
+	} `json:"groundingChunks"`
+	SearchEntryPoint *struct {
+		RenderedContent string `json:"renderedContent"`
+	} `json:"searchEntryPoint"`
+}
+
+// googleSearchTool is the Gemini tool entry that enables Search grounding.
+func googleSearchTool() map[string]interface{} {
+	return map[string]interface{}{"googleSearch": map[string]interface{}{}}
+}
+
+// convertGrounding turns Gemini grounding metadata into a Grounding, or nil
+// if there's nothing to report.
+func convertGrounding(meta *geminiGroundingMetadata) *Grounding {
+	if meta == nil {
+		return nil
+	}
+
+	g := &Grounding{SearchQueries: meta.WebSearchQueries}
+	seen := make(map[string]bool)
+	for _, chunk := range meta.GroundingChunks {
+		if chunk.Web == nil || chunk.Web.URI == "" || seen[chunk.Web.URI] {
+			continue
+		}
+		seen[chunk.Web.URI] = true
+		g.Sources = append(g.Sources, GroundingSource{
+			Title: chunk.Web.Title,
+			URI:   chunk.Web.URI,
+		})
+	}
+	if meta.SearchEntryPoint != nil {
+		g.SearchEntryPoint = meta.SearchEntryPoint.RenderedContent
+	}
+
+	if len(g.SearchQueries) == 0 && len(g.Sources) == 0 && g.SearchEntryPoint == "" {
+		return nil
+	}
+	return g
+}
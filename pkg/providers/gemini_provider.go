@@ -7,26 +7,119 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultGeminiTimeout    = 120 * time.Second
+	defaultGeminiAPIBase    = "https://generativelanguage.googleapis.com"
+	defaultGeminiAPIVersion = "v1beta"
 )
 
+// geminiVersionSuffix matches an API base that already ends in a version
+// segment, as older configs did ("https://.../v1beta").
+var geminiVersionSuffix = regexp.MustCompile(`/v\d+(alpha|beta)?\d*$`)
+
+func init() {
+	RegisterProvider("gemini", newGeminiProviderFromConfig)
+}
+
+func newGeminiProviderFromConfig(cfg *config.Config) (LLMProvider, error) {
+	apiKey := cfg.Providers.Gemini.APIKey
+	apiBase := cfg.Providers.Gemini.APIBase
+	if apiBase == "" {
+		apiBase = defaultGeminiAPIBase
+	}
+	var provider *GeminiProvider
+	if proxy := cfg.Providers.Gemini.Proxy; proxy != "" {
+		transport, err := newProxyTransport(proxy)
+		if err != nil {
+			return nil, err
+		}
+		provider = NewGeminiProviderWithTransport(apiKey, apiBase, transport)
+	} else {
+		provider = NewGeminiProvider(apiKey, apiBase)
+	}
+	if version := cfg.Providers.Gemini.APIVersion; version != "" {
+		provider.SetAPIVersion(version)
+	}
+	return provider, nil
+}
+
 // GeminiProvider implements LLMProvider using the native Gemini REST API.
 type GeminiProvider struct {
 	apiKey     string
 	apiBase    string
+	apiVersion string
+	timeout    time.Duration
 	httpClient *http.Client
 }
 
 func NewGeminiProvider(apiKey, apiBase string) *GeminiProvider {
+	return NewGeminiProviderWithClient(apiKey, apiBase, nil)
+}
+
+// NewGeminiProviderWithClient creates a GeminiProvider that sends requests
+// through the given http.Client, e.g. one with a proxying Transport. A nil
+// client falls back to the default. The provider timeout still applies on top
+// of any timeout configured on the client.
+func NewGeminiProviderWithClient(apiKey, apiBase string, client *http.Client) *GeminiProvider {
+	if client == nil {
+		client = &http.Client{}
+	}
 	return &GeminiProvider{
-		apiKey:  apiKey,
-		apiBase: apiBase,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		apiKey:     apiKey,
+		apiBase:    apiBase,
+		apiVersion: defaultGeminiAPIVersion,
+		timeout:    defaultGeminiTimeout,
+		httpClient: client,
 	}
 }
 
+// NewGeminiProviderWithTransport creates a GeminiProvider whose requests go
+// through the given transport.
+func NewGeminiProviderWithTransport(apiKey, apiBase string, transport http.RoundTripper) *GeminiProvider {
+	return NewGeminiProviderWithClient(apiKey, apiBase, &http.Client{Transport: transport})
+}
+
+// SetTimeout overrides the per-request timeout (default 120s). A zero or
+// negative value disables the provider timeout so only the caller's context
+// deadline applies. When the context passed to Chat carries an earlier
+// deadline, that deadline wins.
+func (g *GeminiProvider) SetTimeout(timeout time.Duration) {
+	g.timeout = timeout
+}
+
+// SetAPIVersion sets the version segment inserted between the API base and
+// the endpoint path (default "v1beta"), e.g. "v1" or "v1alpha". It has no
+// effect when the API base already ends in a version segment.
+func (g *GeminiProvider) SetAPIVersion(version string) {
+	g.apiVersion = strings.Trim(version, "/")
+}
+
+// endpoint returns the full URL for an API path such as
+// "models/gemini-2.5-flash:generateContent".
+func (g *GeminiProvider) endpoint(path string) string {
+	base := strings.TrimRight(g.apiBase, "/")
+	if g.apiVersion != "" && !geminiVersionSuffix.MatchString(base) {
+		base += "/" + g.apiVersion
+	}
+	return base + "/" + path
+}
+
+// withTimeout derives a request context bounded by the provider timeout.
+func (g *GeminiProvider) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, g.timeout)
+}
+
 // geminiContent represents a single turn in the Gemini conversation.
 type geminiContent struct {
 	Role  string       `json:"role"`
@@ -35,9 +128,16 @@ type geminiContent struct {
 
 // geminiPart is a union type for text, function call, or function response.
 type geminiPart struct {
-	Text             string                `json:"text,omitempty"`
-	FunctionCall     *geminiFunctionCall   `json:"functionCall,omitempty"`
-	FunctionResponse *geminiFuncResponse   `json:"functionResponse,omitempty"`
+	Text             string              `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFuncResponse `json:"functionResponse,omitempty"`
+	InlineData       *geminiBlob         `json:"inlineData,omitempty"`
+}
+
+// geminiBlob is inline binary data, e.g. an image.
+type geminiBlob struct {
+	MIMEType string `json:"mimeType"`
+	Data     string `json:"data"`
 }
 
 type geminiFunctionCall struct {
@@ -61,6 +161,9 @@ func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, tools []T
 		return nil, fmt.Errorf("Gemini API base not configured")
 	}
 
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
 	// Build request body
 	body := map[string]interface{}{}
 
@@ -68,31 +171,32 @@ func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, tools []T
 	contents, systemInstruction := convertMessagesToGemini(messages)
 	body["contents"] = contents
 
-	if systemInstruction != "" {
-		body["systemInstruction"] = map[string]interface{}{
-			"parts": []map[string]interface{}{
-				{"text": systemInstruction},
-			},
+	// A cachedContent already carries the system instruction and tools; Gemini
+	// rejects requests that set them again alongside the cache reference.
+	cachedContent, _ := options["cached_content"].(string)
+	if cachedContent != "" {
+		body["cachedContent"] = cachedContent
+	} else {
+		if systemInstruction != "" {
+			body["systemInstruction"] = map[string]interface{}{
+				"parts": []map[string]interface{}{
+					{"text": systemInstruction},
+				},
+			}
 		}
-	}
 
-	// Convert tools
-	if len(tools) > 0 {
-		declarations := make([]geminiFunctionDeclaration, 0, len(tools))
-		for _, t := range tools {
-			declarations = append(declarations, geminiFunctionDeclaration{
-				Name:        t.Function.Name,
-				Description: t.Function.Description,
-				Parameters:  t.Function.Parameters,
-			})
-		}
-		body["tools"] = []map[string]interface{}{
-			{"functionDeclarations": declarations},
-		}
-		body["toolConfig"] = map[string]interface{}{
-			"functionCallingConfig": map[string]interface{}{
-				"mode": "AUTO",
-			},
+		// Convert tools. generateContent rejects googleSearch combined with
+		// function declarations, so function calling wins when both are set.
+		googleSearch, _ := options["google_search"].(bool)
+		if len(tools) > 0 {
+			addGeminiTools(body, tools)
+			if googleSearch {
+				logger.DebugCtx(ctx, "provider", "Gemini Search grounding skipped: not supported with function calling", map[string]interface{}{
+					"tools_count": len(tools),
+				})
+			}
+		} else if googleSearch {
+			body["tools"] = []map[string]interface{}{googleSearchTool()}
 		}
 	}
 
@@ -104,6 +208,16 @@ func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, tools []T
 	if temperature, ok := options["temperature"].(float64); ok {
 		genConfig["temperature"] = temperature
 	}
+	if candidateCount, ok := options["candidate_count"].(int); ok && candidateCount > 0 {
+		genConfig["candidateCount"] = candidateCount
+	}
+	// thinking_budget caps the tokens a thinking model may spend reasoning;
+	// 0 disables thinking and -1 lets the model decide.
+	if budget, ok := intOption(options, "thinking_budget"); ok {
+		genConfig["thinkingConfig"] = map[string]interface{}{
+			"thinkingBudget": budget,
+		}
+	}
 	if len(genConfig) > 0 {
 		body["generationConfig"] = genConfig
 	}
@@ -113,8 +227,8 @@ func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, tools []T
 		return nil, fmt.Errorf("failed to marshal Gemini request: %w", err)
 	}
 
-	// Gemini endpoint: /v1beta/models/{model}:generateContent?key={apiKey}
-	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", g.apiBase, model, g.apiKey)
+	// Gemini endpoint: /{version}/models/{model}:generateContent?key={apiKey}
+	url := g.endpoint(fmt.Sprintf("models/%s:generateContent?key=%s", model, g.apiKey))
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
 	if err != nil {
@@ -134,7 +248,14 @@ func (g *GeminiProvider) Chat(ctx context.Context, messages []Message, tools []T
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Gemini API error (%d): %s", resp.StatusCode, string(respBody))
+		if cachedContent != "" && isCachedContentError(resp.StatusCode, respBody) {
+			return nil, &CachedContentExpiredError{
+				Name:       cachedContent,
+				StatusCode: resp.StatusCode,
+				Body:       string(respBody),
+			}
+		}
+		return nil, NewAPIError("Gemini", resp.StatusCode, respBody)
 	}
 
 	return parseGeminiResponse(respBody)
@@ -144,11 +265,35 @@ func (g *GeminiProvider) GetDefaultModel() string {
 	return "gemini-2.5-flash"
 }
 
+// addGeminiTools sets the function declarations and tool config on a Gemini
+// request body.
+func addGeminiTools(body map[string]interface{}, tools []ToolDefinition) {
+	declarations := make([]geminiFunctionDeclaration, 0, len(tools))
+	for _, t := range tools {
+		declarations = append(declarations, geminiFunctionDeclaration{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			Parameters:  t.Function.Parameters,
+		})
+	}
+	body["tools"] = []map[string]interface{}{
+		{"functionDeclarations": declarations},
+	}
+	body["toolConfig"] = map[string]interface{}{
+		"functionCallingConfig": map[string]interface{}{
+			"mode": "AUTO",
+		},
+	}
+}
+
 // convertMessagesToGemini converts OpenAI-style messages to Gemini format.
 // Returns (contents, systemInstruction).
 func convertMessagesToGemini(messages []Message) ([]geminiContent, string) {
 	var contents []geminiContent
 	var systemInstruction string
+	var callNames map[string]string
+	var callOrder []string
+	resultIndex := 0
 
 	for _, msg := range messages {
 		switch msg.Role {
@@ -165,7 +310,11 @@ func convertMessagesToGemini(messages []Message) ([]geminiContent, string) {
 			if msg.Content != "" {
 				parts = append(parts, geminiPart{Text: msg.Content})
 			}
-			// Convert tool calls from assistant
+			// Gemini matches results to calls by name, so remember which
+			// name each call ID had for the tool messages that follow.
+			callNames = make(map[string]string, len(msg.ToolCalls))
+			callOrder = callOrder[:0]
+			resultIndex = 0
 			for _, tc := range msg.ToolCalls {
 				args := tc.Arguments
 				if args == nil {
@@ -178,6 +327,8 @@ func convertMessagesToGemini(messages []Message) ([]geminiContent, string) {
 						_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
 					}
 				}
+				callNames[tc.ID] = name
+				callOrder = append(callOrder, name)
 				parts = append(parts, geminiPart{
 					FunctionCall: &geminiFunctionCall{
 						Name: name,
@@ -195,36 +346,39 @@ func convertMessagesToGemini(messages []Message) ([]geminiContent, string) {
 			if err := json.Unmarshal([]byte(msg.Content), &result); err != nil {
 				result = map[string]interface{}{"result": msg.Content}
 			}
-			// Find the tool name from ToolCallID by looking back at previous assistant message
-			toolName := "unknown"
-			if msg.ToolCallID != "" {
-				for i := len(contents) - 1; i >= 0; i-- {
-					for _, p := range contents[i].Parts {
-						if p.FunctionCall != nil {
-							// Match by position (Gemini doesn't use IDs)
-							toolName = p.FunctionCall.Name
-							break
-						}
-					}
-					if toolName != "unknown" {
-						break
-					}
+			// Match the call by ID, falling back to position for results
+			// without one.
+			toolName, ok := callNames[msg.ToolCallID]
+			if !ok || msg.ToolCallID == "" {
+				toolName = "unknown"
+				if resultIndex < len(callOrder) {
+					toolName = callOrder[resultIndex]
 				}
 			}
-			contents = append(contents, geminiContent{
-				Role: "user",
-				Parts: []geminiPart{{
-					FunctionResponse: &geminiFuncResponse{
-						Name:     toolName,
-						Response: result,
-					},
-				}},
-			})
+			resultIndex++
+			part := geminiPart{
+				FunctionResponse: &geminiFuncResponse{
+					Name:     toolName,
+					Response: result,
+				},
+			}
+			// All responses to one turn's calls go in a single content.
+			if n := len(contents); n > 0 && contents[n-1].Role == "user" && contents[n-1].Parts[0].FunctionResponse != nil {
+				contents[n-1].Parts = append(contents[n-1].Parts, part)
+			} else {
+				contents = append(contents, geminiContent{Role: "user", Parts: []geminiPart{part}})
+			}
 
 		default: // "user"
+			parts := []geminiPart{{Text: msg.Content}}
+			for _, a := range msg.Attachments {
+				parts = append(parts, geminiPart{
+					InlineData: &geminiBlob{MIMEType: a.MIMEType, Data: a.Base64()},
+				})
+			}
 			contents = append(contents, geminiContent{
-				Role: "user",
-				Parts: []geminiPart{{Text: msg.Content}},
+				Role:  "user",
+				Parts: parts,
 			})
 		}
 	}
@@ -232,38 +386,93 @@ func convertMessagesToGemini(messages []Message) ([]geminiContent, string) {
 	return contents, systemInstruction
 }
 
+// geminiCandidate is a single candidate in a generateContent response.
+type geminiCandidate struct {
+	Content struct {
+		Parts []struct {
+			Text         string `json:"text"`
+			FunctionCall *struct {
+				Name string                 `json:"name"`
+				Args map[string]interface{} `json:"args"`
+			} `json:"functionCall"`
+		} `json:"parts"`
+		Role string `json:"role"`
+	} `json:"content"`
+	FinishReason      string                   `json:"finishReason"`
+	GroundingMetadata *geminiGroundingMetadata `json:"groundingMetadata"`
+}
+
 func parseGeminiResponse(body []byte) (*LLMResponse, error) {
 	var resp struct {
-		Candidates []struct {
-			Content struct {
-				Parts []struct {
-					Text         string `json:"text"`
-					FunctionCall *struct {
-						Name string                 `json:"name"`
-						Args map[string]interface{} `json:"args"`
-					} `json:"functionCall"`
-				} `json:"parts"`
-				Role string `json:"role"`
-			} `json:"content"`
-			FinishReason string `json:"finishReason"`
-		} `json:"candidates"`
+		Candidates    []geminiCandidate `json:"candidates"`
 		UsageMetadata *struct {
 			PromptTokenCount     int `json:"promptTokenCount"`
 			CandidatesTokenCount int `json:"candidatesTokenCount"`
 			TotalTokenCount      int `json:"totalTokenCount"`
 		} `json:"usageMetadata"`
+		PromptFeedback *struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
 	}
 
 	if err := json.Unmarshal(body, &resp); err != nil {
 		return nil, fmt.Errorf("failed to parse Gemini response: %w", err)
 	}
 
+	if len(resp.Candidates) == 0 && resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		return nil, fmt.Errorf("Gemini blocked the prompt (%s): %w", resp.PromptFeedback.BlockReason, ErrSafetyBlocked)
+	}
+
 	if len(resp.Candidates) == 0 {
-		return &LLMResponse{Content: "", FinishReason: "stop"}, nil
+		return nil, fmt.Errorf("Gemini returned no candidates")
+	}
+
+	// A first candidate with no parts at all was withheld rather than
+	// answered; a function-call-only candidate still has parts.
+	if first := resp.Candidates[0]; len(first.Content.Parts) == 0 && isGeminiBlockReason(first.FinishReason) {
+		return nil, fmt.Errorf("Gemini blocked the response (%s): %w", first.FinishReason, ErrSafetyBlocked)
+	}
+
+	candidates := make([]Candidate, 0, len(resp.Candidates))
+	for _, c := range resp.Candidates {
+		candidates = append(candidates, convertGeminiCandidate(c))
 	}
 
-	candidate := resp.Candidates[0]
+	// Top-level fields mirror the first candidate for callers that only
+	// expect a single completion.
+	result := &LLMResponse{
+		Content:         candidates[0].Content,
+		ToolCalls:       candidates[0].ToolCalls,
+		FinishReason:    candidates[0].FinishReason,
+		RawFinishReason: candidates[0].RawFinishReason,
+		Grounding:       candidates[0].Grounding,
+		Candidates:      candidates,
+	}
 
+	if resp.UsageMetadata != nil {
+		result.Usage = &UsageInfo{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+		}
+	}
+
+	return result, nil
+}
+
+// isGeminiBlockReason reports whether a finish reason means the candidate was
+// withheld by Gemini's content filters.
+func isGeminiBlockReason(reason string) bool {
+	switch reason {
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return true
+	}
+	return false
+}
+
+// convertGeminiCandidate extracts text, tool calls and a normalized finish
+// reason from a Gemini candidate.
+func convertGeminiCandidate(candidate geminiCandidate) Candidate {
 	var content string
 	var toolCalls []ToolCall
 
@@ -290,19 +499,23 @@ func parseGeminiResponse(body []byte) (*LLMResponse, error) {
 		finishReason = "stop"
 	}
 
-	result := &LLMResponse{
-		Content:      content,
-		ToolCalls:    toolCalls,
-		FinishReason: finishReason,
+	return Candidate{
+		Content:         content,
+		ToolCalls:       toolCalls,
+		FinishReason:    finishReason,
+		RawFinishReason: candidate.FinishReason,
+		Grounding:       convertGrounding(candidate.GroundingMetadata),
 	}
+}
 
-	if resp.UsageMetadata != nil {
-		result.Usage = &UsageInfo{
-			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
-			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
-			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
-		}
+// intOption reads an integer option, accepting float64 for values decoded
+// from JSON.
+func intOption(options map[string]interface{}, key string) (int, bool) {
+	switch v := options[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
 	}
-
-	return result, nil
+	return 0, false
 }
@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGeminiResponseCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"one", `{"candidates":[{"content":{"parts":[{"text":"yes"}]},"finishReason":"STOP"}]}`, []string{"yes"}},
+		{"several", `{"candidates":[{"content":{"parts":[{"text":"yes"}]}},{"content":{"parts":[{"text":"no"}]}}]}`, []string{"yes", "no"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := parseGeminiResponse([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range resp.Candidates {
+				got = append(got, c.Content)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("candidates = %q, want %q", got, tt.want)
+			}
+			if resp.Content != tt.want[0] {
+				t.Errorf("content = %q, want the first candidate %q", resp.Content, tt.want[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// CountTokens implements TokenCounter using Gemini's countTokens endpoint,
+// counting the system instruction and tools along with the contents.
+func (g *GeminiProvider) CountTokens(ctx context.Context, messages []Message, tools []ToolDefinition, model string) (int, error) {
+	if g.apiBase == "" {
+		return 0, fmt.Errorf("Gemini API base not configured")
+	}
+
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	contents, systemInstruction := convertMessagesToGemini(messages)
+	name := model
+	if !strings.HasPrefix(name, "models/") {
+		name = "models/" + name
+	}
+	request := map[string]interface{}{
+		"model":    name,
+		"contents": contents,
+	}
+	if systemInstruction != "" {
+		request["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{
+				{"text": systemInstruction},
+			},
+		}
+	}
+	if len(tools) > 0 {
+		addGeminiTools(request, tools)
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{"generateContentRequest": request})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal Gemini countTokens request: %w", err)
+	}
+
+	url := g.endpoint(fmt.Sprintf("models/%s:countTokens?key=%s", strings.TrimPrefix(model, "models/"), g.apiKey))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Gemini countTokens request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("Gemini countTokens request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Gemini countTokens response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, NewAPIError("Gemini", resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		TotalTokens int `json:"totalTokens"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return 0, fmt.Errorf("failed to parse Gemini countTokens response: %w", err)
+	}
+	return result.TotalTokens, nil
+}
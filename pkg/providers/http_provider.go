@@ -13,36 +13,67 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 )
 
+// defaultHTTPTimeout bounds each chat/completions request unless SetTimeout
+// says otherwise.
+const defaultHTTPTimeout = 120 * time.Second
+
+// HTTPProvider implements LLMProvider against an OpenAI-compatible
+// chat/completions endpoint. OpenAIProvider and the openrouter, zhipu and
+// groq providers all use it.
 type HTTPProvider struct {
-	apiKey     string
-	apiBase    string
+	apiKey  string
+	apiBase string
+	// name labels API errors, e.g. "OpenAI"; empty for a generic endpoint.
+	name       string
+	timeout    time.Duration
 	httpClient *http.Client
 }
 
 func NewHTTPProvider(apiKey, apiBase string) *HTTPProvider {
+	return NewHTTPProviderWithClient(apiKey, apiBase, nil)
+}
+
+// NewHTTPProviderWithClient creates an HTTPProvider using the given
+// http.Client. A nil client falls back to the default.
+func NewHTTPProviderWithClient(apiKey, apiBase string, client *http.Client) *HTTPProvider {
+	if client == nil {
+		client = &http.Client{}
+	}
 	return &HTTPProvider{
-		apiKey:  apiKey,
-		apiBase: apiBase,
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
+		apiKey:     apiKey,
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		timeout:    defaultHTTPTimeout,
+		httpClient: client,
 	}
 }
 
+// SetTimeout overrides the per-request timeout (default 120s). A zero or
+// negative value leaves only the caller's context deadline in effect.
+func (p *HTTPProvider) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
 func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
 	if p.apiBase == "" {
 		return nil, fmt.Errorf("API base not configured")
 	}
 
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
 	requestBody := map[string]interface{}{
 		"model":    model,
-		"messages": messages,
+		"messages": convertMessagesToOpenAI(messages),
 	}
 
 	if len(tools) > 0 {
@@ -86,87 +117,101 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s", string(body))
+		return nil, NewAPIError(p.name, resp.StatusCode, body)
 	}
 
-	return p.parseResponse(body)
+	return parseOpenAIResponse(body)
 }
 
-func (p *HTTPProvider) parseResponse(body []byte) (*LLMResponse, error) {
-	var apiResponse struct {
-		Choices []struct {
-			Message struct {
-				Content   string `json:"content"`
-				ToolCalls []struct {
-					ID       string `json:"id"`
-					Type     string `json:"type"`
-					Function *struct {
-						Name      string `json:"name"`
-						Arguments string `json:"arguments"`
-					} `json:"function"`
-				} `json:"tool_calls"`
-			} `json:"message"`
-			FinishReason string `json:"finish_reason"`
-		} `json:"choices"`
-		Usage *UsageInfo `json:"usage"`
-	}
-
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	if len(apiResponse.Choices) == 0 {
-		return &LLMResponse{
-			Content:      "",
-			FinishReason: "stop",
-		}, nil
-	}
-
-	choice := apiResponse.Choices[0]
-
-	toolCalls := make([]ToolCall, 0, len(choice.Message.ToolCalls))
-	for _, tc := range choice.Message.ToolCalls {
-		arguments := make(map[string]interface{})
-		name := ""
-
-		// Handle OpenAI format with nested function object
-		if tc.Type == "function" && tc.Function != nil {
-			name = tc.Function.Name
-			if tc.Function.Arguments != "" {
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
-					arguments["raw"] = tc.Function.Arguments
-				}
+func (p *HTTPProvider) GetDefaultModel() string {
+	return ""
+}
+
+func init() {
+	httpProviders := []struct {
+		name        string
+		defaultBase string
+		get         func(cfg *config.Config) config.ProviderConfig
+	}{
+		{"openrouter", "https://openrouter.ai/api/v1", func(cfg *config.Config) config.ProviderConfig { return cfg.Providers.OpenRouter }},
+		{"zhipu", "https://open.bigmodel.cn/api/paas/v4", func(cfg *config.Config) config.ProviderConfig { return cfg.Providers.Zhipu }},
+		{"groq", "https://api.groq.com/openai/v1", func(cfg *config.Config) config.ProviderConfig { return cfg.Providers.Groq }},
+	}
+	for _, hp := range httpProviders {
+		hp := hp
+		RegisterProvider(hp.name, func(cfg *config.Config) (LLMProvider, error) {
+			pc := hp.get(cfg)
+			apiBase := pc.APIBase
+			if apiBase == "" {
+				apiBase = hp.defaultBase
 			}
-		} else if tc.Function != nil {
-			// Legacy format without type field
-			name = tc.Function.Name
-			if tc.Function.Arguments != "" {
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
-					arguments["raw"] = tc.Function.Arguments
-				}
+			if apiBase == "" {
+				return nil, fmt.Errorf("no API base configured for provider %s", hp.name)
 			}
-		}
-
-		toolCalls = append(toolCalls, ToolCall{
-			ID:        tc.ID,
-			Name:      name,
-			Arguments: arguments,
+			return NewHTTPProvider(pc.APIKey, apiBase), nil
 		})
 	}
-
-	return &LLMResponse{
-		Content:      choice.Message.Content,
-		ToolCalls:    toolCalls,
-		FinishReason: choice.FinishReason,
-		Usage:        apiResponse.Usage,
-	}, nil
 }
 
-func (p *HTTPProvider) GetDefaultModel() string {
-	return ""
+// newProxyTransport returns a clone of the default transport that routes
+// requests through the given proxy URL.
+func newProxyTransport(proxy string) (*http.Transport, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", proxy, err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return transport, nil
 }
 
+// CreateProvider builds the provider for the configured model. When
+// agents.defaults.fallbacks is set, the result is wrapped in a
+// FallbackProvider that tries each "provider:model" entry in order, and when
+// agents.defaults.response_cache_ttl_seconds is set, in a CachingProvider.
 func CreateProvider(cfg *config.Config) (LLMProvider, error) {
+	provider, err := createProviderChain(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if ttl := cfg.Agents.Defaults.ResponseCacheTTLSeconds; ttl > 0 {
+		return NewCachingProvider(provider, nil, time.Duration(ttl)*time.Second), nil
+	}
+	return provider, nil
+}
+
+func createProviderChain(cfg *config.Config) (LLMProvider, error) {
+	primary, err := createPrimaryProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbacks := cfg.Agents.Defaults.Fallbacks
+	if len(fallbacks) == 0 {
+		return primary, nil
+	}
+
+	entries := []FallbackEntry{{Name: "primary", Provider: primary, Model: cfg.Agents.Defaults.Model}}
+	for _, fb := range fallbacks {
+		name, model, _ := strings.Cut(fb, ":")
+		provider, err := NewProvider(name, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("fallback %q: %w", fb, err)
+		}
+		if model == "" {
+			model = provider.GetDefaultModel()
+		}
+		entries = append(entries, FallbackEntry{Name: name, Provider: provider, Model: model})
+	}
+
+	return NewFallbackProvider(entries...), nil
+}
+
+func createPrimaryProvider(cfg *config.Config) (LLMProvider, error) {
+	if name := cfg.Agents.Defaults.Provider; name != "" {
+		return NewProvider(name, cfg)
+	}
+
 	model := cfg.Agents.Defaults.Model
 
 	var apiKey, apiBase string
@@ -183,27 +228,20 @@ func CreateProvider(cfg *config.Config) (LLMProvider, error) {
 		}
 
 	case strings.Contains(lowerModel, "claude") || strings.HasPrefix(model, "anthropic/"):
-		apiKey = cfg.Providers.Anthropic.APIKey
-		apiBase = cfg.Providers.Anthropic.APIBase
-		if apiBase == "" {
-			apiBase = "https://api.anthropic.com/v1"
+		if cfg.Providers.Anthropic.APIKey == "" {
+			return nil, fmt.Errorf("no API key configured for provider (model: %s)", model)
 		}
+		return NewProvider("anthropic", cfg)
 
 	case strings.Contains(lowerModel, "gpt") || strings.HasPrefix(model, "openai/"):
-		apiKey = cfg.Providers.OpenAI.APIKey
-		apiBase = cfg.Providers.OpenAI.APIBase
-		if apiBase == "" {
-			apiBase = "https://api.openai.com/v1"
+		if cfg.Providers.OpenAI.APIKey == "" {
+			return nil, fmt.Errorf("no API key configured for provider (model: %s)", model)
 		}
+		return NewProvider("openai", cfg)
 
 	case strings.Contains(lowerModel, "gemini") || strings.HasPrefix(model, "google/"):
-		apiKey = cfg.Providers.Gemini.APIKey
-		apiBase = cfg.Providers.Gemini.APIBase
-		if apiBase == "" {
-			apiBase = "https://generativelanguage.googleapis.com/v1beta"
-		}
 		// Native Gemini API — use dedicated provider
-		return NewGeminiProvider(apiKey, apiBase), nil
+		return NewProvider("gemini", cfg)
 
 	case strings.Contains(lowerModel, "glm") || strings.Contains(lowerModel, "zhipu") || strings.Contains(lowerModel, "zai"):
 		apiKey = cfg.Providers.Zhipu.APIKey
@@ -220,8 +258,8 @@ func CreateProvider(cfg *config.Config) (LLMProvider, error) {
 		}
 
 	case cfg.Providers.VLLM.APIBase != "":
-		apiKey = cfg.Providers.VLLM.APIKey
-		apiBase = cfg.Providers.VLLM.APIBase
+		// Self-hosted OpenAI-compatible endpoint; API key is optional
+		return NewProvider("vllm", cfg)
 
 	default:
 		if cfg.Providers.OpenRouter.APIKey != "" {
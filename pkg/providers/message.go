@@ -0,0 +1,59 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// NewSystemMessage returns a system message.
+func NewSystemMessage(content string) Message {
+	return Message{Role: "system", Content: content}
+}
+
+// NewUserMessage returns a user message, with any attachments sent inline.
+func NewUserMessage(content string, attachments ...Attachment) Message {
+	return Message{Role: "user", Content: content, Attachments: attachments}
+}
+
+// NewAssistantToolCalls returns the assistant message that requested calls,
+// in the OpenAI shape that every provider converts: each call has an ID,
+// Type "function" and JSON arguments. Calls without an ID are given one in
+// place, so results built from the same slice carry it too. The results
+// must follow as NewToolResult messages with the matching IDs.
+func NewAssistantToolCalls(content string, calls []ToolCall) Message {
+	msg := Message{Role: "assistant", Content: content}
+	for i := range calls {
+		if calls[i].ID == "" {
+			calls[i].ID = fmt.Sprintf("call_%d", i)
+		}
+		tc := calls[i]
+		name, args := tc.Name, tc.Arguments
+		if tc.Function != nil {
+			if name == "" {
+				name = tc.Function.Name
+			}
+			if args == nil && tc.Function.Arguments != "" {
+				_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+			}
+		}
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		argsJSON, _ := json.Marshal(args)
+
+		msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+			ID:   tc.ID,
+			Type: "function",
+			Function: &FunctionCall{
+				Name:      name,
+				Arguments: string(argsJSON),
+			},
+		})
+	}
+	return msg
+}
+
+// NewToolResult returns the result of the tool call with ID callID.
+func NewToolResult(callID, content string) Message {
+	return Message{Role: "tool", Content: content, ToolCallID: callID}
+}
@@ -0,0 +1,26 @@
+package providers
+
+import "testing"
+
+func TestNewAssistantToolCallsAssignsIDs(t *testing.T) {
+	calls := []ToolCall{
+		{Name: "read_file", Arguments: map[string]interface{}{"path": "a"}},
+		{ID: "toolu_1", Name: "exec"},
+	}
+	msg := NewAssistantToolCalls("", calls)
+
+	if calls[0].ID == "" {
+		t.Fatal("missing ID wasn't assigned on the call itself")
+	}
+	for i, tc := range msg.ToolCalls {
+		if tc.ID != calls[i].ID {
+			t.Errorf("message call %d has ID %q, but the call to execute has %q", i, tc.ID, calls[i].ID)
+		}
+	}
+	if calls[1].ID != "toolu_1" {
+		t.Errorf("existing ID changed to %q", calls[1].ID)
+	}
+	if got := msg.ToolCalls[0].Function.Arguments; got != `{"path":"a"}` {
+		t.Errorf("arguments = %s", got)
+	}
+}
@@ -0,0 +1,204 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	RegisterProvider("openai", func(cfg *config.Config) (LLMProvider, error) {
+		return newOpenAIProviderFromConfig(cfg.Providers.OpenAI, "https://api.openai.com/v1")
+	})
+	RegisterProvider("vllm", func(cfg *config.Config) (LLMProvider, error) {
+		return newOpenAIProviderFromConfig(cfg.Providers.VLLM, "")
+	})
+}
+
+func newOpenAIProviderFromConfig(pc config.ProviderConfig, defaultBase string) (LLMProvider, error) {
+	apiBase := pc.APIBase
+	if apiBase == "" {
+		apiBase = defaultBase
+	}
+	if apiBase == "" {
+		return nil, fmt.Errorf("no API base configured for OpenAI-compatible provider")
+	}
+	if pc.Proxy != "" {
+		transport, err := newProxyTransport(pc.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		return NewOpenAIProviderWithClient(pc.APIKey, apiBase, &http.Client{Transport: transport}), nil
+	}
+	return NewOpenAIProvider(pc.APIKey, apiBase), nil
+}
+
+// OpenAIProvider is an HTTPProvider for OpenAI and self-hosted
+// OpenAI-compatible endpoints (vLLM, Ollama, LM Studio, ...), differing only
+// in its default model and error label.
+type OpenAIProvider struct {
+	*HTTPProvider
+}
+
+// NewOpenAIProvider creates an OpenAIProvider. apiBase is the URL prefix in
+// front of /chat/completions, e.g. "http://localhost:11434/v1" for Ollama.
+// apiKey may be empty for self-hosted endpoints.
+func NewOpenAIProvider(apiKey, apiBase string) *OpenAIProvider {
+	return NewOpenAIProviderWithClient(apiKey, apiBase, nil)
+}
+
+// NewOpenAIProviderWithClient creates an OpenAIProvider using the given
+// http.Client. A nil client falls back to the default.
+func NewOpenAIProviderWithClient(apiKey, apiBase string, client *http.Client) *OpenAIProvider {
+	p := NewHTTPProviderWithClient(apiKey, apiBase, client)
+	p.name = "OpenAI"
+	return &OpenAIProvider{HTTPProvider: p}
+}
+
+// openaiMessage is a chat/completions message.
+type openaiMessage struct {
+	Role string `json:"role"`
+	// Content is nil (JSON null), a string, or []openaiContentPart when
+	// the message carries attachments.
+	Content    interface{}      `json:"content"`
+	ToolCalls  []openaiToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openaiContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openaiImageURL `json:"image_url,omitempty"`
+}
+
+type openaiImageURL struct {
+	URL string `json:"url"`
+}
+
+type openaiToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+func (p *OpenAIProvider) GetDefaultModel() string {
+	return "gpt-4o-mini"
+}
+
+// openaiContentParts builds multi-part content: the text followed by each
+// attachment as a base64 data URL.
+func openaiContentParts(text string, attachments []Attachment) []openaiContentPart {
+	parts := []openaiContentPart{{Type: "text", Text: text}}
+	for _, a := range attachments {
+		parts = append(parts, openaiContentPart{
+			Type:     "image_url",
+			ImageURL: &openaiImageURL{URL: "data:" + a.MIMEType + ";base64," + a.Base64()},
+		})
+	}
+	return parts
+}
+
+// convertMessagesToOpenAI converts our Message slice to the chat/completions
+// schema. Tool calls recorded with Name/Arguments are re-encoded into the
+// nested function object with JSON-string arguments.
+func convertMessagesToOpenAI(messages []Message) []openaiMessage {
+	out := make([]openaiMessage, 0, len(messages))
+	for _, msg := range messages {
+		om := openaiMessage{
+			Role:       msg.Role,
+			ToolCallID: msg.ToolCallID,
+		}
+
+		content := msg.Content
+		// Assistant messages that only carry tool calls must send null content.
+		if len(msg.Attachments) > 0 {
+			om.Content = openaiContentParts(content, msg.Attachments)
+		} else if !(msg.Role == "assistant" && content == "" && len(msg.ToolCalls) > 0) {
+			om.Content = content
+		}
+
+		for _, tc := range msg.ToolCalls {
+			otc := openaiToolCall{ID: tc.ID, Type: "function"}
+			if tc.Function != nil {
+				otc.Function.Name = tc.Function.Name
+				otc.Function.Arguments = tc.Function.Arguments
+			}
+			if otc.Function.Name == "" {
+				otc.Function.Name = tc.Name
+			}
+			if otc.Function.Arguments == "" {
+				args := tc.Arguments
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				argsJSON, _ := json.Marshal(args)
+				otc.Function.Arguments = string(argsJSON)
+			}
+			om.ToolCalls = append(om.ToolCalls, otc)
+		}
+
+		out = append(out, om)
+	}
+	return out
+}
+
+func parseOpenAIResponse(body []byte) (*LLMResponse, error) {
+	var apiResponse struct {
+		Choices []struct {
+			Message struct {
+				Content   *string          `json:"content"`
+				ToolCalls []openaiToolCall `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *UsageInfo `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAI response: %w", err)
+	}
+
+	if len(apiResponse.Choices) == 0 {
+		return &LLMResponse{Content: "", FinishReason: "stop", Usage: apiResponse.Usage}, nil
+	}
+
+	choice := apiResponse.Choices[0]
+
+	var content string
+	if choice.Message.Content != nil {
+		content = *choice.Message.Content
+	}
+
+	toolCalls := make([]ToolCall, 0, len(choice.Message.ToolCalls))
+	for _, tc := range choice.Message.ToolCalls {
+		arguments := make(map[string]interface{})
+		if tc.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
+				arguments["raw"] = tc.Function.Arguments
+			}
+		}
+		toolCalls = append(toolCalls, ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: arguments,
+		})
+	}
+
+	finishReason := choice.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	return &LLMResponse{
+		Content:         content,
+		ToolCalls:       toolCalls,
+		FinishReason:    finishReason,
+		RawFinishReason: choice.FinishReason,
+		Usage:           apiResponse.Usage,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIProviderChat(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotAuth, gotBody = r.URL.Path, r.Header.Get("Authorization"), string(body)
+		if strings.Contains(gotBody, "fail") {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":null,"tool_calls":[`+
+			`{"id":"call_2","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"a.txt\"}"}}]},`+
+			`"finish_reason":"tool_calls"}]}`)
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider("sk-test", srv.URL+"/v1/")
+	messages := []Message{
+		{Role: "user", Content: "hi"},
+		NewAssistantToolCalls("", []ToolCall{{ID: "call_1", Name: "list_dir"}}),
+		{Role: "tool", ToolCallID: "call_1", Content: "a.txt"},
+	}
+	resp, err := p.Chat(context.Background(), messages, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/v1/chat/completions" || gotAuth != "Bearer sk-test" {
+		t.Errorf("request to %s with auth %q", gotPath, gotAuth)
+	}
+	if !strings.Contains(gotBody, `"tool_calls":[{"id":"call_1","type":"function","function":{"name":"list_dir","arguments":"{}"}}]`) {
+		t.Errorf("assistant tool call not in chat/completions form: %s", gotBody)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_file" || resp.ToolCalls[0].Arguments["path"] != "a.txt" {
+		t.Errorf("tool calls = %+v", resp.ToolCalls)
+	}
+	if p.GetDefaultModel() != "gpt-4o-mini" {
+		t.Errorf("default model = %q", p.GetDefaultModel())
+	}
+
+	_, err = p.Chat(context.Background(), []Message{{Role: "user", Content: "fail"}}, nil, "gpt-4o", nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "OpenAI API error (500)") {
+		t.Errorf("err = %v, want an OpenAI API error", err)
+	}
+}
@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ProviderFactory builds an LLMProvider from the application config.
+type ProviderFactory func(cfg *config.Config) (LLMProvider, error)
+
+var (
+	factories   = make(map[string]ProviderFactory)
+	factoriesMu sync.RWMutex
+)
+
+// RegisterProvider makes a provider available by name to NewProvider.
+// Registering the same name twice replaces the earlier factory.
+func RegisterProvider(name string, factory ProviderFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[strings.ToLower(name)] = factory
+}
+
+// NewProvider creates the provider registered under name.
+func NewProvider(name string, cfg *config.Config) (LLMProvider, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[strings.ToLower(name)]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (available: %s)", name, strings.Join(RegisteredProviders(), ", "))
+	}
+	return factory(cfg)
+}
+
+// RegisteredProviders returns the sorted names of all registered providers.
+func RegisteredProviders() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
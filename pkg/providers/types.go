@@ -1,6 +1,11 @@
 package providers
 
-import "context"
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
 
 type ToolCall struct {
 	ID        string                 `json:"id"`
@@ -15,11 +20,78 @@ type FunctionCall struct {
 	Arguments string `json:"arguments"`
 }
 
+// LLMResponse is a provider's reply. FinishReason is normalized ("stop",
+// "length", ...); RawFinishReason keeps the provider's original value, e.g.
+// Gemini's "RECITATION".
 type LLMResponse struct {
-	Content      string     `json:"content"`
-	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
-	FinishReason string     `json:"finish_reason"`
-	Usage        *UsageInfo `json:"usage,omitempty"`
+	Content         string      `json:"content"`
+	ToolCalls       []ToolCall  `json:"tool_calls,omitempty"`
+	FinishReason    string      `json:"finish_reason"`
+	RawFinishReason string      `json:"raw_finish_reason,omitempty"`
+	Usage           *UsageInfo  `json:"usage,omitempty"`
+	Candidates      []Candidate `json:"candidates,omitempty"`
+	Grounding       *Grounding  `json:"grounding,omitempty"`
+	// Fallback is set by FallbackProvider to the entry that served the call.
+	Fallback *FallbackUsed `json:"fallback,omitempty"`
+}
+
+// Grounding describes the web search a grounded answer was based on.
+type Grounding struct {
+	SearchQueries []string          `json:"search_queries,omitempty"`
+	Sources       []GroundingSource `json:"sources,omitempty"`
+	// SearchEntryPoint is provider-rendered HTML for the search suggestions,
+	// which Google's terms require to be displayed alongside grounded answers.
+	SearchEntryPoint string `json:"search_entry_point,omitempty"`
+}
+
+// Citations formats the sources as a numbered list to append to an answer.
+// It returns "" for a nil Grounding or one without sources.
+func (g *Grounding) Citations() string {
+	if g == nil || len(g.Sources) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nSources:")
+	for i, src := range g.Sources {
+		title := src.Title
+		if title == "" {
+			title = src.URI
+		}
+		fmt.Fprintf(&sb, "\n%d. %s - %s", i+1, title, src.URI)
+	}
+	return sb.String()
+}
+
+// GroundingSource is a web page cited by a grounded answer.
+type GroundingSource struct {
+	Title string `json:"title"`
+	URI   string `json:"uri"`
+}
+
+// Candidate is one of the completions in LLMResponse.Candidates, filled by
+// providers that support options["candidate_count"]. LLMResponse's top-level
+// fields always mirror the first candidate.
+type Candidate struct {
+	Content         string     `json:"content"`
+	ToolCalls       []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason    string     `json:"finish_reason"`
+	RawFinishReason string     `json:"raw_finish_reason,omitempty"`
+	Grounding       *Grounding `json:"grounding,omitempty"`
+}
+
+// AllCandidates returns r.Candidates, or the top-level completion as the only
+// candidate for providers that don't fill them.
+func (r *LLMResponse) AllCandidates() []Candidate {
+	if len(r.Candidates) > 0 {
+		return r.Candidates
+	}
+	return []Candidate{{
+		Content:         r.Content,
+		ToolCalls:       r.ToolCalls,
+		FinishReason:    r.FinishReason,
+		RawFinishReason: r.RawFinishReason,
+		Grounding:       r.Grounding,
+	}}
 }
 
 type UsageInfo struct {
@@ -33,6 +105,31 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// Attachments are sent inline with a user message. They are not
+	// serialized, so stored history keeps only the text.
+	Attachments []Attachment `json:"-"`
+}
+
+// Attachment is a file sent to the model alongside a message's text, such as
+// an image received on a chat channel.
+type Attachment struct {
+	MIMEType string
+	Data     []byte
+}
+
+// Base64 returns the attachment data base64-encoded, as providers expect it
+// inline.
+func (a Attachment) Base64() string {
+	return base64.StdEncoding.EncodeToString(a.Data)
+}
+
+func hasAttachments(messages []Message) bool {
+	for _, msg := range messages {
+		if len(msg.Attachments) > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 type LLMProvider interface {
@@ -0,0 +1,89 @@
+package providers
+
+import "sync"
+
+// SessionUsage accumulates token usage across multiple Chat calls. It is safe
+// for concurrent use.
+type SessionUsage struct {
+	mu               sync.Mutex
+	promptTokens     int
+	completionTokens int
+	totalTokens      int
+	calls            int
+}
+
+func NewSessionUsage() *SessionUsage {
+	return &SessionUsage{}
+}
+
+// Add records the usage of a single call. A nil usage still counts as a call
+// so that providers that omit usage metadata are reflected in Calls.
+func (u *SessionUsage) Add(usage *UsageInfo) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.calls++
+	if usage == nil {
+		return
+	}
+	u.promptTokens += usage.PromptTokens
+	u.completionTokens += usage.CompletionTokens
+	if usage.TotalTokens > 0 {
+		u.totalTokens += usage.TotalTokens
+	} else {
+		u.totalTokens += usage.PromptTokens + usage.CompletionTokens
+	}
+}
+
+// Totals returns the accumulated usage as a UsageInfo.
+func (u *SessionUsage) Totals() UsageInfo {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return UsageInfo{
+		PromptTokens:     u.promptTokens,
+		CompletionTokens: u.completionTokens,
+		TotalTokens:      u.totalTokens,
+	}
+}
+
+// Calls returns the number of calls recorded.
+func (u *SessionUsage) Calls() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.calls
+}
+
+// ExceedsBudget reports whether the accumulated total tokens have reached
+// the given limit. A limit <= 0 means unlimited.
+func (u *SessionUsage) ExceedsBudget(limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.totalTokens >= limit
+}
+
+// Remaining returns how many tokens are left before reaching limit, or -1
+// when limit <= 0 (unlimited).
+func (u *SessionUsage) Remaining(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.totalTokens >= limit {
+		return 0
+	}
+	return limit - u.totalTokens
+}
+
+// Reset clears all accumulated totals.
+func (u *SessionUsage) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.promptTokens = 0
+	u.completionTokens = 0
+	u.totalTokens = 0
+	u.calls = 0
+}
@@ -0,0 +1,190 @@
+package session
+
+import (
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// HistoryWindow bounds the conversation history sent to a provider. Zero
+// values disable the corresponding limit.
+type HistoryWindow struct {
+	// MaxTurns is the number of most recent turns kept. A turn starts at a
+	// user message and runs up to the next one, including any assistant
+	// tool calls and tool results in between.
+	MaxTurns int
+	// MaxTokens is an approximate token budget for the kept turns.
+	MaxTokens int
+}
+
+// History appends messages to sessions and returns them trimmed to a
+// window, so callers don't each reimplement history management.
+type History struct {
+	sessions *SessionManager
+	window   HistoryWindow
+}
+
+func NewHistory(sessions *SessionManager, window HistoryWindow) *History {
+	return &History{sessions: sessions, window: window}
+}
+
+// Append adds messages to the session's stored history.
+func (h *History) Append(sessionKey string, msgs ...providers.Message) {
+	h.sessions.AppendMessages(sessionKey, msgs...)
+}
+
+// Messages returns the session's history trimmed to the window.
+func (h *History) Messages(sessionKey string) []providers.Message {
+	return TrimHistory(h.sessions.GetHistory(sessionKey), h.window)
+}
+
+// KeepTurns drops all but the last n turns of the session's stored history.
+func (h *History) KeepTurns(sessionKey string, n int) {
+	h.sessions.KeepTurns(sessionKey, n)
+}
+
+// AppendMessages adds full messages (including tool calls and results) to a
+// session.
+func (sm *SessionManager) AppendMessages(sessionKey string, msgs ...providers.Message) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionKey]
+	if !ok {
+		session = &Session{
+			Key:      sessionKey,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[sessionKey] = session
+	}
+
+	session.Messages = append(session.Messages, msgs...)
+	session.Updated = time.Now()
+}
+
+// KeepTurns drops all but the last n turns of a session's messages.
+func (sm *SessionManager) KeepTurns(sessionKey string, n int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[sessionKey]
+	if !ok {
+		return
+	}
+
+	turns := splitTurns(session.Messages)
+	if len(turns) <= n {
+		return
+	}
+	kept := []providers.Message{}
+	for _, t := range turns[len(turns)-n:] {
+		kept = append(kept, t...)
+	}
+	session.Messages = kept
+	session.Updated = time.Now()
+}
+
+// TrimHistory drops the oldest turns of messages until they fit w. Leading
+// system messages are always kept, whole turns are dropped so a tool call is
+// never separated from its result, and the latest turn is always kept.
+func TrimHistory(messages []providers.Message, w HistoryWindow) []providers.Message {
+	var system []providers.Message
+	rest := messages
+	for len(rest) > 0 && rest[0].Role == "system" {
+		system = append(system, rest[0])
+		rest = rest[1:]
+	}
+
+	// A history trimmed elsewhere may start mid-turn; orphaned tool results
+	// are rejected by providers, so drop them.
+	for len(rest) > 0 && rest[0].Role == "tool" {
+		rest = rest[1:]
+	}
+
+	turns := splitTurns(rest)
+	if w.MaxTurns > 0 && len(turns) > w.MaxTurns {
+		turns = turns[len(turns)-w.MaxTurns:]
+	}
+	if w.MaxTokens > 0 {
+		budget := w.MaxTokens - EstimateTokens(system)
+		total := 0
+		for _, t := range turns {
+			total += EstimateTokens(t)
+		}
+		for len(turns) > 1 && total > budget {
+			total -= EstimateTokens(turns[0])
+			turns = turns[1:]
+		}
+	}
+
+	out := make([]providers.Message, 0, len(messages))
+	out = append(out, system...)
+	for _, t := range turns {
+		out = append(out, t...)
+	}
+	return out
+}
+
+// CountTurns returns the number of turns in messages after any leading
+// system messages.
+func CountTurns(messages []providers.Message) int {
+	for len(messages) > 0 && messages[0].Role == "system" {
+		messages = messages[1:]
+	}
+	return len(splitTurns(messages))
+}
+
+// splitTurns groups messages into turns, each starting at a user message.
+func splitTurns(messages []providers.Message) [][]providers.Message {
+	var turns [][]providers.Message
+	for _, m := range messages {
+		if m.Role == "user" || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], m)
+	}
+	return turns
+}
+
+// EstimateTokens roughly estimates the token count of messages at four
+// characters per token plus a small per-message overhead.
+func EstimateTokens(messages []providers.Message) int {
+	chars := 0
+	for _, m := range messages {
+		chars += len(m.Content) + 16
+		for _, tc := range m.ToolCalls {
+			chars += len(tc.Name)
+			if tc.Function != nil {
+				chars += len(tc.Function.Name) + len(tc.Function.Arguments)
+			}
+		}
+	}
+	return chars / 4
+}
+
+// ShrinkHistory drops the older half of the turns in messages, for retrying a
+// request that overflowed the model's context window. Leading system
+// messages and the latest turn are kept. It reports false if there was
+// nothing left to drop.
+func ShrinkHistory(messages []providers.Message) ([]providers.Message, bool) {
+	var system []providers.Message
+	rest := messages
+	for len(rest) > 0 && rest[0].Role == "system" {
+		system = append(system, rest[0])
+		rest = rest[1:]
+	}
+
+	turns := splitTurns(rest)
+	if len(turns) <= 1 {
+		return messages, false
+	}
+	turns = turns[len(turns)/2:]
+
+	out := make([]providers.Message, 0, len(messages))
+	out = append(out, system...)
+	for _, t := range turns {
+		out = append(out, t...)
+	}
+	return out, true
+}
@@ -9,6 +9,18 @@ type Tool interface {
 	Execute(ctx context.Context, args map[string]interface{}) (string, error)
 }
 
+var (
+	_ Tool = (*ExecTool)(nil)
+	_ Tool = (*ReadFileTool)(nil)
+	_ Tool = (*WriteFileTool)(nil)
+	_ Tool = (*ListDirTool)(nil)
+	_ Tool = (*EditFileTool)(nil)
+	_ Tool = (*WebFetchTool)(nil)
+	_ Tool = (*GrepTool)(nil)
+	_ Tool = (*RememberTool)(nil)
+	_ Tool = (*RecallTool)(nil)
+)
+
 func ToolToSchema(tool Tool) map[string]interface{} {
 	return map[string]interface{}{
 		"type": "function",
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// SerialTool is implemented by tools that mutate shared state (files, the
+// shell, outgoing messages). ExecuteToolCalls never runs a serial tool
+// concurrently with any other call.
+type SerialTool interface {
+	Serial() bool
+}
+
+// TimeoutTool is implemented by tools that need a deadline other than the
+// registry default (see ToolRegistry.SetTimeout). A zero Timeout means the
+// registry adds none, for tools that enforce their own (exec) or outlive the
+// call (spawn).
+type TimeoutTool interface {
+	Timeout() time.Duration
+}
+
+// ToolCallResult is the outcome of one call dispatched by ExecuteToolCalls.
+type ToolCallResult struct {
+	ID      string
+	Name    string
+	Content string
+	Err     error
+}
+
+// ExecuteToolCalls runs calls with at most maxConcurrency running at once
+// (<= 1 runs them one by one) and returns one result per call, in call order.
+// Serial tools act as barriers: earlier calls finish before they start and
+// later calls wait for them. A failing or panicking call only affects its own
+// result.
+func (r *ToolRegistry) ExecuteToolCalls(ctx context.Context, calls []providers.ToolCall, maxConcurrency int) []ToolCallResult {
+	results := make([]ToolCallResult, len(calls))
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i, tc := range calls {
+		if r.isSerial(tc.Name) {
+			wg.Wait()
+			results[i] = r.executeCall(ctx, tc)
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, tc providers.ToolCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = r.executeCall(ctx, tc)
+		}(i, tc)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (r *ToolRegistry) isSerial(name string) bool {
+	tool, ok := r.Get(name)
+	if !ok {
+		return false
+	}
+	st, ok := tool.(SerialTool)
+	return ok && st.Serial()
+}
+
+func (r *ToolRegistry) executeCall(ctx context.Context, tc providers.ToolCall) (res ToolCallResult) {
+	res = ToolCallResult{ID: tc.ID, Name: tc.Name}
+	defer func() {
+		if p := recover(); p != nil {
+			logger.ErrorCF("tool", "Tool panicked", map[string]interface{}{
+				"tool":  tc.Name,
+				"panic": fmt.Sprint(p),
+			})
+			res.Err = fmt.Errorf("tool '%s' panicked: %v", tc.Name, p)
+		}
+	}()
+
+	res.Content, res.Err = r.Execute(ctx, tc.Name, tc.Arguments)
+	return res
+}
@@ -24,6 +24,11 @@ func (t *EditFileTool) Name() string {
 	return "edit_file"
 }
 
+// Serial implements SerialTool.
+func (t *EditFileTool) Serial() bool {
+	return true
+}
+
 func (t *EditFileTool) Description() string {
 	return "Edit a file by replacing old_text with new_text. The old_text must exist exactly in the file."
 }
@@ -66,7 +71,7 @@ func (t *EditFileTool) Execute(ctx context.Context, args map[string]interface{})
 	}
 
 	// Resolve path and enforce directory restriction if configured
-	resolvedPath, err := ValidatePath(path, t.allowedDir)
+	resolvedPath, err := ValidatePathResolved(path, t.allowedDir)
 	if err != nil {
 		return "", err
 	}
@@ -112,6 +117,11 @@ func (t *AppendFileTool) Name() string {
 	return "append_file"
 }
 
+// Serial implements SerialTool.
+func (t *AppendFileTool) Serial() bool {
+	return true
+}
+
 func (t *AppendFileTool) Description() string {
 	return "Append content to the end of a file"
 }
@@ -144,7 +154,7 @@ func (t *AppendFileTool) Execute(ctx context.Context, args map[string]interface{
 		return "", fmt.Errorf("content is required")
 	}
 
-	filePath, err := ValidatePath(path, t.allowedDir)
+	filePath, err := ValidatePathResolved(path, t.allowedDir)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EditLinesTool replaces a range of lines in a file. Unlike write_file it
+// leaves the rest of the file untouched, and an optional expected hash guards
+// against edits based on a stale read.
+type EditLinesTool struct {
+	allowedDir string
+}
+
+func NewEditLinesTool(allowedDir string) *EditLinesTool {
+	return &EditLinesTool{allowedDir: allowedDir}
+}
+
+func (t *EditLinesTool) Name() string {
+	return "edit_lines"
+}
+
+// Serial implements SerialTool.
+func (t *EditLinesTool) Serial() bool {
+	return true
+}
+
+func (t *EditLinesTool) Description() string {
+	return "Replace lines start_line..end_line (1-based, inclusive) of a file with new content and return the diff. " +
+		"Set end_line to start_line-1 to insert before start_line without removing anything."
+}
+
+func (t *EditLinesTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Path to the file to edit",
+			},
+			"start_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "First line to replace, 1-based",
+			},
+			"end_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "Last line to replace, inclusive",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "Replacement text for the range (may be empty to delete it)",
+			},
+			"expected_sha256": map[string]interface{}{
+				"type":        "string",
+				"description": "SHA-256 of the file as last seen; the edit is refused if the file has changed since (optional)",
+			},
+		},
+		"required": []string{"path", "start_line", "end_line", "content"},
+	}
+}
+
+func (t *EditLinesTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", fmt.Errorf("path is required")
+	}
+	startF, ok := args["start_line"].(float64)
+	if !ok {
+		return "", fmt.Errorf("start_line is required")
+	}
+	endF, ok := args["end_line"].(float64)
+	if !ok {
+		return "", fmt.Errorf("end_line is required")
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return "", fmt.Errorf("content is required")
+	}
+	expected, _ := args["expected_sha256"].(string)
+
+	absPath, err := ValidatePathResolved(path, t.allowedDir)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if expected != "" && !strings.EqualFold(expected, sha256Hex(data)) {
+		return "", fmt.Errorf("%s has changed since it was read (sha256 mismatch); read it again before editing", path)
+	}
+
+	lines := splitLines(string(data))
+	start, end := int(startF), int(endF)
+	if start < 1 || start > len(lines)+1 || end < start-1 || end > len(lines) {
+		return "", fmt.Errorf("invalid line range %d-%d for a file with %d lines", start, end, len(lines))
+	}
+
+	// Keep the file's line structure: replacement text gets a trailing newline
+	// unless it becomes the file's last line and the original had none.
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		if end < len(lines) || (len(lines) > 0 && strings.HasSuffix(lines[len(lines)-1], "\n")) {
+			content += "\n"
+		}
+	}
+	// Appending after a last line without a newline would join the two.
+	if start == len(lines)+1 && len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+
+	removed := lines[start-1 : end]
+	added := splitLines(content)
+
+	var sb strings.Builder
+	for _, l := range lines[:start-1] {
+		sb.WriteString(l)
+	}
+	sb.WriteString(content)
+	for _, l := range lines[end:] {
+		sb.WriteString(l)
+	}
+	newData := []byte(sb.String())
+
+	if err := writeFileAtomic(absPath, newData); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return fmt.Sprintf("%s\nsha256: %s", lineDiff(path, start, removed, added), sha256Hex(newData)), nil
+}
+
+// splitLines splits s into lines, each keeping its trailing newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineDiff renders a single-hunk unified diff of a line replacement.
+func lineDiff(path string, start int, removed, added []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", path, path)
+	fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", start, len(removed), start, len(added))
+	for _, l := range removed {
+		sb.WriteString("-" + strings.TrimSuffix(l, "\n") + "\n")
+	}
+	for _, l := range added {
+		sb.WriteString("+" + strings.TrimSuffix(l, "\n") + "\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
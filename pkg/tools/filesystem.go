@@ -1,18 +1,32 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// defaultMaxReadSize caps how much read_file returns in one call.
+const defaultMaxReadSize = 1 << 20
+
 type ReadFileTool struct {
 	allowedDir string
+	maxSize    int64
 }
 
 func NewReadFileTool(allowedDir string) *ReadFileTool {
-	return &ReadFileTool{allowedDir: allowedDir}
+	return &ReadFileTool{allowedDir: allowedDir, maxSize: defaultMaxReadSize}
+}
+
+// SetMaxSize sets the maximum number of bytes returned by a read (default
+// 1 MiB). Larger files must be read in parts with start_line/end_line.
+func (t *ReadFileTool) SetMaxSize(n int64) {
+	t.maxSize = n
 }
 
 func (t *ReadFileTool) Name() string {
@@ -20,7 +34,7 @@ func (t *ReadFileTool) Name() string {
 }
 
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file"
+	return "Read the contents of a text file, optionally limited to a range of lines"
 }
 
 func (t *ReadFileTool) Parameters() map[string]interface{} {
@@ -31,6 +45,14 @@ func (t *ReadFileTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Path to the file to read",
 			},
+			"start_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "First line to read, 1-based (optional)",
+			},
+			"end_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "Last line to read, inclusive (optional)",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -42,17 +64,93 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]interface{})
 		return "", fmt.Errorf("path is required")
 	}
 
-	absPath, err := ValidatePath(path, t.allowedDir)
+	startLine, endLine := 0, 0
+	if v, ok := args["start_line"].(float64); ok {
+		startLine = int(v)
+	}
+	if v, ok := args["end_line"].(float64); ok {
+		endLine = int(v)
+	}
+	if startLine < 0 || endLine < 0 || (endLine > 0 && startLine > endLine) {
+		return "", fmt.Errorf("invalid line range %d-%d", startLine, endLine)
+	}
+	ranged := startLine > 0 || endLine > 0
+
+	absPath, err := ValidatePathResolved(path, t.allowedDir)
 	if err != nil {
 		return "", err
 	}
 
-	content, err := os.ReadFile(absPath)
+	info, err := os.Stat(absPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if !ranged && t.maxSize > 0 && info.Size() > t.maxSize {
+		return "", fmt.Errorf("file too large (%d bytes, limit %d); use start_line/end_line to read part of it", info.Size(), t.maxSize)
+	}
 
-	return string(content), nil
+	f, err := os.Open(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	if isBinaryFile(f) {
+		return "", fmt.Errorf("%s appears to be a binary file", path)
+	}
+
+	if !ranged {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		return string(content), nil
+	}
+
+	return readLineRange(f, startLine, endLine, t.maxSize)
+}
+
+// isBinaryFile sniffs the start of f for NUL bytes and rewinds it.
+func isBinaryFile(f *os.File) bool {
+	buf := make([]byte, 8000)
+	n, _ := f.Read(buf)
+	f.Seek(0, io.SeekStart)
+	return bytes.IndexByte(buf[:n], 0) >= 0
+}
+
+// readLineRange returns lines start..end (1-based, inclusive; 0 means from
+// the beginning / to the end) of r, stopping at maxSize bytes.
+func readLineRange(r io.Reader, start, end int, maxSize int64) (string, error) {
+	if start < 1 {
+		start = 1
+	}
+
+	reader := bufio.NewReader(r)
+	var sb strings.Builder
+	for lineNo := 1; end == 0 || lineNo <= end; lineNo++ {
+		line, err := reader.ReadString('\n')
+		if lineNo >= start {
+			if maxSize > 0 && int64(sb.Len()+len(line)) > maxSize {
+				sb.WriteString(fmt.Sprintf("\n... (output truncated at line %d, limit %d bytes)", lineNo, maxSize))
+				break
+			}
+			sb.WriteString(line)
+		}
+		if err == io.EOF {
+			if lineNo < start {
+				return "", fmt.Errorf("start_line %d is past the end of the file (%d lines)", start, lineNo)
+			}
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	return sb.String(), nil
 }
 
 type WriteFileTool struct {
@@ -67,8 +165,13 @@ func (t *WriteFileTool) Name() string {
 	return "write_file"
 }
 
+// Serial implements SerialTool.
+func (t *WriteFileTool) Serial() bool {
+	return true
+}
+
 func (t *WriteFileTool) Description() string {
-	return "Write content to a file"
+	return "Write content to a file, replacing it atomically or appending to it"
 }
 
 func (t *WriteFileTool) Parameters() map[string]interface{} {
@@ -83,6 +186,10 @@ func (t *WriteFileTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Content to write to the file",
 			},
+			"append": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Append to the file instead of replacing it (default false)",
+			},
 		},
 		"required": []string{"path", "content"},
 	}
@@ -99,7 +206,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}
 		return "", fmt.Errorf("content is required")
 	}
 
-	absPath, err := ValidatePath(path, t.allowedDir)
+	absPath, err := ValidatePathResolved(path, t.allowedDir)
 	if err != nil {
 		return "", err
 	}
@@ -107,7 +214,7 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}
 	// Validate that the parent directory is also within the workspace before
 	// creating intermediate directories.
 	dir := filepath.Dir(absPath)
-	if _, err := ValidatePath(dir, t.allowedDir); err != nil {
+	if _, err := ValidatePathResolved(dir, t.allowedDir); err != nil {
 		return "", err
 	}
 
@@ -115,27 +222,88 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]interface{}
 		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
+	if appendMode, _ := args["append"].(bool); appendMode {
+		f, err := os.OpenFile(absPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to open file: %w", err)
+		}
+		if _, err := f.WriteString(content); err != nil {
+			f.Close()
+			return "", fmt.Errorf("failed to append to file: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return "", fmt.Errorf("failed to append to file: %w", err)
+		}
+		return "Content appended successfully", nil
+	}
+
+	if err := writeFileAtomic(absPath, []byte(content)); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return "File written successfully", nil
 }
 
+// writeFileAtomic writes data to a temp file in the same directory and
+// renames it over path, so readers never see a partially written file. An
+// existing file's permissions are kept.
+func writeFileAtomic(path string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+const (
+	defaultListDirDepth = 3
+	maxListDirDepth     = 10
+	maxListDirEntries   = 1000
+)
+
 type ListDirTool struct {
-	allowedDir string
+	allowedDir     string
+	ignorePatterns []string
 }
 
 func NewListDirTool(allowedDir string) *ListDirTool {
 	return &ListDirTool{allowedDir: allowedDir}
 }
 
+// SetIgnorePatterns sets extra gitignore-style patterns to hide, on top of
+// any .gitignore files found in the listed tree.
+func (t *ListDirTool) SetIgnorePatterns(patterns []string) {
+	t.ignorePatterns = patterns
+}
+
 func (t *ListDirTool) Name() string {
 	return "list_dir"
 }
 
 func (t *ListDirTool) Description() string {
-	return "List files and directories in a path"
+	return "List files and directories in a path with size and modification time, optionally recursively"
 }
 
 func (t *ListDirTool) Parameters() map[string]interface{} {
@@ -146,6 +314,22 @@ func (t *ListDirTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Path to list",
 			},
+			"recursive": map[string]interface{}{
+				"type":        "boolean",
+				"description": "List subdirectories too (default false)",
+			},
+			"max_depth": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum depth when recursive (default 3, max 10)",
+			},
+			"show_hidden": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Include entries starting with '.' (default false)",
+			},
+			"no_ignore": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Include entries excluded by .gitignore (default false)",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -157,24 +341,96 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]interface{})
 		path = "."
 	}
 
-	absPath, err := ValidatePath(path, t.allowedDir)
+	depth := 1
+	if recursive, _ := args["recursive"].(bool); recursive {
+		depth = defaultListDirDepth
+		if d, ok := args["max_depth"].(float64); ok && d >= 1 {
+			depth = int(d)
+		}
+		if depth > maxListDirDepth {
+			depth = maxListDirDepth
+		}
+	}
+	showHidden, _ := args["show_hidden"].(bool)
+
+	absPath, err := ValidatePathResolved(path, t.allowedDir)
 	if err != nil {
 		return "", err
 	}
 
-	entries, err := os.ReadDir(absPath)
+	var ignore *IgnoreMatcher
+	if noIgnore, _ := args["no_ignore"].(bool); !noIgnore {
+		ignore = NewIgnoreMatcher(absPath, t.ignorePatterns)
+	}
+
+	var sb strings.Builder
+	count := 0
+	if err := listDir(ctx, absPath, "", depth, showHidden, ignore, &sb, &count); err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "(empty directory)", nil
+	}
+	if count >= maxListDirEntries {
+		sb.WriteString(fmt.Sprintf("... (stopped after %d entries)\n", maxListDirEntries))
+	}
+
+	return sb.String(), nil
+}
+
+// listDir writes one line per entry of dir to sb, sorted by name, descending
+// into subdirectories while depth > 1. prefix is the path relative to the
+// listing root. Entries matched by ignore (if non-nil) are left out.
+func listDir(ctx context.Context, dir, prefix string, depth int, showHidden bool, ignore *IgnoreMatcher, sb *strings.Builder, count *int) error {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return "", fmt.Errorf("failed to read directory: %w", err)
+		if prefix != "" {
+			// Unreadable subdirectory: note it and carry on.
+			sb.WriteString(fmt.Sprintf("ERR:  %s (%v)\n", prefix, err))
+			return nil
+		}
+		return fmt.Errorf("failed to read directory: %w", err)
 	}
+	// os.ReadDir already sorts by filename.
 
-	result := ""
 	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if *count >= maxListDirEntries {
+			return nil
+		}
+		name := entry.Name()
+		if !showHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ignore != nil && ignore.Ignored(filepath.Join(dir, name), entry.IsDir()) {
+			continue
+		}
+		rel := name
+		if prefix != "" {
+			rel = prefix + "/" + name
+		}
+
+		var size int64
+		var modTime string
+		if info, err := entry.Info(); err == nil {
+			size = info.Size()
+			modTime = info.ModTime().Format("2006-01-02 15:04")
+		}
+
+		*count++
 		if entry.IsDir() {
-			result += "DIR:  " + entry.Name() + "\n"
+			sb.WriteString(fmt.Sprintf("DIR:  %s/  %s\n", rel, modTime))
+			if depth > 1 {
+				if err := listDir(ctx, filepath.Join(dir, name), rel, depth-1, showHidden, ignore, sb, count); err != nil {
+					return err
+				}
+			}
 		} else {
-			result += "FILE: " + entry.Name() + "\n"
+			sb.WriteString(fmt.Sprintf("FILE: %s  %d bytes  %s\n", rel, size, modTime))
 		}
 	}
 
-	return result, nil
+	return nil
 }
@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultGrepMaxResults = 100
+	maxGrepResults        = 1000
+	maxGrepFileSize       = 5 << 20
+	maxGrepLineLen        = 300
+)
+
+// defaultGrepIgnoreDirs are skipped while walking.
+var defaultGrepIgnoreDirs = []string{".git", "node_modules", ".hg", ".svn", "vendor", "__pycache__", ".venv"}
+
+// GrepTool searches file contents under a directory for a regular
+// expression, returning file:line: text matches.
+type GrepTool struct {
+	allowedDir     string
+	ignoreDirs     map[string]bool
+	ignorePatterns []string
+}
+
+func NewGrepTool(allowedDir string) *GrepTool {
+	t := &GrepTool{allowedDir: allowedDir}
+	t.SetIgnoreDirs(defaultGrepIgnoreDirs)
+	return t
+}
+
+// SetIgnoreDirs replaces the directory names skipped during the walk.
+func (t *GrepTool) SetIgnoreDirs(names []string) {
+	t.ignoreDirs = make(map[string]bool, len(names))
+	for _, n := range names {
+		t.ignoreDirs[n] = true
+	}
+}
+
+// SetIgnorePatterns sets extra gitignore-style patterns to skip, on top of
+// any .gitignore files found during the walk.
+func (t *GrepTool) SetIgnorePatterns(patterns []string) {
+	t.ignorePatterns = patterns
+}
+
+func (t *GrepTool) Name() string {
+	return "grep"
+}
+
+func (t *GrepTool) Description() string {
+	return "Search file contents for a regular expression. Returns matches as path:line: text. Skips .git, node_modules, binary files and anything excluded by .gitignore."
+}
+
+func (t *GrepTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"pattern": map[string]interface{}{
+				"type":        "string",
+				"description": "Regular expression (RE2 syntax) to search for",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "File or directory to search (default: workspace)",
+			},
+			"include": map[string]interface{}{
+				"type":        "string",
+				"description": "Only search files whose name matches this glob, e.g. \"*.go\"",
+			},
+			"case_insensitive": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Ignore case when matching",
+			},
+			"fixed_strings": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Treat pattern as a literal string, not a regex",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of matches to return (default 100)",
+			},
+			"no_ignore": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Also search files excluded by .gitignore (default false)",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GrepTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return "", fmt.Errorf("pattern is required")
+	}
+
+	if fixed, _ := args["fixed_strings"].(bool); fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ci, _ := args["case_insensitive"].(bool); ci {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	include, _ := args["include"].(string)
+	if include != "" {
+		if _, err := filepath.Match(include, ""); err != nil {
+			return "", fmt.Errorf("invalid include glob: %w", err)
+		}
+	}
+
+	maxResults := defaultGrepMaxResults
+	if mr, ok := args["max_results"].(float64); ok && mr > 0 {
+		maxResults = int(mr)
+	}
+	if maxResults > maxGrepResults {
+		maxResults = maxGrepResults
+	}
+
+	path, _ := args["path"].(string)
+	if t.allowedDir != "" && !filepath.IsAbs(path) {
+		// Relative paths are relative to the workspace, not the process.
+		path = filepath.Join(t.allowedDir, path)
+	} else if path == "" {
+		path = "."
+	}
+	root, err := ValidatePathResolved(path, t.allowedDir)
+	if err != nil {
+		return "", err
+	}
+
+	var ignore *IgnoreMatcher
+	if noIgnore, _ := args["no_ignore"].(bool); !noIgnore {
+		ignore = NewIgnoreMatcher(root, t.ignorePatterns)
+	}
+
+	var matches []string
+	truncated := false
+	walkErr := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped rather than failing the search.
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if p != root && (t.ignoreDirs[d.Name()] || (ignore != nil && ignore.Ignored(p, true))) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if ignore != nil && ignore.Ignored(p, false) {
+			return nil
+		}
+		if include != "" {
+			if ok, _ := filepath.Match(include, d.Name()); !ok {
+				return nil
+			}
+		}
+
+		display := p
+		if rel, err := filepath.Rel(root, p); err == nil && rel != "." {
+			display = rel
+		}
+
+		var stop bool
+		matches, stop = grepFile(p, display, re, matches, maxResults)
+		if stop {
+			truncated = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return "", fmt.Errorf("search failed: %w", walkErr)
+	}
+
+	if len(matches) == 0 {
+		return "No matches found", nil
+	}
+
+	result := strings.Join(matches, "\n")
+	if truncated {
+		result += fmt.Sprintf("\n... (stopped after %d matches; narrow the pattern or path)", maxResults)
+	}
+	return result, nil
+}
+
+// grepFile appends "display:line: text" for each match in the file at path.
+// Binary and oversized files are skipped. stop is true once maxResults is
+// exceeded.
+func grepFile(path, display string, re *regexp.Regexp, matches []string, maxResults int) ([]string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxGrepFileSize {
+		return matches, false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return matches, false
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if head, _ := reader.Peek(8000); bytes.IndexByte(head, 0) >= 0 {
+		return matches, false
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxGrepFileSize)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if !re.MatchString(line) {
+			continue
+		}
+		if len(matches) >= maxResults {
+			return matches, true
+		}
+		if len(line) > maxGrepLineLen {
+			line = line[:maxGrepLineLen] + "..."
+		}
+		matches = append(matches, fmt.Sprintf("%s:%d: %s", display, lineNo, line))
+	}
+	return matches, false
+}
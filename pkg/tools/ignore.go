@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreMatcher decides which entries a walking tool should skip, using the
+// .gitignore files found along the walk plus operator-supplied patterns in
+// the same syntax. Supported: comments, "!" negation, trailing "/" for
+// directories only, leading or inner "/" anchoring, and "*", "?", "[...]"
+// and "**" globs. Deeper .gitignore files take precedence over shallower
+// ones, and within a file the last matching pattern wins.
+//
+// A matcher caches the .gitignore files it reads and is meant to be used for
+// a single walk; it is not safe for concurrent use.
+type IgnoreMatcher struct {
+	root  string
+	extra []ignoreRule
+	dirs  map[string][]ignoreRule
+}
+
+type ignoreRule struct {
+	re       *regexp.Regexp
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// NewIgnoreMatcher creates a matcher for a walk rooted at root. extra
+// patterns are applied as if they were in a .gitignore at root, below any
+// rules from the .gitignore files themselves.
+func NewIgnoreMatcher(root string, extra []string) *IgnoreMatcher {
+	return &IgnoreMatcher{
+		root:  root,
+		extra: parseIgnoreRules(extra),
+		dirs:  make(map[string][]ignoreRule),
+	}
+}
+
+// Ignored reports whether p, a path under the matcher's root, should be
+// skipped. Callers are expected to prune ignored directories rather than
+// descend into them, as git does.
+func (m *IgnoreMatcher) Ignored(p string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	ignored := false
+	apply := func(rules []ignoreRule, relPath string) {
+		for _, r := range rules {
+			if r.matches(relPath, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	apply(m.extra, rel)
+
+	// Walk the .gitignore files from the root down to p's parent, each
+	// matching against the path relative to its own directory.
+	dir := ""
+	parts := strings.Split(rel, "/")
+	for i := 0; i < len(parts); i++ {
+		apply(m.load(dir), strings.Join(parts[i:], "/"))
+		dir = path.Join(dir, parts[i])
+	}
+
+	return ignored
+}
+
+// load returns the rules of the .gitignore in dir (relative to the root,
+// slash-separated), reading it on first use.
+func (m *IgnoreMatcher) load(dir string) []ignoreRule {
+	if rules, ok := m.dirs[dir]; ok {
+		return rules
+	}
+
+	var rules []ignoreRule
+	if f, err := os.Open(filepath.Join(m.root, filepath.FromSlash(dir), ".gitignore")); err == nil {
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.Close()
+		rules = parseIgnoreRules(lines)
+	}
+	m.dirs[dir] = rules
+	return rules
+}
+
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	return r.re.MatchString(rel)
+}
+
+func parseIgnoreRules(lines []string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+		if err != nil {
+			continue
+		}
+		r.re = re
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// globToRegexp translates a gitignore glob into a regular expression.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
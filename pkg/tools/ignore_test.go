@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "# build output\n*.log\n/dist\nbuild/\n!keep.log\n")
+	write("sub/.gitignore", "secret.txt\n!debug.log\ndocs/**/*.tmp\n")
+
+	m := NewIgnoreMatcher(root, []string{"*.bak"})
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"app.log", false, true},
+		{"keep.log", false, false},
+		{"sub/app.log", false, true},
+		{"sub/debug.log", false, false},
+		{"dist", true, true},
+		{"sub/dist", true, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"sub/secret.txt", false, true},
+		{"secret.txt", false, false},
+		{"sub/docs/a/b/x.tmp", false, true},
+		{"sub/docs/x.tmp", false, true},
+		{"notes.bak", false, true},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		got := m.Ignored(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir)
+		if got != tt.ignored {
+			t.Errorf("Ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+}
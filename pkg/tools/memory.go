@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+// RememberTool lets the model commit a single fact to MemDB on demand, e.g.
+// when the user says "remember that my timezone is UTC+3".
+type RememberTool struct {
+	client *memory.MemDBClient
+	userID string
+	mu     sync.RWMutex
+}
+
+func NewRememberTool(client *memory.MemDBClient) *RememberTool {
+	return &RememberTool{client: client}
+}
+
+func (t *RememberTool) Name() string {
+	return "remember"
+}
+
+func (t *RememberTool) Description() string {
+	return "Store a fact in long-term memory so it can be recalled in future conversations. Use it when the user asks you to remember something or shares a lasting preference. Write the fact as a short, self-contained sentence."
+}
+
+func (t *RememberTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "The fact to remember, e.g. \"The user's timezone is UTC+3\"",
+			},
+		},
+		"required": []string{"content"},
+	}
+}
+
+// SetUserID sets the MemDB user memories are stored for; empty means the
+// client's configured user.
+func (t *RememberTool) SetUserID(userID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.userID = userID
+}
+
+func (t *RememberTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	content, _ := args["content"].(string)
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", fmt.Errorf("content is required")
+	}
+
+	t.mu.RLock()
+	userID := t.userID
+	t.mu.RUnlock()
+
+	err := t.client.AddMessages(ctx, userID, []map[string]string{
+		{"role": "user", "content": content},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store memory: %w", err)
+	}
+
+	return fmt.Sprintf("Remembered: %s", content), nil
+}
+
+// RecallTool lets the model search long-term memory mid-turn, beyond the
+// memories injected into the prompt before each turn. Result count and score
+// threshold follow the MemDB client's configuration.
+type RecallTool struct {
+	client *memory.MemDBClient
+	userID string
+	mu     sync.RWMutex
+}
+
+func NewRecallTool(client *memory.MemDBClient) *RecallTool {
+	return &RecallTool{client: client}
+}
+
+func (t *RecallTool) Name() string {
+	return "recall"
+}
+
+func (t *RecallTool) Description() string {
+	return "Search long-term memory for facts, preferences and past experience relevant to a query. Use it when you need information about the user or earlier conversations that isn't in the current context."
+}
+
+func (t *RecallTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "What to look up, e.g. \"user's timezone\"",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+// SetUserID sets the MemDB user whose memories are searched; empty means the
+// client's configured user.
+func (t *RecallTool) SetUserID(userID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.userID = userID
+}
+
+func (t *RecallTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+
+	t.mu.RLock()
+	userID := t.userID
+	t.mu.RUnlock()
+
+	result, err := t.client.SearchForUser(ctx, userID, query)
+	if err != nil {
+		return "", fmt.Errorf("memory search failed: %w", err)
+	}
+
+	if formatted := result.FormatForPrompt(); formatted != "" {
+		return formatted, nil
+	}
+	return fmt.Sprintf("No memories found for %q", query), nil
+}
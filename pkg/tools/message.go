@@ -23,6 +23,11 @@ func (t *MessageTool) Name() string {
 	return "message"
 }
 
+// Serial implements SerialTool.
+func (t *MessageTool) Serial() bool {
+	return true
+}
+
 func (t *MessageTool) Description() string {
 	return "Send a message to user on a chat channel. Use this when you want to communicate something."
 }
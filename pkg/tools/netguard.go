@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewPublicHTTPClient returns an HTTP client that refuses to connect to
+// private or internal addresses, including through redirects, for fetching
+// URLs that come from untrusted input.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: denyPrivateDialControl}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 15 * time.Second},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("stopped after 5 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
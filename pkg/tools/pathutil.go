@@ -2,45 +2,174 @@ package tools
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
+// CaseInsensitivePaths makes the workspace boundary checks compare paths
+// case-insensitively, matching the default filesystems on macOS and Windows.
+// It defaults to true there and false elsewhere.
+var CaseInsensitivePaths = runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+
+// isWithinDir reports whether path is dir itself or lies beneath it. Both must
+// be absolute and clean.
+func isWithinDir(path, dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	if strings.HasSuffix(dir, string(filepath.Separator)) {
+		// Filesystem root ("/" or `C:\`).
+		prefix = dir
+	}
+	if CaseInsensitivePaths {
+		return strings.EqualFold(path, dir) ||
+			(len(path) > len(prefix) && strings.EqualFold(path[:len(prefix)], prefix))
+	}
+	return path == dir || strings.HasPrefix(path, prefix)
+}
+
 // ValidatePath resolves the given path to an absolute path and checks that it
 // falls within allowedDir. It returns the resolved absolute path or an error
 // if the path escapes the workspace boundary.
 func ValidatePath(path, allowedDir string) (string, error) {
-	if allowedDir == "" {
-		// No restriction configured — resolve and return.
-		abs, err := filepath.Abs(path)
-		if err != nil {
-			return "", fmt.Errorf("failed to resolve path: %w", err)
+	return ValidatePathMulti(path, singleDir(allowedDir))
+}
+
+// ValidatePathMulti is ValidatePath for several roots: it returns the absolute
+// path if it falls under any of allowedDirs. Empty entries are ignored, and no
+// roots at all means no restriction.
+func ValidatePathMulti(path string, allowedDirs []string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	roots, err := absDirs(allowedDirs)
+	if err != nil {
+		return "", err
+	}
+	if len(roots) == 0 {
+		// No restriction configured.
+		return absPath, nil
+	}
+
+	// Allow each directory itself and anything beneath it. The
+	// separator-aware check keeps "/workspace-extra/foo" from matching
+	// "/workspace".
+	for _, root := range roots {
+		if isWithinDir(absPath, root) {
+			return absPath, nil
 		}
-		return abs, nil
 	}
 
-	allowedAbs, err := filepath.Abs(allowedDir)
+	return "", accessDenied(path, "is outside", allowedDirs)
+}
+
+// ValidatePathResolved is ValidatePath plus symlink resolution: the path and
+// allowedDir are both passed through filepath.EvalSymlinks and re-checked, so
+// a link inside the workspace pointing elsewhere is rejected. Paths that don't
+// exist yet are resolved through their deepest existing parent directory.
+func ValidatePathResolved(path, allowedDir string) (string, error) {
+	return ValidatePathResolvedMulti(path, singleDir(allowedDir))
+}
+
+// ValidatePathResolvedMulti is ValidatePathResolved for several roots.
+func ValidatePathResolvedMulti(path string, allowedDirs []string) (string, error) {
+	absPath, err := ValidatePathMulti(path, allowedDirs)
 	if err != nil {
-		return "", fmt.Errorf("failed to resolve allowed directory: %w", err)
+		return "", err
 	}
-	// Ensure the allowed dir ends with separator for prefix matching so that
-	// "/workspace-extra/foo" is not confused with "/workspace/foo".
-	allowedPrefix := allowedAbs + string(filepath.Separator)
 
-	var absPath string
-	if filepath.IsAbs(path) {
-		absPath = filepath.Clean(path)
-	} else {
-		absPath, err = filepath.Abs(path)
+	roots, err := absDirs(allowedDirs)
+	if err != nil || len(roots) == 0 {
+		return absPath, err
+	}
+
+	realPath, err := resolveExisting(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	for _, root := range roots {
+		realRoot, err := resolveExisting(root)
 		if err != nil {
-			return "", fmt.Errorf("failed to resolve path: %w", err)
+			return "", fmt.Errorf("failed to resolve allowed directory: %w", err)
+		}
+		if isWithinDir(realPath, realRoot) {
+			return absPath, nil
 		}
 	}
 
-	// Allow the directory itself and anything beneath it.
-	if absPath != allowedAbs && !strings.HasPrefix(absPath, allowedPrefix) {
-		return "", fmt.Errorf("access denied: path %q is outside workspace %q", path, allowedDir)
+	return "", accessDenied(path, "resolves outside", allowedDirs)
+}
+
+func singleDir(dir string) []string {
+	if dir == "" {
+		return nil
 	}
+	return []string{dir}
+}
+
+// absDirs makes each non-empty entry of dirs absolute and clean.
+func absDirs(dirs []string) ([]string, error) {
+	roots := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve allowed directory: %w", err)
+		}
+		roots = append(roots, abs)
+	}
+	return roots, nil
+}
 
-	return absPath, nil
+func accessDenied(path, verb string, allowedDirs []string) error {
+	if len(allowedDirs) == 1 {
+		return fmt.Errorf("access denied: path %q %s workspace %q", path, verb, allowedDirs[0])
+	}
+	return fmt.Errorf("access denied: path %q %s allowed directories %q", path, verb, allowedDirs)
+}
+
+// resolveExisting evaluates symlinks in the longest existing prefix of the
+// absolute path p and re-appends the components that don't exist yet. A
+// dangling symlink is followed to its target so it can't be used to create a
+// file outside the workspace.
+func resolveExisting(p string) (string, error) {
+	var missing []string
+	for hops := 0; ; {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if fi, lerr := os.Lstat(p); lerr == nil && fi.Mode()&os.ModeSymlink != 0 {
+			if hops++; hops > 255 {
+				return "", fmt.Errorf("too many levels of symbolic links: %s", p)
+			}
+			target, rerr := os.Readlink(p)
+			if rerr != nil {
+				return "", rerr
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(p), target)
+			}
+			p = filepath.Clean(target)
+			continue
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(p))
+		p = parent
+	}
 }
@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidatePathResolvedSymlinks(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(workspace, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(workspace, "escape")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "new"), filepath.Join(workspace, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", filepath.Join(workspace, "inner")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		allowed bool
+	}{
+		{"plain file", filepath.Join(workspace, "a.txt"), true},
+		{"new nested file", filepath.Join(workspace, "sub", "x", "y.txt"), true},
+		{"link inside workspace", filepath.Join(workspace, "inner", "f.txt"), true},
+		{"link to outside", filepath.Join(workspace, "escape", "secret"), false},
+		{"new file under outside link", filepath.Join(workspace, "escape", "new.txt"), false},
+		{"dangling link to outside", filepath.Join(workspace, "dangling"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidatePathResolved(tt.path, workspace)
+			if (err == nil) != tt.allowed {
+				t.Errorf("ValidatePathResolved(%q) error = %v, want allowed=%v", tt.path, err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestValidatePathCaseInsensitive(t *testing.T) {
+	orig := CaseInsensitivePaths
+	defer func() { CaseInsensitivePaths = orig }()
+
+	workspace := filepath.Join(string(filepath.Separator), "Users", "me", "workspace")
+	mixed := filepath.Join(string(filepath.Separator), "Users", "me", "Workspace", "foo")
+	sibling := filepath.Join(string(filepath.Separator), "Users", "me", "WORKSPACE-extra", "foo")
+
+	CaseInsensitivePaths = false
+	if _, err := ValidatePath(mixed, workspace); err == nil {
+		t.Errorf("case-sensitive: expected %q to be rejected", mixed)
+	}
+
+	CaseInsensitivePaths = true
+	if _, err := ValidatePath(mixed, workspace); err != nil {
+		t.Errorf("case-insensitive: expected %q to be allowed, got %v", mixed, err)
+	}
+	if _, err := ValidatePath(sibling, workspace); err == nil {
+		t.Errorf("case-insensitive: expected %q to be rejected", sibling)
+	}
+}
+
+func TestValidatePathMulti(t *testing.T) {
+	workspace := t.TempDir()
+	shared := t.TempDir()
+	other := t.TempDir()
+	roots := []string{workspace, shared}
+
+	for _, p := range []string{filepath.Join(workspace, "a"), filepath.Join(shared, "ref", "b"), shared} {
+		if _, err := ValidatePathMulti(p, roots); err != nil {
+			t.Errorf("ValidatePathMulti(%q) unexpected error: %v", p, err)
+		}
+	}
+	for _, p := range []string{filepath.Join(other, "c"), shared + "-extra", filepath.Join(workspace, "..", "x")} {
+		if _, err := ValidatePathMulti(p, roots); err == nil {
+			t.Errorf("ValidatePathMulti(%q) expected access denied", p)
+		}
+	}
+}
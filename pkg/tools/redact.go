@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactedText replaces each secret found in command output.
+const redactedText = "[REDACTED]"
+
+// defaultRedactPatterns match common secret formats. A pattern with a group
+// named "secret" redacts only that group, keeping e.g. the variable name of
+// "API_KEY=...".
+var defaultRedactPatterns = []*regexp.Regexp{
+	// NAME=value and "name": "value" where the name ends in a secret-like
+	// word, as in GITHUB_TOKEN or db.password, but not max_tokens.
+	regexp.MustCompile(`(?i)\b[\w.-]*(?:secret|token|passw(?:or)?d|api_?key|private_?key|access_?key|credentials?)["']?\s*[=:]\s*["']?(?P<secret>[^\s"',;]{4,})`),
+	regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{16,}=*)`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), // JWT
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                                // AWS access key ID
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),        // OpenAI, Anthropic
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), // Slack
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),      // Google API key
+}
+
+// longHexPattern matches hex strings of 32 or more characters, which are
+// often keys, along with any "sha256:"-style algorithm prefix.
+var longHexPattern = regexp.MustCompile(`(?i)\b((?:md5|sha1|sha224|sha256|sha384|sha512):)?[0-9a-f]{32,}\b`)
+
+// isDigest reports whether the long hex string m in s is a checksum rather
+// than a secret: one with an algorithm prefix, or a digest starting a line
+// of md5sum-style "<hash>  <file>" output. A bare hex string is redacted
+// whatever its length, since keys and tokens come in the same lengths as
+// digests.
+func isDigest(s string, m []int) bool {
+	if m[2] >= 0 {
+		return true
+	}
+	switch m[1] - m[0] {
+	case 32, 40, 64, 128:
+		lineStart := m[0] == 0 || s[m[0]-1] == '\n'
+		rest := s[m[1]:]
+		return lineStart && (strings.HasPrefix(rest, "  ") || strings.HasPrefix(rest, " *"))
+	}
+	return false
+}
+
+// redactLongHex redacts long hex strings other than digests.
+func redactLongHex(s string) string {
+	matches := longHexPattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	out := make([]byte, 0, len(s))
+	pos := 0
+	for _, m := range matches {
+		if isDigest(s, m) {
+			continue
+		}
+		out = append(out, s[pos:m[0]]...)
+		out = append(out, redactedText...)
+		pos = m[1]
+	}
+	return string(append(out, s[pos:]...))
+}
+
+// SetRedactPatterns sets additional regular expressions whose matches are
+// replaced with [REDACTED] in command output. A group named "secret", as in
+// `password=(?P<secret>\S+)`, limits redaction to that group. The built-in
+// secret formats stay in effect unless redaction is disabled with
+// SetRedactSecrets(false).
+func (t *ExecTool) SetRedactPatterns(patterns []string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	t.redactPatterns = compiled
+	return nil
+}
+
+// SetRedactSecrets controls whether secrets are redacted from command output
+// before it is returned or streamed. Enabled by default.
+func (t *ExecTool) SetRedactSecrets(enabled bool) {
+	t.redactDisabled = !enabled
+}
+
+// redact replaces secrets in s. Streamed output is redacted a line at a
+// time (see streamWriter); the final result is redacted as a whole.
+func (t *ExecTool) redact(s string) string {
+	if t.redactDisabled || s == "" {
+		return s
+	}
+	for _, re := range defaultRedactPatterns {
+		s = redactMatches(re, s)
+	}
+	s = redactLongHex(s)
+	for _, re := range t.redactPatterns {
+		s = redactMatches(re, s)
+	}
+	return s
+}
+
+func redactMatches(re *regexp.Regexp, s string) string {
+	group := re.SubexpIndex("secret")
+	if group < 0 {
+		return re.ReplaceAllLiteralString(s, redactedText)
+	}
+
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	out := make([]byte, 0, len(s))
+	pos := 0
+	for _, m := range matches {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 {
+			continue
+		}
+		out = append(out, s[pos:start]...)
+		out = append(out, redactedText...)
+		pos = end
+	}
+	return string(append(out, s[pos:]...))
+}
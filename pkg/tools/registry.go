@@ -2,16 +2,25 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tracing"
 )
 
 type ToolRegistry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools   map[string]Tool
+	mu      sync.RWMutex
+	timeout time.Duration
+	// maxResultChars and workspace configure result spilling; see
+	// SetResultLimit.
+	maxResultChars int
+	workspace      string
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -26,6 +35,25 @@ func (r *ToolRegistry) Register(tool Tool) {
 	r.tools[tool.Name()] = tool
 }
 
+// SetTimeout bounds each tool invocation with its own deadline, separate
+// from the caller's. Tools implementing TimeoutTool choose their own. Zero
+// (the default) adds no deadline.
+func (r *ToolRegistry) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
+}
+
+// toolTimeout returns the deadline to apply to tool.
+func (r *ToolRegistry) toolTimeout(tool Tool) time.Duration {
+	if tt, ok := tool.(TimeoutTool); ok {
+		return tt.Timeout()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.timeout
+}
+
 func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -34,7 +62,7 @@ func (r *ToolRegistry) Get(name string) (Tool, bool) {
 }
 
 func (r *ToolRegistry) Execute(ctx context.Context, name string, args map[string]interface{}) (string, error) {
-	logger.InfoCF("tool", "Tool execution started",
+	logger.InfoCtx(ctx, "tool", "Tool execution started",
 		map[string]interface{}{
 			"tool": name,
 			"args": args,
@@ -42,31 +70,55 @@ func (r *ToolRegistry) Execute(ctx context.Context, name string, args map[string
 
 	tool, ok := r.Get(name)
 	if !ok {
-		logger.ErrorCF("tool", "Tool not found",
+		logger.ErrorCtx(ctx, "tool", "Tool not found",
 			map[string]interface{}{
 				"tool": name,
 			})
 		return "", fmt.Errorf("tool '%s' not found", name)
 	}
 
+	if err := ValidateArgs(tool.Parameters(), args); err != nil {
+		logger.WarnCtx(ctx, "tool", "Tool arguments rejected",
+			map[string]interface{}{
+				"tool":  name,
+				"error": err.Error(),
+			})
+		return "", fmt.Errorf("invalid arguments for tool '%s': %w", name, err)
+	}
+
+	ctx, span := tracing.Start(ctx, "tool.execute", map[string]interface{}{"tool.name": name})
+	defer span.End()
+
+	timeout := r.toolTimeout(tool)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	start := time.Now()
 	result, err := tool.Execute(ctx, args)
 	duration := time.Since(start)
+	if err != nil && timeout > 0 && duration >= timeout && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("tool '%s' timed out after %v: %w", name, timeout, err)
+	}
 
 	if err != nil {
-		logger.ErrorCF("tool", "Tool execution failed",
+		span.RecordError(err)
+		logger.ErrorCtx(ctx, "tool", "Tool execution failed",
 			map[string]interface{}{
 				"tool":     name,
 				"duration": duration.Milliseconds(),
 				"error":    err.Error(),
 			})
 	} else {
-		logger.InfoCF("tool", "Tool execution completed",
+		logger.InfoCtx(ctx, "tool", "Tool execution completed",
 			map[string]interface{}{
 				"tool":          name,
 				"duration_ms":   duration.Milliseconds(),
 				"result_length": len(result),
 			})
+		result = r.capResult(name, result)
 	}
 
 	return result, err
@@ -83,7 +135,30 @@ func (r *ToolRegistry) GetDefinitions() []map[string]interface{} {
 	return definitions
 }
 
-// List returns a list of all registered tool names.
+// ProviderDefinitions returns the registered tools as provider tool
+// definitions, sorted by name so the request is stable across calls.
+func (r *ToolRegistry) ProviderDefinitions() []providers.ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]providers.ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, providers.ToolDefinition{
+			Type: "function",
+			Function: providers.ToolFunctionDefinition{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				Parameters:  tool.Parameters(),
+			},
+		})
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Function.Name < definitions[j].Function.Name
+	})
+	return definitions
+}
+
+// List returns the names of all registered tools, sorted.
 func (r *ToolRegistry) List() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -92,6 +167,7 @@ func (r *ToolRegistry) List() []string {
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// staticTool returns a fixed result.
+type staticTool struct {
+	name   string
+	result string
+}
+
+func (t *staticTool) Name() string        { return t.name }
+func (t *staticTool) Description() string { return "returns a fixed result" }
+func (t *staticTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+func (t *staticTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	return t.result, nil
+}
+
+func TestRegistryResultLimit(t *testing.T) {
+	full := "HEAD" + strings.Repeat("x", 200) + "TAIL"
+
+	t.Run("spills to the workspace", func(t *testing.T) {
+		workspace := t.TempDir()
+		r := NewToolRegistry()
+		r.Register(&staticTool{name: "big/tool", result: full})
+		r.SetResultLimit(50, workspace)
+
+		got, err := r.Execute(context.Background(), "big/tool", map[string]interface{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, _ := filepath.Glob(filepath.Join(workspace, spillDirName, "big_tool-*.txt"))
+		if len(files) != 1 {
+			t.Fatalf("spilled files = %v, want one under %s", files, spillDirName)
+		}
+		if data, _ := os.ReadFile(files[0]); string(data) != full {
+			t.Errorf("spilled file holds %d characters, want the full %d", len(data), len(full))
+		}
+		if !strings.Contains(got, files[0]) {
+			t.Errorf("result %q doesn't name the spill file %s", got, files[0])
+		}
+		if !strings.HasPrefix(got, "[Result truncated from 208 to 50 characters.") ||
+			!strings.Contains(got, "\nHEAD") || !strings.HasSuffix(got, "TAIL") {
+			t.Errorf("result %q lacks the truncation note or head and tail preview", got)
+		}
+	})
+
+	t.Run("no workspace", func(t *testing.T) {
+		r := NewToolRegistry()
+		r.Register(&staticTool{name: "big", result: full})
+		r.SetResultLimit(50, "")
+
+		got, err := r.Execute(context.Background(), "big", map[string]interface{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(got, "[Result truncated from 208 to 50 characters; the full output could not be saved.]\nHEAD") {
+			t.Errorf("result = %q, want the truncated-only note and preview", got)
+		}
+	})
+
+	t.Run("within the limit", func(t *testing.T) {
+		workspace := t.TempDir()
+		r := NewToolRegistry()
+		r.Register(&staticTool{name: "small", result: "ok"})
+		r.SetResultLimit(50, workspace)
+
+		if got, _ := r.Execute(context.Background(), "small", map[string]interface{}{}); got != "ok" {
+			t.Errorf("result = %q, want it unchanged", got)
+		}
+		if _, err := os.Stat(filepath.Join(workspace, spillDirName)); !os.IsNotExist(err) {
+			t.Errorf("spill directory created for a small result: %v", err)
+		}
+	})
+}
@@ -3,48 +3,144 @@ package tools
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 type ExecTool struct {
 	workingDir          string
 	timeout             time.Duration
+	maxTimeout          time.Duration
 	denyPatterns        []*regexp.Regexp
+	warnPatterns        []*regexp.Regexp
 	allowPatterns       []*regexp.Regexp
 	restrictToWorkspace bool
+	maxOutputLen        int
+	env                 map[string]string
+	shell               string
+	shellArgs           []string
+	limits              ResourceLimits
+	auditFunc           ExecAuditFunc
+	stripANSI           bool
+	extraPathWhitelist  []string
+	guardDisabled       bool
+	redactPatterns      []*regexp.Regexp
+	redactDisabled      bool
+	procSem             chan struct{}
+	queueTimeout        time.Duration
+	defaultWorkingDir   string
+}
+
+// defaultDenyPatterns is the minimal built-in denylist — only catastrophic
+// commands (OpenClaw-level trust).
+var defaultDenyPatterns = []*regexp.Regexp{
+	mustCompileGuardPattern(`\brm\s+-[rf]{2}\s+/\s*$`),        // rm -rf /
+	mustCompileGuardPattern(`\brm\s+.*--no-preserve-root\b`),  // rm --no-preserve-root
+	mustCompileGuardPattern(`\b(mkfs|format|diskpart)\b\s`),   // disk formatting
+	mustCompileGuardPattern(`\bdd\s+.*of=/dev/sd[a-z]\b`),     // dd to disk
+	mustCompileGuardPattern(`>\s*/dev/sd[a-z]\b`),             // redirect to disk
+	mustCompileGuardPattern(`\b(shutdown|reboot|poweroff)\b`), // system shutdown
+	mustCompileGuardPattern(`:\(\)\s*\{.*\};\s*:`),            // fork bomb
+
+	// Obfuscation: decoded or downloaded text run as a script bypasses every
+	// literal pattern above, e.g. "echo cm0gLXJmIC8= | base64 -d | sh".
+	mustCompileGuardPattern(shellInterpreters(`\|\s*(sudo\s+)?(env\s+)?(\S*/)?`, `\b`)), // ... | sh
+	mustCompileGuardPattern(shellInterpreters(`\b`, `\s+(-\w+\s+)*<\s*\(`)),             // bash <(...)
+	mustCompileGuardPattern(shellInterpreters(`\b`, `\s+-c\s+["']?\$\(`)),               // sh -c "$(...)"
+	mustCompileGuardPattern(`\beval\b.*(\$\(|\x60)`),                                    // eval "$(...)"
+}
+
+// GuardSeverity is what the exec guard does with a command matching a deny
+// rule.
+type GuardSeverity string
+
+const (
+	// GuardBlock refuses the command.
+	GuardBlock GuardSeverity = "block"
+	// GuardWarn runs the command but logs the match and notes it in the
+	// output.
+	GuardWarn GuardSeverity = "warn"
+)
+
+// DenyRule is a deny pattern with its severity. An empty Severity blocks.
+type DenyRule struct {
+	Pattern  string
+	Severity GuardSeverity
+}
+
+// shellInterpreters returns a pattern matching any common shell interpreter
+// name between prefix and suffix.
+func shellInterpreters(prefix, suffix string) string {
+	return prefix + `(sh|bash|zsh|dash|ksh|mksh|fish|csh|tcsh)` + suffix
+}
+
+// compileGuardPattern compiles a deny or allow pattern. Guard patterns are
+// matched case-insensitively against the command as written, so command
+// names match in any case while paths extracted from the same string keep
+// their case for the workspace check.
+func compileGuardPattern(p string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + p)
+}
+
+func mustCompileGuardPattern(p string) *regexp.Regexp {
+	re, err := compileGuardPattern(p)
+	if err != nil {
+		panic(err)
+	}
+	return re
 }
 
 func NewExecTool(workingDir string) *ExecTool {
-	// Minimal denylist — only catastrophic commands (OpenClaw-level trust)
-	denyPatterns := []*regexp.Regexp{
-		regexp.MustCompile(`\brm\s+-[rf]{2}\s+/\s*$`),         // rm -rf /
-		regexp.MustCompile(`\brm\s+.*--no-preserve-root\b`),    // rm --no-preserve-root
-		regexp.MustCompile(`\b(mkfs|format|diskpart)\b\s`),     // disk formatting
-		regexp.MustCompile(`\bdd\s+.*of=/dev/sd[a-z]\b`),       // dd to disk
-		regexp.MustCompile(`>\s*/dev/sd[a-z]\b`),               // redirect to disk
-		regexp.MustCompile(`\b(shutdown|reboot|poweroff)\b`),   // system shutdown
-		regexp.MustCompile(`:\(\)\s*\{.*\};\s*:`),              // fork bomb
+	denyPatterns := append([]*regexp.Regexp{}, defaultDenyPatterns...)
+
+	shell, shellArgs := "sh", []string{"-c"}
+	if runtime.GOOS == "windows" {
+		shell, shellArgs = "cmd", []string{"/c"}
 	}
 
 	return &ExecTool{
+		shell:               shell,
+		shellArgs:           shellArgs,
 		workingDir:          workingDir,
 		timeout:             60 * time.Second,
+		maxTimeout:          10 * time.Minute,
 		denyPatterns:        denyPatterns,
 		allowPatterns:       nil,
 		restrictToWorkspace: false,
+		maxOutputLen:        10000,
+		limits:              DefaultResourceLimits,
+		stripANSI:           true,
+		procSem:             make(chan struct{}, DefaultMaxConcurrentExec),
+		queueTimeout:        DefaultExecQueueTimeout,
 	}
 }
 
+// Timeout implements TimeoutTool. Commands are bounded by the tool's own
+// timeout (and timeout_seconds), so the registry adds no deadline.
+func (t *ExecTool) Timeout() time.Duration {
+	return 0
+}
+
 func (t *ExecTool) Name() string {
 	return "exec"
 }
 
+// Serial implements SerialTool.
+func (t *ExecTool) Serial() bool {
+	return true
+}
+
 func (t *ExecTool) Description() string {
 	return "Execute a shell command and return its output. Use with caution."
 }
@@ -61,91 +157,395 @@ func (t *ExecTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Optional working directory for the command",
 			},
+			"env": map[string]interface{}{
+				"type":                 "object",
+				"description":          "Optional environment variables for this command only (name -> non-empty string value)",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional timeout for this command in seconds (capped by the configured maximum)",
+				"minimum":     1.0,
+			},
+			"stdin": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional data to write to the command's standard input",
+			},
+			"extra_paths": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional absolute paths outside the workspace this command may reference; each must be on the operator's whitelist",
+			},
+			"output": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional output mode: 'combined' (default, stdout followed by stderr), 'stdout' (stdout only), or 'separate' (labelled stdout and stderr sections)",
+				"enum":        []string{"combined", "stdout", "separate"},
+			},
 		},
 		"required": []string{"command"},
 	}
 }
 
+// ExecResult holds the structured outcome of a command run by ExecTool.
+type ExecResult struct {
+	Stdout string
+	Stderr string
+	// ExitCode is the process exit status, or -1 if it did not exit normally
+	// (killed by a signal, failed to start).
+	ExitCode int
+	TimedOut bool
+	// Timeout is the effective timeout applied to the command.
+	Timeout time.Duration
+	// Err is the error returned by the process, e.g. *exec.ExitError for a
+	// non-zero exit. It is nil on success.
+	Err error
+	// Warnings lists the warn-level deny patterns the command matched.
+	Warnings []string
+}
+
 func (t *ExecTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	result, err := t.ExecuteStructured(ctx, args)
+	if err != nil {
+		var guardErr *execGuardError
+		if errors.As(err, &guardErr) {
+			return fmt.Sprintf("Error: %s", guardErr.msg), nil
+		}
+		var busyErr *execBusyError
+		if errors.As(err, &busyErr) {
+			return fmt.Sprintf("Error: %s", busyErr), nil
+		}
+		return "", err
+	}
+
+	if result.TimedOut {
+		return fmt.Sprintf("Error: Command timed out after %v", result.Timeout), nil
+	}
+
+	mode, _ := args["output"].(string)
+
+	var output string
+	switch mode {
+	case "stdout":
+		output = result.Stdout
+	case "separate":
+		output = "STDOUT:\n" + result.Stdout + "\nSTDERR:\n" + result.Stderr
+	default:
+		output = result.Stdout
+		if result.Stderr != "" {
+			output += "\nSTDERR:\n" + result.Stderr
+		}
+	}
+
+	if result.Err != nil {
+		if result.ExitCode >= 0 {
+			output += fmt.Sprintf("\nExit code: %d", result.ExitCode)
+		} else {
+			// Killed by a signal or failed to start: no exit status to report.
+			output += fmt.Sprintf("\nError: %v", result.Err)
+		}
+	}
+
+	if output == "" {
+		output = "(no output)"
+	}
+
+	output = truncateMiddle(output, t.maxOutputLen)
+	for _, w := range result.Warnings {
+		output = fmt.Sprintf("Warning: command matched cautionary pattern %q; it was allowed to run.\n", w) + output
+	}
+	return output, nil
+}
+
+// truncateMiddle shortens s to roughly maxLen characters by dropping the
+// middle, keeping the head and the tail (where exit status and final errors
+// usually are). maxLen <= 0 disables truncation.
+func truncateMiddle(s string, maxLen int) string {
+	if maxLen <= 0 || len(s) <= maxLen {
+		return s
+	}
+	head := maxLen / 2
+	tail := maxLen - head
+	omitted := len(s) - head - tail
+	return s[:head] + fmt.Sprintf("\n... (truncated %d chars) ...\n", omitted) + s[len(s)-tail:]
+}
+
+// execGuardError reports a command or argument rejected before execution.
+type execGuardError struct {
+	msg string
+}
+
+func (e *execGuardError) Error() string {
+	return e.msg
+}
+
+// ExecuteStructured runs the command described by args (same schema as
+// Execute) and returns stdout and stderr separately, for Go callers that need
+// to parse command output. Commands rejected by the safety guard return an
+// error.
+func (t *ExecTool) ExecuteStructured(ctx context.Context, args map[string]interface{}) (*ExecResult, error) {
+	return t.execute(ctx, args, nil)
+}
+
+func (t *ExecTool) execute(ctx context.Context, args map[string]interface{}, onOutput ExecOutputFunc) (*ExecResult, error) {
 	command, ok := args["command"].(string)
 	if !ok {
-		return "", fmt.Errorf("command is required")
+		return nil, fmt.Errorf("command is required")
+	}
+
+	req, err := t.prepare(command, args)
+	if err != nil {
+		t.audit(ExecAuditEvent{
+			Phase:         ExecAuditBefore,
+			Command:       command,
+			WorkingDir:    req.cwd,
+			BlockedReason: err.Error(),
+		})
+		return nil, err
+	}
+
+	if len(req.warnings) > 0 {
+		logger.WarnCF("tool", "Command matched warn-level deny pattern, allowing",
+			map[string]interface{}{
+				"command":  command,
+				"patterns": req.warnings,
+			})
+	}
+
+	release, err := t.acquireProcess(ctx, command)
+	if err != nil {
+		t.audit(ExecAuditEvent{
+			Phase:      ExecAuditBefore,
+			Command:    command,
+			WorkingDir: req.cwd,
+			BusyReason: err.Error(),
+		})
+		return nil, err
+	}
+	defer release()
+
+	t.audit(ExecAuditEvent{
+		Phase:      ExecAuditBefore,
+		Command:    command,
+		WorkingDir: req.cwd,
+		Warnings:   req.warnings,
+	})
+
+	start := time.Now()
+	result := t.run(ctx, req, onOutput)
+	result.Warnings = req.warnings
+
+	t.audit(ExecAuditEvent{
+		Phase:      ExecAuditAfter,
+		Command:    command,
+		WorkingDir: req.cwd,
+		ExitCode:   result.ExitCode,
+		TimedOut:   result.TimedOut,
+		Duration:   time.Since(start),
+	})
+
+	return result, nil
+}
+
+// execRequest is a validated command ready to run.
+type execRequest struct {
+	command    string
+	cwd        string
+	env        map[string]string
+	stdin      *string
+	timeout    time.Duration
+	extraPaths []string
+	warnings   []string
+}
+
+// prepare resolves the working directory and validates the command and its
+// arguments. The returned request is never nil so the resolved cwd is
+// available for auditing even when the command is blocked.
+func (t *ExecTool) prepare(command string, args map[string]interface{}) (*execRequest, error) {
+	req := &execRequest{command: command, cwd: t.workingDir, timeout: t.timeout}
+
+	if t.restrictToWorkspace && !t.guardDisabled && t.workingDir == "" {
+		return req, &execGuardError{"no workspace is configured, so commands cannot be restricted to it"}
 	}
 
-	cwd := t.workingDir
 	if wd, ok := args["working_dir"].(string); ok && wd != "" {
-		// Validate that the requested working_dir is within the workspace
-		if t.restrictToWorkspace && t.workingDir != "" {
-			absWD, err := filepath.Abs(wd)
-			if err != nil {
-				return "Error: invalid working directory path", nil
-			}
-			absWorkspace, err := filepath.Abs(t.workingDir)
-			if err != nil {
-				return "Error: invalid workspace path", nil
-			}
-			// Ensure the requested dir is the workspace or a subdirectory of it
-			if absWD != absWorkspace && !strings.HasPrefix(absWD, absWorkspace+string(filepath.Separator)) {
-				return "Error: working_dir must be within the workspace", nil
+		// Validate that the requested working_dir is within the workspace,
+		// following symlinks as the filesystem tools do.
+		if t.restrictToWorkspace && !t.guardDisabled && t.workingDir != "" {
+			if _, err := ValidatePathResolved(wd, t.workingDir); err != nil {
+				req.cwd = wd
+				return req, &execGuardError{"working_dir must be within the workspace"}
 			}
 		}
-		cwd = wd
+		req.cwd = wd
 	}
 
-	if cwd == "" {
-		wd, err := os.Getwd()
-		if err == nil {
-			cwd = wd
+	if req.cwd == "" {
+		req.cwd = t.fallbackWorkingDir()
+	}
+
+	if !t.guardDisabled {
+		extraPaths, err := t.parseExtraPaths(args["extra_paths"])
+		if err != nil {
+			return req, &execGuardError{err.Error()}
+		}
+		req.extraPaths = extraPaths
+
+		if guardError := t.guardCommand(command, req.cwd, req.extraPaths...); guardError != "" {
+			return req, &execGuardError{guardError}
+		}
+		req.warnings = t.guardWarnings(command)
+	}
+
+	env, err := parseEnvArg(args["env"])
+	if err != nil {
+		return req, &execGuardError{err.Error()}
+	}
+	req.env = env
+
+	timeout, err := parseTimeoutArg(args["timeout_seconds"])
+	if err != nil {
+		return req, &execGuardError{err.Error()}
+	}
+	if timeout > 0 {
+		req.timeout = timeout
+		if t.maxTimeout > 0 && req.timeout > t.maxTimeout {
+			req.timeout = t.maxTimeout
 		}
 	}
 
-	if guardError := t.guardCommand(command, cwd); guardError != "" {
-		return fmt.Sprintf("Error: %s", guardError), nil
+	if stdin, ok := args["stdin"].(string); ok {
+		req.stdin = &stdin
 	}
 
-	cmdCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	return req, nil
+}
+
+// run executes a prepared request, passing output to onOutput as it arrives
+// when non-nil.
+func (t *ExecTool) run(ctx context.Context, req *execRequest, onOutput ExecOutputFunc) *ExecResult {
+	cmdCtx, cancel := context.WithTimeout(ctx, req.timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, "sh", "-c", command)
-	if cwd != "" {
-		cmd.Dir = cwd
+	shellArgs := append(append([]string{}, t.shellArgs...), req.command)
+	cmd := exec.CommandContext(cmdCtx, t.shell, shellArgs...)
+	setProcessGroup(cmd)
+	// Don't block forever on pipes still held open by stray descendants
+	cmd.WaitDelay = 2 * time.Second
+	if req.cwd != "" {
+		cmd.Dir = req.cwd
+	}
+	if len(t.env) > 0 || len(req.env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range t.env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		// Per-call values are appended last so they override SetEnv defaults
+		for k, v := range req.env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+
+	if req.stdin != nil {
+		cmd.Stdin = strings.NewReader(*req.stdin)
 	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	var streams []*streamWriter
+	if onOutput != nil {
+		var mu sync.Mutex
+		streams = []*streamWriter{
+			{mu: &mu, stream: ExecStdout, onOutput: onOutput, strip: t.stripANSI, redact: t.redact, buf: &stdout},
+			{mu: &mu, stream: ExecStderr, onOutput: onOutput, strip: t.stripANSI, redact: t.redact, buf: &stderr},
+		}
+		cmd.Stdout, cmd.Stderr = streams[0], streams[1]
+	}
 
-	err := cmd.Run()
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		output += "\nSTDERR:\n" + stderr.String()
+	err := applyResourceLimits(cmd, t.limits)
+	if err == nil {
+		err = cmd.Run()
+	}
+	for _, w := range streams {
+		w.flush()
 	}
 
+	exitCode := 0
 	if err != nil {
-		if cmdCtx.Err() == context.DeadlineExceeded {
-			return fmt.Sprintf("Error: Command timed out after %v", t.timeout), nil
+		exitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
 		}
-		output += fmt.Sprintf("\nExit code: %v", err)
 	}
 
-	if output == "" {
-		output = "(no output)"
+	stdoutStr, stderrStr := stdout.String(), stderr.String()
+	if t.stripANSI {
+		stdoutStr = StripANSI(stdoutStr)
+		stderrStr = StripANSI(stderrStr)
 	}
+	stdoutStr, stderrStr = t.redact(stdoutStr), t.redact(stderrStr)
 
-	maxLen := 10000
-	if len(output) > maxLen {
-		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
+	return &ExecResult{
+		Stdout:   stdoutStr,
+		Stderr:   stderrStr,
+		ExitCode: exitCode,
+		TimedOut: err != nil && cmdCtx.Err() == context.DeadlineExceeded,
+		Timeout:  req.timeout,
+		Err:      err,
 	}
+}
 
-	return output, nil
+// absPathPattern matches absolute Windows and Unix paths in a command.
+var absPathPattern = regexp.MustCompile(`[A-Za-z]:\\[^\\\"']+|/[^\s\"']+`)
+
+var (
+	// urlSchemePattern matches a URL scheme such as "https:".
+	urlSchemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:$`)
+	// relativePathPrefix matches token text that makes a following "/..."
+	// relative: a path component, or a host:port.
+	relativePathPrefix = regexp.MustCompile(`^[\w.+@%-]+(:[0-9]+)?$`)
+	// windowsSwitchPattern matches a cmd.exe switch such as "/s", "/?" or
+	// "/A:H".
+	windowsSwitchPattern = regexp.MustCompile(`^/[A-Za-z0-9?]+(:[^\s/\\]*)?$`)
+)
+
+// windowsSwitches makes the path guard treat "/x" tokens as command switches
+// rather than root paths, as cmd.exe does. It defaults to true on Windows.
+var windowsSwitches = runtime.GOOS == "windows"
+
+// tildeHome resolves the home directory of "~user" (or the current user's
+// for "~").
+func tildeHome(name string) (string, bool) {
+	if name == "" {
+		home, err := os.UserHomeDir()
+		return home, err == nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
+
+// isPathBoundary reports whether c can directly precede an absolute path
+// argument, e.g. whitespace, a quote, "=" in --flag=/path or a redirect.
+func isPathBoundary(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '"', '\'', '=', '<', '>', '(', ';', '|', '&', '`', ',':
+		return true
+	}
+	return false
 }
 
-func (t *ExecTool) guardCommand(command, cwd string) string {
+// guardCommand returns why command must not run, or "" if it may. When the
+// tool is restricted to the workspace, absolute paths must fall under the
+// workspace or one of extraPaths.
+func (t *ExecTool) guardCommand(command, cwd string, extraPaths ...string) string {
 	cmd := strings.TrimSpace(command)
-	lower := strings.ToLower(cmd)
 
 	for _, pattern := range t.denyPatterns {
-		if pattern.MatchString(lower) {
+		if pattern.MatchString(cmd) {
 			return "Command blocked by safety guard (dangerous pattern detected)"
 		}
 	}
@@ -153,7 +553,7 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	if len(t.allowPatterns) > 0 {
 		allowed := false
 		for _, pattern := range t.allowPatterns {
-			if pattern.MatchString(lower) {
+			if pattern.MatchString(cmd) {
 				allowed = true
 				break
 			}
@@ -178,10 +578,47 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 			return "Command blocked by safety guard (cannot resolve workspace path)"
 		}
 
-		pathPattern := regexp.MustCompile(`[A-Za-z]:\\[^\\\"']+|/[^\s\"']+`)
-		matches := pathPattern.FindAllString(cmd, -1)
-
-		for _, raw := range matches {
+		for _, loc := range absPathPattern.FindAllStringIndex(cmd, -1) {
+			raw := cmd[loc[0]:loc[1]]
+			atTokenStart := loc[0] == 0 || isPathBoundary(cmd[loc[0]-1])
+			if windowsSwitches && atTokenStart && windowsSwitchPattern.MatchString(raw) {
+				continue
+			}
+			if !atTokenStart {
+				tokenStart := loc[0]
+				for tokenStart > 0 && !isPathBoundary(cmd[tokenStart-1]) {
+					tokenStart--
+				}
+				prefix := cmd[tokenStart:loc[0]]
+				switch {
+				case prefix[0] == '$':
+					// "$HOME/.ssh" and friends can't be resolved statically
+					return "Command blocked by safety guard (path outside workspace)"
+				case prefix[0] == '~':
+					home, ok := tildeHome(prefix[1:])
+					if !ok {
+						return "Command blocked by safety guard (path outside workspace)"
+					}
+					raw = home + raw
+				case strings.Trim(prefix, `\{`) == "":
+					// Escapes and brace expansion leave an absolute path:
+					// `\/etc/passwd`, "{/etc/passwd,}".
+				case urlSchemePattern.MatchString(prefix) && strings.HasPrefix(raw, "//"):
+					if !strings.EqualFold(prefix, "file:") {
+						continue
+					}
+					// file:///etc/passwd names a local path.
+					raw = raw[2:]
+					if !strings.HasPrefix(raw, "/") {
+						return "Command blocked by safety guard (path outside workspace)"
+					}
+				case relativePathPrefix.MatchString(prefix):
+					// Not a path at the start of a token: relative paths
+					// ("./dir/file"), host:port addresses and expressions
+					// like sed's "s/a/b/".
+					continue
+				}
+			}
 			// Allow common safe system paths that commands legitimately reference
 			if raw == "/dev/null" || raw == "/dev/stdin" || raw == "/dev/stdout" || raw == "/dev/stderr" ||
 				raw == "/tmp" || strings.HasPrefix(raw, "/tmp/") {
@@ -191,8 +628,10 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 			if err != nil {
 				continue
 			}
-			// Path must be within the workspace
-			if p != absWorkspace && !strings.HasPrefix(p, absWorkspace+string(filepath.Separator)) {
+			// Path must be within the workspace or a granted extra path.
+			// Paths keep their original case; isWithinDir only folds case
+			// where the filesystem does.
+			if !isWithinDir(p, absWorkspace) && !withinAnyDir(p, extraPaths) {
 				return "Command blocked by safety guard (path outside workspace)"
 			}
 		}
@@ -201,18 +640,314 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	return ""
 }
 
+// guardWarnings returns the warn-level deny patterns command matches.
+func (t *ExecTool) guardWarnings(command string) []string {
+	cmd := strings.TrimSpace(command)
+	var matched []string
+	for _, pattern := range t.warnPatterns {
+		if pattern.MatchString(cmd) {
+			matched = append(matched, guardPatternSource(pattern))
+		}
+	}
+	return matched
+}
+
+// guardPatternSource returns a guard pattern as configured, without the
+// case-insensitivity flag compileGuardPattern adds.
+func guardPatternSource(re *regexp.Regexp) string {
+	return strings.TrimPrefix(re.String(), "(?i)")
+}
+
+func guardPatternSources(patterns []*regexp.Regexp) []string {
+	sources := make([]string, len(patterns))
+	for i, re := range patterns {
+		sources[i] = guardPatternSource(re)
+	}
+	return sources
+}
+
+// ListDenyPatterns returns the patterns that block commands, built-in ones
+// first.
+func (t *ExecTool) ListDenyPatterns() []string {
+	return guardPatternSources(t.denyPatterns)
+}
+
+// ListWarnPatterns returns the warn-level deny patterns.
+func (t *ExecTool) ListWarnPatterns() []string {
+	return guardPatternSources(t.warnPatterns)
+}
+
+// ListAllowPatterns returns the allowlist; empty means every command not
+// denied is allowed.
+func (t *ExecTool) ListAllowPatterns() []string {
+	return guardPatternSources(t.allowPatterns)
+}
+
+// IsRestrictedToWorkspace reports whether commands are confined to the
+// workspace.
+func (t *ExecTool) IsRestrictedToWorkspace() bool {
+	return t.restrictToWorkspace
+}
+
+// SetGuardEnabled turns the whole safety guard on or off. Disabling it
+// bypasses the deny patterns (including the built-in ones), the allow
+// patterns and the workspace restriction in one go.
+//
+// DANGEROUS: with the guard off the model can run any command with the
+// privileges of this process. Only do this on a trusted, single-user
+// machine. The guard is on by default.
+func (t *ExecTool) SetGuardEnabled(enabled bool) {
+	t.guardDisabled = !enabled
+}
+
+// GuardEnabled reports whether the safety guard is active.
+func (t *ExecTool) GuardEnabled() bool {
+	return !t.guardDisabled
+}
+
+// SetExtraPathWhitelist sets the paths outside the workspace that a single
+// call may be granted through the extra_paths argument, for trusted commands
+// such as reading a system CA bundle. Each entry covers itself and everything
+// beneath it. Without a whitelist, extra_paths is rejected.
+func (t *ExecTool) SetExtraPathWhitelist(paths []string) error {
+	abs, err := absDirs(paths)
+	if err != nil {
+		return err
+	}
+	t.extraPathWhitelist = abs
+	return nil
+}
+
+// parseExtraPaths validates the extra_paths argument against the whitelist
+// and returns the paths made absolute.
+func (t *ExecTool) parseExtraPaths(raw interface{}) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("extra_paths must be an array of paths")
+	}
+
+	paths := make([]string, 0, len(list))
+	for _, item := range list {
+		path, ok := item.(string)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("extra_paths must be an array of paths")
+		}
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("extra path %q must be absolute", path)
+		}
+		abs := filepath.Clean(path)
+		if !withinAnyDir(abs, t.extraPathWhitelist) {
+			return nil, fmt.Errorf("extra path %q is not on the operator whitelist", path)
+		}
+		paths = append(paths, abs)
+	}
+	return paths, nil
+}
+
+// withinAnyDir reports whether path is one of dirs or lies beneath one.
+func withinAnyDir(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isWithinDir(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *ExecTool) SetTimeout(timeout time.Duration) {
 	t.timeout = timeout
 }
 
+// SetMaxTimeout caps the per-call "timeout_seconds" argument (default 10m).
+// 0 removes the cap.
+func (t *ExecTool) SetMaxTimeout(timeout time.Duration) {
+	t.maxTimeout = timeout
+}
+
+// SetResourceLimits sets the per-command resource limits (Linux only).
+// Pass ResourceLimits{} to disable them.
+func (t *ExecTool) SetResourceLimits(limits ResourceLimits) {
+	t.limits = limits
+}
+
+// SetStripANSI controls whether ANSI/VT escape sequences (colors, cursor
+// movement) are removed from command output. Enabled by default.
+func (t *ExecTool) SetStripANSI(strip bool) {
+	t.stripANSI = strip
+}
+
+// ansiPattern matches CSI sequences (ESC [ ... final), OSC sequences
+// (ESC ] ... BEL or ESC \) and two-byte escape sequences.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// StripANSI removes ANSI/VT control sequences from s.
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// SetShell sets the shell used to run commands; the command string is passed
+// as the final argument, e.g. SetShell("bash", "-c") or
+// SetShell("powershell", "-NoProfile", "-Command"). Defaults to "cmd /c" on
+// Windows and "sh -c" elsewhere. Safety guards apply regardless of shell.
+func (t *ExecTool) SetShell(shell string, args ...string) {
+	t.shell = shell
+	t.shellArgs = args
+}
+
+// SetEnv sets environment variables added to every command, on top of the
+// parent process environment. Per-call "env" arguments take precedence.
+func (t *ExecTool) SetEnv(env map[string]string) error {
+	for k, v := range env {
+		if err := validateEnvVar(k, v); err != nil {
+			return err
+		}
+	}
+	t.env = env
+	return nil
+}
+
+// parseEnvArg converts the optional "env" tool argument into a validated map.
+func parseEnvArg(raw interface{}) (map[string]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("env must be an object of string values")
+	}
+	env := make(map[string]string, len(m))
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("env value for %q must be a string", k)
+		}
+		if err := validateEnvVar(k, s); err != nil {
+			return nil, err
+		}
+		env[k] = s
+	}
+	return env, nil
+}
+
+// parseTimeoutArg converts the optional "timeout_seconds" tool argument,
+// decoded from JSON as float64 or passed as an int by Go callers. Zero means
+// no override.
+func parseTimeoutArg(raw interface{}) (time.Duration, error) {
+	var seconds float64
+	switch v := raw.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		seconds = v
+	case int:
+		seconds = float64(v)
+	case int64:
+		seconds = float64(v)
+	default:
+		return 0, fmt.Errorf("timeout_seconds must be a number, got %T", raw)
+	}
+	if seconds <= 0 {
+		return 0, nil
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func validateEnvVar(name, value string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid env variable name %q", name)
+	}
+	if value == "" {
+		return fmt.Errorf("env variable %q must have a non-empty value", name)
+	}
+	return nil
+}
+
+// SetMaxOutputLen sets the maximum number of output characters returned to
+// the model. Longer output keeps its head and tail. 0 means unlimited.
+func (t *ExecTool) SetMaxOutputLen(maxLen int) {
+	t.maxOutputLen = maxLen
+}
+
+// SetDefaultWorkingDir sets the directory commands run in when the tool has
+// no workspace and the call gives no working_dir. Without one, commands fall
+// back to the process's current directory, with a warning.
+func (t *ExecTool) SetDefaultWorkingDir(dir string) {
+	t.defaultWorkingDir = dir
+}
+
+// fallbackWorkingDir resolves the directory for a command when neither the
+// call nor the tool names one: the configured default, else the process's
+// current directory.
+func (t *ExecTool) fallbackWorkingDir() string {
+	if t.defaultWorkingDir != "" {
+		return t.defaultWorkingDir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		logger.WarnCF("tool", "No working directory configured and the current one is unknown", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return ""
+	}
+	logger.WarnCF("tool", "No working directory configured, running command in the process's current directory", map[string]interface{}{
+		"cwd": wd,
+	})
+	return wd
+}
+
 func (t *ExecTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
 
+// SetDenyPatterns sets additional regular expressions that block matching
+// commands. The built-in defaults always stay in effect; calling this again
+// replaces the previously added patterns. Patterns are compiled
+// case-insensitively, with (?i), and matched against the command as given.
+func (t *ExecTool) SetDenyPatterns(patterns []string) error {
+	rules := make([]DenyRule, len(patterns))
+	for i, p := range patterns {
+		rules[i] = DenyRule{Pattern: p, Severity: GuardBlock}
+	}
+	return t.SetDenyRules(rules)
+}
+
+// SetDenyRules is SetDenyPatterns with a severity per pattern: block-level
+// matches are refused, while warn-level matches run but are logged and
+// noted in the output. The built-in defaults always block.
+func (t *ExecTool) SetDenyRules(rules []DenyRule) error {
+	denyPatterns := append([]*regexp.Regexp{}, defaultDenyPatterns...)
+	var warnPatterns []*regexp.Regexp
+	for _, r := range rules {
+		re, err := compileGuardPattern(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid deny pattern %q: %w", r.Pattern, err)
+		}
+		switch r.Severity {
+		case "", GuardBlock:
+			denyPatterns = append(denyPatterns, re)
+		case GuardWarn:
+			warnPatterns = append(warnPatterns, re)
+		default:
+			return fmt.Errorf("invalid severity %q for deny pattern %q (want %q or %q)", r.Severity, r.Pattern, GuardBlock, GuardWarn)
+		}
+	}
+	t.denyPatterns = denyPatterns
+	t.warnPatterns = warnPatterns
+	return nil
+}
+
 func (t *ExecTool) SetAllowPatterns(patterns []string) error {
 	t.allowPatterns = make([]*regexp.Regexp, 0, len(patterns))
 	for _, p := range patterns {
-		re, err := regexp.Compile(p)
+		re, err := compileGuardPattern(p)
 		if err != nil {
 			return fmt.Errorf("invalid allow pattern %q: %w", p, err)
 		}
@@ -220,3 +955,27 @@ func (t *ExecTool) SetAllowPatterns(patterns []string) error {
 	}
 	return nil
 }
+
+// LoadAllowPatternsFromFile reads allow patterns from path, one regex per
+// line, and applies them with SetAllowPatterns. Blank lines and lines starting
+// with '#' are ignored.
+func (t *ExecTool) LoadAllowPatternsFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read allow patterns: %w", err)
+	}
+
+	var patterns []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := compileGuardPattern(line); err != nil {
+			return fmt.Errorf("%s:%d: invalid allow pattern %q: %w", path, i+1, line, err)
+		}
+		patterns = append(patterns, line)
+	}
+
+	return t.SetAllowPatterns(patterns)
+}
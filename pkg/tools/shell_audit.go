@@ -0,0 +1,47 @@
+package tools
+
+import "time"
+
+// ExecAuditPhase identifies when an ExecAuditEvent was emitted.
+type ExecAuditPhase string
+
+const (
+	// ExecAuditBefore fires for every command before it runs, including
+	// commands rejected by the safety guard (BlockedReason is then set) or
+	// turned away by the concurrency limit (BusyReason is set). No
+	// ExecAuditAfter event follows a rejection.
+	ExecAuditBefore ExecAuditPhase = "before"
+	// ExecAuditAfter fires once a command has finished running.
+	ExecAuditAfter ExecAuditPhase = "after"
+)
+
+// ExecAuditEvent describes a command the exec tool was asked to run.
+type ExecAuditEvent struct {
+	Phase         ExecAuditPhase `json:"phase"`
+	Time          time.Time      `json:"time"`
+	Command       string         `json:"command"`
+	WorkingDir    string         `json:"working_dir"`
+	BlockedReason string         `json:"blocked_reason,omitempty"`
+	BusyReason    string         `json:"busy_reason,omitempty"`
+	Warnings      []string       `json:"warnings,omitempty"`
+	ExitCode      int            `json:"exit_code"`
+	TimedOut      bool           `json:"timed_out,omitempty"`
+	Duration      time.Duration  `json:"duration,omitempty"`
+}
+
+// ExecAuditFunc receives audit events from ExecTool. It is called
+// synchronously, so slow sinks should hand events off to a goroutine.
+type ExecAuditFunc func(event ExecAuditEvent)
+
+// SetAuditFunc installs a hook that is called before and after every command.
+func (t *ExecTool) SetAuditFunc(fn ExecAuditFunc) {
+	t.auditFunc = fn
+}
+
+func (t *ExecTool) audit(event ExecAuditEvent) {
+	if t.auditFunc == nil {
+		return
+	}
+	event.Time = time.Now()
+	t.auditFunc(event)
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Defaults for how many commands an ExecTool runs at once and how long an
+// excess command waits for a slot. Kept small for constrained devices.
+const (
+	DefaultMaxConcurrentExec = 2
+	DefaultExecQueueTimeout  = 30 * time.Second
+)
+
+// SetMaxConcurrent limits how many commands run at once across all callers.
+// Excess commands wait up to queueTimeout for a running one to finish and
+// are then rejected; queueTimeout <= 0 rejects them immediately. max <= 0
+// removes the limit. Call it before the tool is in use.
+func (t *ExecTool) SetMaxConcurrent(max int, queueTimeout time.Duration) {
+	t.procSem = nil
+	if max > 0 {
+		t.procSem = make(chan struct{}, max)
+	}
+	t.queueTimeout = queueTimeout
+}
+
+// acquireProcess waits for a free process slot. The returned release must
+// be called once the command has finished.
+func (t *ExecTool) acquireProcess(ctx context.Context, command string) (func(), error) {
+	if t.procSem == nil {
+		return func() {}, nil
+	}
+	release := func() { <-t.procSem }
+
+	select {
+	case t.procSem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if t.queueTimeout <= 0 {
+		return nil, t.busyError()
+	}
+
+	logger.DebugCF("tool", "Command queued, concurrent exec limit reached",
+		map[string]interface{}{
+			"command": command,
+			"limit":   cap(t.procSem),
+		})
+
+	timer := time.NewTimer(t.queueTimeout)
+	defer timer.Stop()
+	select {
+	case t.procSem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, t.busyError()
+	}
+}
+
+func (t *ExecTool) busyError() error {
+	return &execBusyError{limit: cap(t.procSem)}
+}
+
+// execBusyError reports a command turned away because the concurrent exec
+// limit was reached. Unlike an execGuardError the command itself is fine
+// and can be retried later.
+type execBusyError struct {
+	limit int
+}
+
+func (e *execBusyError) Error() string {
+	return fmt.Sprintf("too many commands are already running (limit %d); wait for them to finish and try again", e.limit)
+}
@@ -0,0 +1,33 @@
+package tools
+
+// ResourceLimits caps the resources a single ExecTool command may consume.
+// A zero field leaves that limit unset. Limits are only enforced on Linux.
+type ResourceLimits struct {
+	// MemoryBytes limits the virtual address space (RLIMIT_AS).
+	MemoryBytes uint64
+	// CPUSeconds limits CPU time (RLIMIT_CPU).
+	CPUSeconds uint64
+	// MaxProcesses limits the number of processes for the user (RLIMIT_NPROC).
+	// Note that this counts all processes owned by the user, not just the
+	// command's children.
+	MaxProcesses uint64
+}
+
+// DefaultResourceLimits are applied to new ExecTools. The address space
+// isn't capped by default: runtimes such as the JVM and Go reserve far more
+// virtual memory than they use, so any cap low enough to matter breaks
+// them. Set MemoryBytes to opt in.
+var DefaultResourceLimits = ResourceLimits{
+	CPUSeconds: 600,
+}
+
+// ExecLimitedCommand is the hidden subcommand ExecTool starts commands
+// through when resource limits are set:
+//
+//	picoclaw __exec-limited <memory>,<cpu>,<processes> <command> [args...]
+//
+// It applies the limits to itself and then execs the command, so they are in
+// place before the command runs at all. The binary's main must hand it to
+// RunExecLimited; a program embedding ExecTool that doesn't should disable
+// the limits with SetResourceLimits(ResourceLimits{}).
+const ExecLimitedCommand = "__exec-limited"
@@ -0,0 +1,63 @@
+//go:build linux
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// applyResourceLimits rewrites cmd to start through ExecLimitedCommand of the
+// running binary, which applies limits and then execs the original command.
+func applyResourceLimits(cmd *exec.Cmd, limits ResourceLimits) error {
+	if limits == (ResourceLimits{}) || cmd.Err != nil {
+		return nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable for resource limits: %w", err)
+	}
+
+	spec := fmt.Sprintf("%d,%d,%d", limits.MemoryBytes, limits.CPUSeconds, limits.MaxProcesses)
+	cmd.Args = append([]string{self, ExecLimitedCommand, spec, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = self
+	return nil
+}
+
+// RunExecLimited implements ExecLimitedCommand. args are the arguments after
+// the subcommand: the limits, then the command and its arguments. It sets the
+// limits on the current process and replaces it with the command, so it only
+// returns on error.
+func RunExecLimited(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: %s <memory>,<cpu>,<processes> <command> [args...]", ExecLimitedCommand)
+	}
+	spec, argv := args[0], args[1:]
+	var limits ResourceLimits
+	if _, err := fmt.Sscanf(spec, "%d,%d,%d", &limits.MemoryBytes, &limits.CPUSeconds, &limits.MaxProcesses); err != nil {
+		return fmt.Errorf("invalid resource limits %q: %w", spec, err)
+	}
+
+	for _, l := range []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"address space", unix.RLIMIT_AS, limits.MemoryBytes},
+		{"CPU time", unix.RLIMIT_CPU, limits.CPUSeconds},
+		{"processes", unix.RLIMIT_NPROC, limits.MaxProcesses},
+	} {
+		if l.value == 0 {
+			continue
+		}
+		rlim := unix.Rlimit{Cur: l.value, Max: l.value}
+		if err := unix.Setrlimit(l.resource, &rlim); err != nil {
+			return fmt.Errorf("limit %s: %w", l.name, err)
+		}
+	}
+	return syscall.Exec(argv[0], argv, os.Environ())
+}
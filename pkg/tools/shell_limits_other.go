@@ -0,0 +1,19 @@
+//go:build !linux
+
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// applyResourceLimits is a no-op outside Linux.
+func applyResourceLimits(cmd *exec.Cmd, limits ResourceLimits) error {
+	return nil
+}
+
+// RunExecLimited always fails outside Linux, where ExecTool never starts
+// commands through ExecLimitedCommand.
+func RunExecLimited(args []string) error {
+	return fmt.Errorf("%s is only supported on Linux", ExecLimitedCommand)
+}
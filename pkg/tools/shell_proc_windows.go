@@ -0,0 +1,9 @@
+//go:build windows
+
+package tools
+
+import "os/exec"
+
+// setProcessGroup is a no-op on Windows; context cancellation kills only the
+// direct child process.
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// ExecStream identifies which output stream an ExecChunk came from.
+type ExecStream string
+
+const (
+	ExecStdout ExecStream = "stdout"
+	ExecStderr ExecStream = "stderr"
+)
+
+// ExecChunk is a piece of command output: one or more whole lines, or the
+// final unterminated line once the command exits.
+type ExecChunk struct {
+	Stream ExecStream
+	Data   string
+}
+
+// ExecOutputFunc receives output chunks while a command runs. Calls are
+// serialized, so the callback needn't be safe for concurrent use, but it
+// runs on the output path and should not block for long.
+type ExecOutputFunc func(chunk ExecChunk)
+
+// ExecuteStreaming is ExecuteStructured with live output: onOutput is called
+// with stdout and stderr a line at a time as the command produces them. The guard,
+// timeout and cancellation of ctx apply as usual, and the returned result
+// still holds the complete output.
+func (t *ExecTool) ExecuteStreaming(ctx context.Context, args map[string]interface{}, onOutput ExecOutputFunc) (*ExecResult, error) {
+	return t.execute(ctx, args, onOutput)
+}
+
+// streamHoldLimit bounds how much output a streamWriter holds back waiting
+// for a line to end.
+const streamHoldLimit = 64 << 10
+
+// streamWriter forwards output to onOutput a line at a time and buffers it
+// for the final result. Holding back partial lines keeps an ANSI sequence or
+// a secret split across two writes in one piece, so it is stripped or
+// redacted as a whole; a private key block is held until its END line.
+type streamWriter struct {
+	mu       *sync.Mutex
+	stream   ExecStream
+	onOutput ExecOutputFunc
+	strip    bool
+	redact   func(string) string
+	buf      io.Writer
+	pending  []byte
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	if n := w.ready(); n > 0 {
+		w.emit(w.pending[:n])
+		w.pending = w.pending[n:]
+	}
+	return w.buf.Write(p)
+}
+
+// ready returns how many bytes of pending output can be passed on.
+func (w *streamWriter) ready() int {
+	if len(w.pending) > streamHoldLimit {
+		return len(w.pending)
+	}
+	n := bytes.LastIndexAny(w.pending, "\n\r") + 1
+	if begin := bytes.LastIndex(w.pending[:n], []byte("-----BEGIN ")); begin >= 0 &&
+		!bytes.Contains(w.pending[begin:], []byte("-----END ")) {
+		n = bytes.LastIndexByte(w.pending[:begin], '\n') + 1
+	}
+	return n
+}
+
+// flush passes on whatever output is still held back. Call it once the
+// command has exited.
+func (w *streamWriter) flush() {
+	if len(w.pending) > 0 {
+		w.emit(w.pending)
+		w.pending = nil
+	}
+}
+
+func (w *streamWriter) emit(p []byte) {
+	data := string(p)
+	if w.strip {
+		data = StripANSI(data)
+	}
+	if w.redact != nil {
+		data = w.redact(data)
+	}
+	if data != "" {
+		w.mu.Lock()
+		w.onOutput(ExecChunk{Stream: w.stream, Data: data})
+		w.mu.Unlock()
+	}
+}
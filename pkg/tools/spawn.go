@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 type SpawnTool struct {
@@ -21,6 +22,12 @@ func NewSpawnTool(manager *SubagentManager) *SpawnTool {
 	}
 }
 
+// Timeout implements TimeoutTool. The spawned subagent keeps running after
+// the call returns, so its context must not carry a per-call deadline.
+func (t *SpawnTool) Timeout() time.Duration {
+	return 0
+}
+
 func (t *SpawnTool) Name() string {
 	return "spawn"
 }
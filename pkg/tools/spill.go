@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// spillDirName is the workspace directory oversized tool results are
+// written to.
+const spillDirName = "tool_output"
+
+// spillMaxAge is how long spilled results are kept before being pruned.
+const spillMaxAge = 24 * time.Hour
+
+var spillSeq atomic.Uint64
+
+// SetResultLimit caps tool results at maxChars. A longer result is written
+// in full to a file under workspace/tool_output, and the model gets a
+// preview of its head and tail plus the file's path, so it can read or grep
+// the rest in pieces. maxChars <= 0 disables the cap.
+func (r *ToolRegistry) SetResultLimit(maxChars int, workspace string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxResultChars = maxChars
+	r.workspace = workspace
+}
+
+// capResult applies the result limit to a successful result of tool name.
+func (r *ToolRegistry) capResult(name, result string) string {
+	r.mu.RLock()
+	maxChars, workspace := r.maxResultChars, r.workspace
+	r.mu.RUnlock()
+	if maxChars <= 0 || len(result) <= maxChars {
+		return result
+	}
+
+	preview := truncateMiddle(result, maxChars)
+	path, err := spillResult(workspace, name, result)
+	if err != nil {
+		logger.WarnCF("tool", "Failed to save oversized tool result", map[string]interface{}{
+			"tool":   name,
+			"length": len(result),
+			"error":  err.Error(),
+		})
+		return fmt.Sprintf("[Result truncated from %d to %d characters; the full output could not be saved.]\n%s",
+			len(result), maxChars, preview)
+	}
+
+	logger.InfoCF("tool", "Oversized tool result saved to file", map[string]interface{}{
+		"tool":   name,
+		"length": len(result),
+		"path":   path,
+	})
+	return fmt.Sprintf("[Result truncated from %d to %d characters. The full output is in %s; "+
+		"use grep or read_file with start_line/end_line to inspect it.]\n%s", len(result), maxChars, path, preview)
+}
+
+// spillResult writes result to a new file in the workspace's spill directory
+// and returns its path, pruning files older than spillMaxAge on the way.
+func spillResult(workspace, name, result string) (string, error) {
+	if workspace == "" {
+		return "", fmt.Errorf("no workspace configured")
+	}
+	dir, err := ValidatePath(filepath.Join(workspace, spillDirName), workspace)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	pruneSpillDir(dir)
+
+	filename := fmt.Sprintf("%s-%s-%d.txt", sanitizeSpillName(name), time.Now().Format("20060102-150405"), spillSeq.Add(1))
+	path, err := ValidatePath(filepath.Join(dir, filename), workspace)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(result), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+func pruneSpillDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-spillMaxAge)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Mode().IsRegular() && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// sanitizeSpillName keeps a tool name safe for use in a file name.
+func sanitizeSpillName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidateArgs checks tool-call arguments against a tool's Parameters()
+// schema. It covers the JSON Schema subset the tools use: required, type,
+// enum, properties and items. Unknown keywords are ignored, and a null value
+// counts as absent. The error names the offending parameter so the model can
+// correct the call.
+func ValidateArgs(schema map[string]interface{}, args map[string]interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	return validateObject("", schema, args)
+}
+
+func validateObject(path string, schema map[string]interface{}, obj map[string]interface{}) error {
+	for _, name := range schemaStrings(schema["required"]) {
+		if v, ok := obj[name]; !ok || v == nil {
+			return fmt.Errorf("missing required parameter %q", joinPath(path, name))
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := obj[name]
+		prop, ok := props[name].(map[string]interface{})
+		if !ok || v == nil {
+			continue
+		}
+		if err := validateValue(joinPath(path, name), prop, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(path string, schema map[string]interface{}, v interface{}) error {
+	typ, _ := schema["type"].(string)
+	if typ != "" && !matchesType(typ, v) {
+		return fmt.Errorf("parameter %q must be %s, got %s", path, withArticle(typ), jsonTypeName(v))
+	}
+
+	if enum := schemaStrings(schema["enum"]); len(enum) > 0 {
+		s, ok := v.(string)
+		if !ok || !containsString(enum, s) {
+			return fmt.Errorf("parameter %q must be one of %s, got %v", path, strings.Join(enum, ", "), v)
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if typ == "object" {
+			return validateObject(path, schema, val)
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				if item == nil {
+					continue
+				}
+				if err := validateValue(fmt.Sprintf("%s[%d]", path, i), items, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesType reports whether v, as decoded by encoding/json, has the JSON
+// Schema type typ. Integers arrive as whole float64 values.
+func matchesType(typ string, v interface{}) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		switch v.(type) {
+		case float64, float32, int, int64:
+			return true
+		}
+		return false
+	case "integer":
+		switch n := v.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == math.Trunc(n) && !math.IsInf(n, 0)
+		}
+		return false
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func jsonTypeName(v interface{}) string {
+	switch n := v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	case int, int64, float32:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func withArticle(typ string) string {
+	switch typ {
+	case "array", "integer", "object":
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+// schemaStrings reads a string list from a schema keyword, which is []string
+// when built in Go and []interface{} when decoded from JSON.
+func schemaStrings(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
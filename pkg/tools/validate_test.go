@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateArgs(t *testing.T) {
+	schema := (&ExecTool{}).Parameters()
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		wantErr string
+	}{
+		{"valid", map[string]interface{}{"command": "ls"}, ""},
+		{"missing required", map[string]interface{}{}, `missing required parameter "command"`},
+		{"null required", map[string]interface{}{"command": nil}, `missing required parameter "command"`},
+		{"wrong type", map[string]interface{}{"command": 42.0}, `parameter "command" must be a string, got integer`},
+		{"bad enum", map[string]interface{}{"command": "ls", "output": "both"}, `parameter "output" must be one of`},
+		{"unknown ignored", map[string]interface{}{"command": "ls", "extra": true}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateArgs(schema, tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	intSchema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"n": map[string]interface{}{"type": "integer"}},
+	}
+	if err := ValidateArgs(intSchema, map[string]interface{}{"n": 3.0}); err != nil {
+		t.Errorf("whole float rejected as integer: %v", err)
+	}
+	if err := ValidateArgs(intSchema, map[string]interface{}{"n": 3.5}); err == nil {
+		t.Error("fractional number accepted as integer")
+	}
+}
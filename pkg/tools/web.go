@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -30,6 +31,11 @@ func init() {
 		"169.254.0.0/16", // link-local / AWS metadata
 		"fd00::/8",       // IPv6 private
 		"::1/128",        // IPv6 loopback
+		"0.0.0.0/8",      // "this network"
+		"100.64.0.0/10",  // carrier-grade NAT
+		"fc00::/7",       // IPv6 unique local
+		"fe80::/10",      // IPv6 link-local
+		"::/128",         // IPv6 unspecified
 	}
 	for _, cidr := range cidrs {
 		_, ipNet, err := net.ParseCIDR(cidr)
@@ -76,6 +82,20 @@ func validateHostNotPrivate(host string) error {
 	return nil
 }
 
+// denyPrivateDialControl is a net.Dialer Control hook that refuses to connect
+// to private/internal addresses. Checking at dial time also covers redirects
+// and DNS answers that change between validation and connect.
+func denyPrivateDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		return fmt.Errorf("access denied: cannot fetch private/internal URLs")
+	}
+	return nil
+}
+
 type WebSearchTool struct {
 	apiKey     string
 	maxResults int
@@ -193,7 +213,8 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]interface{}
 }
 
 type WebFetchTool struct {
-	maxChars int
+	maxChars     int
+	allowPrivate bool
 }
 
 func NewWebFetchTool(maxChars int) *WebFetchTool {
@@ -205,12 +226,18 @@ func NewWebFetchTool(maxChars int) *WebFetchTool {
 	}
 }
 
+// SetAllowPrivateNetworks disables SSRF protection, allowing requests to
+// loopback, private and link-local addresses. Only for trusted environments.
+func (t *WebFetchTool) SetAllowPrivateNetworks(allow bool) {
+	t.allowPrivate = allow
+}
+
 func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
 
 func (t *WebFetchTool) Description() string {
-	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
+	return "Fetch a URL (GET or POST) and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
 }
 
 func (t *WebFetchTool) Parameters() map[string]interface{} {
@@ -221,6 +248,19 @@ func (t *WebFetchTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "URL to fetch",
 			},
+			"method": map[string]interface{}{
+				"type":        "string",
+				"description": "HTTP method (default GET)",
+				"enum":        []string{"GET", "POST"},
+			},
+			"body": map[string]interface{}{
+				"type":        "string",
+				"description": "Request body for POST",
+			},
+			"content_type": map[string]interface{}{
+				"type":        "string",
+				"description": "Content-Type of the POST body (default application/json)",
+			},
 			"maxChars": map[string]interface{}{
 				"type":        "integer",
 				"description": "Maximum characters to extract",
@@ -257,12 +297,44 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]interface{})
 		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if !t.allowPrivate {
+		if err := validateHostNotPrivate(parsedURL.Host); err != nil {
+			return "", err
+		}
+	}
+
+	method := "GET"
+	if m, ok := args["method"].(string); ok && m != "" {
+		method = m
+	}
+	var reqBody io.Reader
+	switch method {
+	case "GET":
+	case "POST":
+		body, _ := args["body"].(string)
+		reqBody = strings.NewReader(body)
+	default:
+		return "", fmt.Errorf("unsupported method %q (use GET or POST)", method)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reqBody)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", userAgent)
+	if method == "POST" {
+		contentType, _ := args["content_type"].(string)
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if !t.allowPrivate {
+		dialer.Control = denyPrivateDialControl
+	}
 
 	client := &http.Client{
 		Timeout: 60 * time.Second,
@@ -271,11 +343,15 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]interface{})
 			IdleConnTimeout:     30 * time.Second,
 			DisableCompression:  false,
 			TLSHandshakeTimeout: 15 * time.Second,
+			DialContext:         dialer.DialContext,
 		},
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 5 {
 				return fmt.Errorf("stopped after 5 redirects")
 			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
 			return nil
 		},
 	}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOTelTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	SetTracer(NewOTelTracer(provider.Tracer("test")))
+	defer SetTracer(nil)
+
+	ctx, parent := Start(context.Background(), "turn", map[string]interface{}{"session": "s1"})
+	_, child := Start(ctx, "tool.exec", map[string]interface{}{"tool.exit_code": 1, "tool.args": struct{ N int }{2}})
+	End(child, errors.New("exit status 1"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended, want 2", len(spans))
+	}
+	got, turn := spans[0], spans[1]
+	if got.Parent().SpanID() != turn.SpanContext().SpanID() {
+		t.Error("tool span isn't a child of the turn span")
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range got.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["tool.exit_code"].AsInt64() != 1 || attrs["tool.args"].AsString() != "{2}" {
+		t.Errorf("attributes = %v", got.Attributes())
+	}
+	if got.Status().Code != codes.Error || len(got.Events()) != 1 {
+		t.Errorf("status = %v with %d events, want the error recorded", got.Status(), len(got.Events()))
+	}
+}
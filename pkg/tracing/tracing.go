@@ -0,0 +1,77 @@
+// Package tracing wraps memory, provider and tool calls in spans. Install a
+// Tracer with SetTracer, e.g. one from NewOTLPTracer or NewOTelTracer; until
+// one is installed every span is a no-op.
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Tracer starts spans. Implementations must honour any parent span carried
+// in ctx and return a context carrying the new one.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single timed operation.
+type Span interface {
+	SetAttributes(attrs map[string]interface{})
+	RecordError(err error)
+	End()
+}
+
+var (
+	mu     sync.RWMutex
+	tracer Tracer = noopTracer{}
+)
+
+// SetTracer installs the tracer used by Start. nil restores the no-op one.
+func SetTracer(t Tracer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if t == nil {
+		t = noopTracer{}
+	}
+	tracer = t
+}
+
+// Start begins a span named name as a child of any span in ctx. The span
+// gets the correlation fields attached to ctx with logger.With (session key,
+// channel, ...) plus attrs.
+func Start(ctx context.Context, name string, attrs map[string]interface{}) (context.Context, Span) {
+	mu.RLock()
+	t := tracer
+	mu.RUnlock()
+
+	ctx, span := t.Start(ctx, name)
+	if fields := logger.FieldsFrom(ctx); len(fields) > 0 {
+		span.SetAttributes(fields)
+	}
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs)
+	}
+	return ctx, span
+}
+
+// End records err on span, if non-nil, and ends it.
+func End(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(map[string]interface{}) {}
+func (noopSpan) RecordError(error)                    {}
+func (noopSpan) End()                                 {}